@@ -1,10 +1,20 @@
 package main
 
 import (
+	"os"
+
 	"github.com/aziule/filebuildtag/pkg/filebuildtag"
 	"golang.org/x/tools/go/analysis/singlechecker"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case reportCommand:
+			os.Exit(report(os.Args[2:], os.Stdout, os.Stderr))
+		case scanCommand:
+			os.Exit(scan(os.Args[2:], os.Stdout, os.Stderr))
+		}
+	}
 	singlechecker.Main(filebuildtag.Analyzer)
 }
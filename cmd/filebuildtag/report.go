@@ -0,0 +1,339 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/aziule/filebuildtag/pkg/filebuildtag"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/packages"
+)
+
+const (
+	reportCommand = "report"
+	reportUsage   = `Usage: filebuildtag report [-json] [-strict] [-summary] [flags] [packages]
+
+Run the filebuildtag linter on the packages and print every violation, as text or as a JSON report. The command
+exits with a non-zero code on violations only with the -strict flag.
+`
+	// exitViolations is the exit code when violations were found with the -strict flag, in line with singlechecker.
+	exitViolations = 3
+)
+
+// violation is a diagnostic of the report. Its severity is the one of the rules violation, the other diagnostics
+// being errors. The pattern, tag and found tags are only set for the rules violations, and the tags for the
+// aggregated missing tags.
+type violation struct {
+	File     string   `json:"file"`
+	Line     int      `json:"line"`
+	Column   int      `json:"column"`
+	Kind     string   `json:"kind,omitempty"`
+	Severity string   `json:"severity"`
+	Message  string   `json:"message"`
+	Patterns []string `json:"patterns,omitempty"`
+	Tag      string   `json:"tag,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	Found    []string `json:"found,omitempty"`
+}
+
+// summary is the number of files checked by the analyzer, and of the ones matching a pattern of the filetags. A
+// checked file is only counted once, even when it is part of several variants of its package.
+type summary struct {
+	FilesChecked int `json:"files_checked"`
+	FilesMatched int `json:"files_matched"`
+}
+
+// add adds the files counted by the result of the analyzer.
+func (s *summary) add(res *filebuildtag.Result) {
+	s.FilesChecked += res.FilesChecked
+	s.FilesMatched += res.FilesMatched
+}
+
+// jsonReport is the report printed with the -json flag. Its summary is only set with the -summary flag, and its
+// number of suppressed violations with the max-reports flag.
+type jsonReport struct {
+	Violations []violation `json:"violations"`
+	Suppressed int         `json:"suppressed,omitempty"`
+	Summary    *summary    `json:"summary,omitempty"`
+}
+
+// commandFlags are the flags of the commands printing violations: the flags of the analyzer along with the -json,
+// -strict and -summary flags.
+type commandFlags struct {
+	*flag.FlagSet
+	asJSON      *bool
+	strict      *bool
+	withSummary *bool
+	// maxReports is the value of the max-reports flag, which caps the violations of the whole run rather than the
+	// ones of each package.
+	maxReports *int
+	// stdout and stderr are where the violations and the notes are printed.
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// newCommandFlags returns the flags of the command, printing to stdout and stderr.
+func newCommandFlags(name, usage string, stdout, stderr io.Writer) commandFlags {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usage)
+		fs.PrintDefaults()
+	}
+	cf := commandFlags{
+		FlagSet: fs,
+		asJSON:  fs.Bool("json", false, "print the violations as a JSON report"),
+		strict:  fs.Bool("strict", false, "exit with the code 3 when violations of the error severity are found, such as in CI"),
+		withSummary: fs.Bool("summary", false,
+			"print the number of files checked and matching a pattern, to stderr or within the JSON report"),
+		maxReports: new(int),
+		stdout:     stdout,
+		stderr:     stderr,
+	}
+	// The analyzer flags are registered as is, so that the analyzer reads their values.
+	filebuildtag.Analyzer.Flags.VisitAll(func(f *flag.Flag) {
+		fs.Var(f.Value, f.Name, f.Usage)
+	})
+	return cf
+}
+
+// parse parses the arguments, and returns the exit code of the command and false when it must stop.
+func (cf commandFlags) parse(args []string) (int, bool) {
+	if err := cf.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0, false
+		}
+		return 2, false
+	}
+	// The analyzer reports every violation of each package, the command capping the ones of the whole run.
+	if f := cf.Lookup(filebuildtag.FlagMaxReportsName); f != nil {
+		*cf.maxReports, _ = strconv.Atoi(f.Value.String())
+		if *cf.maxReports < 0 {
+			fmt.Fprintf(cf.Output(), "negative max reports %d, must be 0 to report every violation or more\n",
+				*cf.maxReports)
+			return 2, false
+		}
+		if err := f.Value.Set("0"); err != nil {
+			return 2, false
+		}
+	}
+	return 0, true
+}
+
+// report runs the report command, printing to stdout and stderr, and returns its exit code.
+func report(args []string, stdout, stderr io.Writer) int {
+	cf := newCommandFlags(reportCommand, reportUsage, stdout, stderr)
+	if code, ok := cf.parse(args); !ok {
+		return code
+	}
+	violations, s, err := analyze(cf.Args(), stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, "filebuildtag: %v\n", err)
+		return 1
+	}
+	return cf.print(violations, s)
+}
+
+// print prints the violations, along with the summary with the -summary flag, and returns the exit code of the
+// command.
+func (cf commandFlags) print(violations []violation, s summary) int {
+	var withSummary *summary
+	if *cf.withSummary {
+		withSummary = &s
+	}
+	shown, suppressed := violations, 0
+	if max := *cf.maxReports; max > 0 && len(violations) > max {
+		shown, suppressed = violations[:max], len(violations)-max
+	}
+	var err error
+	if *cf.asJSON {
+		err = printJSON(cf.stdout, shown, suppressed, withSummary)
+	} else {
+		err = printText(cf.stdout, shown)
+		if err == nil && suppressed > 0 {
+			// Like the summary, the note is printed to stderr so that the output can still be parsed.
+			_, err = fmt.Fprintf(cf.stderr, "filebuildtag: %d more findings suppressed by the max-reports flag\n",
+				suppressed)
+		}
+		if err == nil && withSummary != nil {
+			// The summary is printed to stderr, so that the output can still be parsed like the linter one.
+			_, err = fmt.Fprintf(cf.stderr, "filebuildtag: %d files checked, %d matching a pattern\n",
+				s.FilesChecked, s.FilesMatched)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(cf.stderr, "filebuildtag: %v\n", err)
+		return 1
+	}
+	// The violations are printed either way, only their exit code depends on the -strict flag, so that the same
+	// rules serve both the editors and the CI. Only errors fail the command, warnings being soft nudges, including
+	// the suppressed ones.
+	if !*cf.strict {
+		return 0
+	}
+	for _, v := range violations {
+		if v.Severity == string(filebuildtag.SeverityError) {
+			return exitViolations
+		}
+	}
+	return 0
+}
+
+// analyze loads the packages, including their tests, and runs the analyzer on each of them. The errors of the
+// packages are printed to stderr, like packages.PrintErrors does.
+func analyze(patterns []string, stderr io.Writer) ([]violation, summary, error) {
+	if len(patterns) == 0 {
+		patterns = []string{"."}
+	}
+	cfg := &packages.Config{
+		Mode:  packages.NeedName | packages.NeedFiles | packages.NeedSyntax | packages.NeedModule,
+		Tests: true,
+	}
+	pkgs, err := packages.Load(cfg, patterns...)
+	if err != nil {
+		return nil, summary{}, err
+	}
+	var n int
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		for _, err := range pkg.Errors {
+			fmt.Fprintln(stderr, err)
+			n++
+		}
+	})
+	if n > 0 {
+		return nil, summary{}, fmt.Errorf("%d errors while loading packages", n)
+	}
+
+	// The files of a package are all part of its test variant, such as "foo [foo.test]", if any, so only the variant
+	// counts them.
+	tested := make(map[string]bool)
+	for _, pkg := range pkgs {
+		if pkg.ID != pkg.PkgPath {
+			tested[pkg.PkgPath] = true
+		}
+	}
+	var violations []violation
+	var s summary
+	// Files of a package are loaded again with its test variant, so diagnostics are deduplicated.
+	seen := make(map[string]bool)
+	for _, pkg := range pkgs {
+		// The generated test main packages are not linted.
+		if strings.HasSuffix(pkg.PkgPath, ".test") {
+			continue
+		}
+		found, res, err := analyzePackage(pkg)
+		if err != nil {
+			return nil, summary{}, fmt.Errorf("%s: %w", pkg.ID, err)
+		}
+		if pkg.ID != pkg.PkgPath || !tested[pkg.PkgPath] {
+			s.add(res)
+		}
+		for _, v := range found {
+			key := fmt.Sprintf("%s:%d:%d: %s", v.File, v.Line, v.Column, v.Message)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			violations = append(violations, v)
+		}
+	}
+	return violations, s, nil
+}
+
+// analyzePackage runs the analyzer on the package, and returns its violations along with the result of the analyzer.
+// The analyzer only needs the syntax of the files, so the package is not type-checked.
+func analyzePackage(pkg *packages.Package) ([]violation, *filebuildtag.Result, error) {
+	var diagnostics []analysis.Diagnostic
+	pass := &analysis.Pass{
+		Analyzer:     filebuildtag.Analyzer,
+		Fset:         pkg.Fset,
+		Files:        pkg.Syntax,
+		OtherFiles:   pkg.OtherFiles,
+		IgnoredFiles: pkg.IgnoredFiles,
+		Pkg:          types.NewPackage(pkg.PkgPath, pkg.Name),
+		TypesInfo:    &types.Info{},
+		ResultOf: map[*analysis.Analyzer]interface{}{
+			inspect.Analyzer: inspector.New(pkg.Syntax),
+		},
+		Report: func(d analysis.Diagnostic) {
+			diagnostics = append(diagnostics, d)
+		},
+		ReadFile:          os.ReadFile,
+		ImportObjectFact:  func(types.Object, analysis.Fact) bool { return false },
+		ExportObjectFact:  func(types.Object, analysis.Fact) {},
+		ImportPackageFact: func(*types.Package, analysis.Fact) bool { return false },
+		ExportPackageFact: func(analysis.Fact) {},
+		AllObjectFacts:    func() []analysis.ObjectFact { return nil },
+		AllPackageFacts:   func() []analysis.PackageFact { return nil },
+	}
+	if pkg.Module != nil {
+		pass.Module = &analysis.Module{Path: pkg.Module.Path, Version: pkg.Module.Version, GoVersion: pkg.Module.GoVersion}
+	}
+	res, err := filebuildtag.Analyzer.Run(pass)
+	if err != nil {
+		return nil, nil, err
+	}
+	result := res.(*filebuildtag.Result)
+
+	type key struct {
+		pos     token.Pos
+		message string
+	}
+	rulesViolations := make(map[key]filebuildtag.Violation)
+	for _, v := range result.Violations {
+		rulesViolations[key{v.Pos, v.Message}] = v
+	}
+	violations := make([]violation, 0, len(diagnostics))
+	for _, d := range diagnostics {
+		position := pkg.Fset.Position(d.Pos)
+		v := violation{
+			File:     position.Filename,
+			Line:     position.Line,
+			Column:   position.Column,
+			Severity: string(filebuildtag.SeverityError),
+			Message:  d.Message,
+		}
+		if rv, ok := rulesViolations[key{d.Pos, d.Message}]; ok {
+			v.Kind = string(rv.Kind)
+			v.Severity = string(rv.Severity)
+			v.Patterns = rv.Patterns
+			v.Tag = rv.Tag
+			v.Tags = rv.Tags
+			v.Found = rv.Found
+		}
+		violations = append(violations, v)
+	}
+	return violations, result, nil
+}
+
+func printText(w io.Writer, violations []violation) error {
+	for _, v := range violations {
+		message := v.Message
+		// The messages are already prefixed with their severity with the severity-prefix flag.
+		if v.Severity != string(filebuildtag.SeverityError) && !strings.HasPrefix(message, v.Severity+": ") {
+			message = v.Severity + ": " + message
+		}
+		if _, err := fmt.Fprintf(w, "%s:%d:%d: %s\n", v.File, v.Line, v.Column, message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func printJSON(w io.Writer, violations []violation, suppressed int, s *summary) error {
+	if violations == nil {
+		violations = []violation{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(jsonReport{Violations: violations, Suppressed: suppressed, Summary: s})
+}
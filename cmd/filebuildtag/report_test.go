@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aziule/filebuildtag/pkg/filebuildtag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_analyze(t *testing.T) {
+	parseTestFlags(t, "-filetags", "foo*.go:tag1")
+	violations, s, err := analyze([]string{"./testdata/report/foo"}, io.Discard)
+	require.NoError(t, err)
+	// foo.go is loaded by both "foo" and "foo [foo.test]", while it is only reported once.
+	require.Len(t, violations, 2)
+	require.Equal(t, summary{FilesChecked: 2, FilesMatched: 2}, s)
+
+	dir, err := filepath.Abs(filepath.Join("testdata", "report", "foo"))
+	require.NoError(t, err)
+	var out bytes.Buffer
+	require.NoError(t, printJSON(&out, violations, 0, nil))
+	require.JSONEq(t, `{
+		"violations": [
+			{
+				"file": `+quoteJSON(t, filepath.Join(dir, "foo.go"))+`,
+				"line": 1,
+				"column": 1,
+				"kind": "missing-tag",
+				"severity": "error",
+				"message": "missing expected build tag: \"tag1\" required by pattern \"foo*.go\" (file has no build tags)",
+				"patterns": ["foo*.go"],
+				"tag": "tag1"
+			},
+			{
+				"file": `+quoteJSON(t, filepath.Join(dir, "foo_test.go"))+`,
+				"line": 1,
+				"column": 1,
+				"kind": "missing-tag",
+				"severity": "error",
+				"message": "missing expected build tag: \"tag1\" required by pattern \"foo*.go\" (file has: [unit])",
+				"patterns": ["foo*.go"],
+				"tag": "tag1",
+				"found": ["unit"]
+			}
+		]
+	}`, out.String())
+}
+
+func Test_report(t *testing.T) {
+	testCases := map[string]struct {
+		args           []string
+		expected       int
+		expectedStdout []string
+		expectedStderr []string
+	}{
+		"successfully report the violations": {
+			args:     []string{"-strict", "-filetags", "foo*.go:tag1", "./testdata/report/foo"},
+			expected: exitViolations,
+			expectedStdout: []string{
+				`foo.go:1:1: missing expected build tag: "tag1" required by pattern "foo*.go" (file has no build tags)`,
+				`foo_test.go:1:1: missing expected build tag: "tag1" required by pattern "foo*.go" (file has: [unit])`,
+			},
+		},
+		"fail to load missing packages": {
+			args:           []string{"./testdata/report/missing"},
+			expected:       1,
+			expectedStderr: []string{"testdata/report/missing", "filebuildtag: 1 errors while loading packages"},
+		},
+		"fail to run the analyzer on malformed rules": {
+			args:     []string{"-filetags", ":foo", "./testdata/report/foo"},
+			expected: 1,
+			expectedStderr: []string{
+				`filebuildtag: github.com/aziule/filebuildtag/cmd/filebuildtag/testdata/report/foo: malformed argument: ":foo", must be of the form "pattern:tag": empty pattern`,
+			},
+		},
+		"fail to parse unknown flags": {
+			args:           []string{"-unknown"},
+			expected:       2,
+			expectedStderr: []string{"flag provided but not defined: -unknown", "Usage: filebuildtag report"},
+		},
+	}
+	for name, tt := range testCases {
+		t.Run(name, func(t *testing.T) {
+			resetAnalyzerFlags(t)
+			var stdout, stderr bytes.Buffer
+			require.Equal(t, tt.expected, report(tt.args, &stdout, &stderr))
+			require.Equal(t, len(tt.expectedStdout), strings.Count(stdout.String(), "\n"))
+			for _, line := range tt.expectedStdout {
+				assert.Contains(t, stdout.String(), line)
+			}
+			if tt.expectedStderr == nil {
+				assert.Empty(t, stderr.String())
+			}
+			for _, line := range tt.expectedStderr {
+				assert.Contains(t, stderr.String(), line)
+			}
+		})
+	}
+}
+
+func Test_printJSON_noViolations(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, printJSON(&out, nil, 0, &summary{FilesChecked: 3}))
+	require.JSONEq(t, `{"violations": [], "summary": {"files_checked": 3, "files_matched": 0}}`, out.String())
+}
+
+func Test_commandFlags_print(t *testing.T) {
+	warning := violation{File: "foo.go", Line: 1, Column: 1, Severity: "warning", Message: "missing expected build tag"}
+	failure := violation{File: "bar.go", Line: 1, Column: 1, Severity: "error", Message: "missing expected build tag"}
+	testCases := map[string]struct {
+		args       []string
+		violations []violation
+		expected   int
+	}{
+		"successfully exit with 0 without violations": {},
+		"successfully exit with 0 on warnings": {
+			violations: []violation{warning},
+		},
+		"successfully exit with 0 on errors without the strict flag": {
+			violations: []violation{warning, failure},
+		},
+		"successfully exit with 0 without violations with the strict flag": {
+			args: []string{"-strict"},
+		},
+		"successfully exit with 0 on warnings with the strict flag": {
+			args:       []string{"-strict"},
+			violations: []violation{warning, warning},
+		},
+		"successfully exit with exitViolations on errors with the strict flag": {
+			args:       []string{"-strict"},
+			violations: []violation{warning, failure},
+			expected:   exitViolations,
+		},
+	}
+	for name, tt := range testCases {
+		t.Run(name, func(t *testing.T) {
+			cf := parseTestFlags(t, tt.args...)
+			var stdout bytes.Buffer
+			cf.stdout = &stdout
+			require.Equal(t, tt.expected, cf.print(tt.violations, summary{}))
+			// The violations are printed whatever the exit code.
+			require.Equal(t, len(tt.violations), strings.Count(stdout.String(), "\n"))
+		})
+	}
+}
+
+func Test_commandFlags_maxReports(t *testing.T) {
+	violations := []violation{
+		{File: "a.go", Line: 1, Column: 1, Severity: "error", Message: "missing expected build tag"},
+		{File: "b.go", Line: 1, Column: 1, Severity: "error", Message: "missing expected build tag"},
+		{File: "c.go", Line: 1, Column: 1, Severity: "warning", Message: "missing expected build tag"},
+	}
+
+	t.Run("successfully cap the violations of the whole run", func(t *testing.T) {
+		cf := parseTestFlags(t, "-max-reports=1")
+		require.Equal(t, 1, *cf.maxReports)
+		// The analyzer reports every violation of each package, the command capping the ones of the whole run.
+		require.Equal(t, "0", filebuildtag.Analyzer.Flags.Lookup(filebuildtag.FlagMaxReportsName).Value.String())
+
+		var stdout, stderr bytes.Buffer
+		cf.stdout, cf.stderr = &stdout, &stderr
+		require.Equal(t, 0, cf.print(violations, summary{}))
+		require.Equal(t, "a.go:1:1: missing expected build tag\n", stdout.String())
+		require.Equal(t, "filebuildtag: 2 more findings suppressed by the max-reports flag\n", stderr.String())
+	})
+
+	t.Run("successfully report the suppressed violations within the JSON report", func(t *testing.T) {
+		cf := parseTestFlags(t, "-max-reports=1", "-json", "-strict")
+		var stdout, stderr bytes.Buffer
+		cf.stdout, cf.stderr = &stdout, &stderr
+		// The suppressed violations still fail the command.
+		require.Equal(t, exitViolations, cf.print(violations[1:], summary{}))
+		require.JSONEq(t, `{
+			"violations": [
+				{"file": "b.go", "line": 1, "column": 1, "severity": "error", "message": "missing expected build tag"}
+			],
+			"suppressed": 1
+		}`, stdout.String())
+		require.Empty(t, stderr.String())
+	})
+
+	t.Run("successfully report every violation without the flag", func(t *testing.T) {
+		cf := parseTestFlags(t)
+		var stdout bytes.Buffer
+		cf.stdout = &stdout
+		require.Equal(t, 0, cf.print(violations, summary{}))
+		require.Equal(t, 3, strings.Count(stdout.String(), "\n"))
+	})
+
+	t.Run("fail to parse a negative value", func(t *testing.T) {
+		resetAnalyzerFlags(t)
+		var output bytes.Buffer
+		cf := newCommandFlags(reportCommand, reportUsage, io.Discard, &output)
+		code, ok := cf.parse([]string{"-max-reports=-1"})
+		require.False(t, ok)
+		require.Equal(t, 2, code)
+		require.Equal(t, "negative max reports -1, must be 0 to report every violation or more\n", output.String())
+	})
+}
+
+// parseTestFlags parses the arguments with the flags of the commands, which set the flags of the analyzer, and
+// restores the defaults of the analyzer flags once the test is done.
+func parseTestFlags(t *testing.T, args ...string) commandFlags {
+	resetAnalyzerFlags(t)
+	cf := newCommandFlags(reportCommand, reportUsage, io.Discard, io.Discard)
+	code, ok := cf.parse(args)
+	require.True(t, ok)
+	require.Equal(t, 0, code)
+	return cf
+}
+
+// resetAnalyzerFlags restores the defaults of the analyzer flags, which the commands share, once the test is done.
+func resetAnalyzerFlags(t *testing.T) {
+	t.Cleanup(func() {
+		filebuildtag.Analyzer.Flags.VisitAll(func(f *flag.Flag) {
+			require.NoError(t, f.Value.Set(f.DefValue))
+		})
+	})
+}
+
+// quoteJSON returns the string as a JSON string.
+func quoteJSON(t *testing.T, s string) string {
+	b, err := json.Marshal(s)
+	require.NoError(t, err)
+	return string(b)
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+const (
+	scanCommand = "scan"
+	scanUsage   = `Usage: filebuildtag scan [-json] [-strict] [-summary] [flags] [directory]
+
+Check the Go files of the directory and its subdirectories against the rules, along with the non-Go files having build
+constraints such as the assembly files, without loading nor compiling their packages, to quickly try a config. The
+files are only parsed up to their imports, so the comments found after them, such as misplaced build constraints, are
+not checked. The files whose header does not parse are skipped with a note.
+`
+)
+
+// scan runs the scan command, printing to stdout and stderr, and returns its exit code.
+func scan(args []string, stdout, stderr io.Writer) int {
+	cf := newCommandFlags(scanCommand, scanUsage, stdout, stderr)
+	if code, ok := cf.parse(args); !ok {
+		return code
+	}
+	root := "."
+	if cf.NArg() > 0 {
+		root = cf.Arg(0)
+	}
+	violations, s, err := scanDir(root, stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, "filebuildtag: %v\n", err)
+		return 1
+	}
+	return cf.print(violations, s)
+}
+
+// otherExts are the extensions of the non-Go files whose build constraints the Go toolchain reads, like go/build.
+// The system object files are binary files, which cannot have build constraints.
+var otherExts = map[string]bool{
+	".c": true, ".cc": true, ".cpp": true, ".cxx": true, ".m": true, ".h": true, ".hh": true, ".hpp": true,
+	".hxx": true, ".f": true, ".F": true, ".for": true, ".f90": true, ".s": true, ".S": true, ".sx": true,
+	".swig": true, ".swigcxx": true,
+}
+
+// scanDir checks the Go files of the directory and its subdirectories, along with the non-Go files of otherExts. The
+// files are grouped by directory and package name into packages whose path is their directory relative to the root,
+// using forward slashes, so that the path patterns match the same files as when the root is the root of the module.
+// The non-Go files are part of the package of their directory which is not an external test package, if any. Like
+// the go command, the testdata and vendor directories are skipped along with the ones starting with "." or "_". The
+// Go files whose header does not parse are skipped, with a note printed to stderr, so that a single broken file does
+// not hide the violations of the other ones.
+func scanDir(root string, stderr io.Writer) ([]violation, summary, error) {
+	fset := token.NewFileSet()
+	pkgs := make(map[string]*packages.Package)
+	others := make(map[string][]string)
+	err := filepath.WalkDir(root, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		base := d.Name()
+		if d.IsDir() {
+			if name != root && (base == "testdata" || base == "vendor" || strings.HasPrefix(base, ".") ||
+				strings.HasPrefix(base, "_")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		ext := filepath.Ext(base)
+		if (ext != ".go" && !otherExts[ext]) || strings.HasPrefix(base, ".") || strings.HasPrefix(base, "_") {
+			return nil
+		}
+		dir, err := filepath.Rel(root, filepath.Dir(name))
+		if err != nil {
+			return err
+		}
+		pkgPath := path.Clean(filepath.ToSlash(dir))
+		if pkgPath == "." {
+			pkgPath = ""
+		}
+		if ext != ".go" {
+			others[pkgPath] = append(others[pkgPath], name)
+			return nil
+		}
+		src, err := os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		f, err := parser.ParseFile(fset, name, src, parser.ImportsOnly|parser.ParseComments)
+		if err != nil {
+			// The error names the file, along with the position of the syntax error.
+			fmt.Fprintf(stderr, "filebuildtag: skipping the file: %v\n", err)
+			return nil
+		}
+		// The lines after the imports are not scanned, while the line count patterns need all of them.
+		fset.File(f.Pos()).SetLinesForContent(src)
+		addFile(pkgs, fset, pkgPath, f)
+		return nil
+	})
+	if err != nil {
+		return nil, summary{}, err
+	}
+
+	ids := make([]string, 0, len(pkgs))
+	for id := range pkgs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if pkg := pkgs[id]; !strings.HasSuffix(pkg.Name, "_test") && pkg.OtherFiles == nil {
+			pkg.OtherFiles = others[pkg.PkgPath]
+			delete(others, pkg.PkgPath)
+		}
+	}
+	var violations []violation
+	var s summary
+	for _, id := range ids {
+		found, res, err := analyzePackage(pkgs[id])
+		if err != nil {
+			return nil, summary{}, fmt.Errorf("%s: %w", id, err)
+		}
+		violations = append(violations, found...)
+		s.add(res)
+	}
+	return violations, s, nil
+}
+
+// addFile adds the file to the package of its directory and package name, creating it if needed.
+func addFile(pkgs map[string]*packages.Package, fset *token.FileSet, pkgPath string, f *ast.File) {
+	name := f.Name.Name
+	id := pkgPath + " " + name
+	pkg, ok := pkgs[id]
+	if !ok {
+		// External test packages are named after the directory of the package they test, like getPath expects.
+		if strings.HasSuffix(name, "_test") {
+			pkgPath += "_test"
+		}
+		pkg = &packages.Package{ID: id, Name: name, PkgPath: pkgPath, Fset: fset}
+		pkgs[id] = pkg
+	}
+	pkg.Syntax = append(pkg.Syntax, f)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_scanDir(t *testing.T) {
+	parseTestFlags(t, "-filetags", "*_test.go:unit,*_amd64.*:amd64,sub/*.go:sub")
+	root := filepath.Join("testdata", "scan")
+	var stderr bytes.Buffer
+	violations, s, err := scanDir(root, &stderr)
+	require.NoError(t, err)
+	// The files whose header does not parse are skipped with a note, the other files being still checked.
+	require.Equal(t, "filebuildtag: skipping the file: "+filepath.Join(root, "sub", "broken_test.go")+
+		":3:1: expected 'package', found packge\n", stderr.String())
+
+	var found []string
+	for _, v := range violations {
+		rel, err := filepath.Rel(root, v.File)
+		require.NoError(t, err)
+		found = append(found, fmt.Sprintf("%s:%d: %s", filepath.ToSlash(rel), v.Line, v.Message))
+	}
+	// The files of the testdata, vendor and hidden directories are skipped, and the body of sub/b.go, which does not
+	// parse, is not scanned.
+	require.ElementsMatch(t, []string{
+		`sub/b.go:1: missing expected build tag: "sub" required by pattern "sub/*.go" (file has no build tags)`,
+		`sub/b_amd64.s:1: missing expected build tag: "amd64" required by pattern "*_amd64.*" (file has no build tags)`,
+		`sub/b_test.go:1: missing expected build tag: "sub" required by pattern "sub/*.go" (file has no build tags)`,
+		`sub/b_test.go:1: missing expected build tag: "unit" required by pattern "*_test.go" (file has no build tags)`,
+	}, found)
+	require.Equal(t, summary{FilesChecked: 6, FilesMatched: 5}, s)
+}
+
+func Test_scan(t *testing.T) {
+	testCases := map[string]struct {
+		args           []string
+		expected       int
+		expectedStdout string
+		expectedStderr string
+	}{
+		"successfully scan the directory despite the files not parsing": {
+			args:     []string{"-strict", "-filetags", "*_test.go:unit", filepath.Join("testdata", "scan")},
+			expected: exitViolations,
+			expectedStdout: filepath.Join("testdata", "scan", "sub", "b_test.go") +
+				`:1:1: missing expected build tag: "unit" required by pattern "*_test.go" (file has no build tags)` + "\n",
+			expectedStderr: "filebuildtag: skipping the file: " + filepath.Join("testdata", "scan", "sub", "broken_test.go") +
+				":3:1: expected 'package', found packge\n",
+		},
+		"fail to scan a missing directory": {
+			args:     []string{filepath.Join("testdata", "missing")},
+			expected: 1,
+			expectedStderr: "filebuildtag: lstat " + filepath.Join("testdata", "missing") +
+				": no such file or directory\n",
+		},
+	}
+	for name, tt := range testCases {
+		t.Run(name, func(t *testing.T) {
+			resetAnalyzerFlags(t)
+			var stdout, stderr bytes.Buffer
+			require.Equal(t, tt.expected, scan(tt.args, &stdout, &stderr))
+			require.Equal(t, tt.expectedStdout, stdout.String())
+			require.Equal(t, tt.expectedStderr, stderr.String())
+		})
+	}
+}
@@ -0,0 +1,3 @@
+//go:build amd64
+
+TEXT ·add(SB),$0
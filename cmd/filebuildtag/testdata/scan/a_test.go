@@ -0,0 +1,3 @@
+//go:build unit || !testfix
+
+package a_test
@@ -0,0 +1,6 @@
+package b
+
+import "fmt"
+
+// The body is not parsed, as the files are only parsed up to their imports.
+func broken( {
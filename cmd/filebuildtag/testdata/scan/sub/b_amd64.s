@@ -0,0 +1 @@
+TEXT ·add(SB),$0
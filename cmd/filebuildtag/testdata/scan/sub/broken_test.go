@@ -0,0 +1,3 @@
+//go:build unit
+
+packge b
@@ -8,17 +8,42 @@
 package internal
 
 import (
+	"errors"
 	"fmt"
 	"go/ast"
+	"go/build/constraint"
+	"go/token"
 	"strings"
 	"unicode"
 
 	"golang.org/x/tools/go/analysis"
 )
 
-// CheckGoFile analyses a single Go file and returns the found tags, if any. It also reports any linting error.
-func CheckGoFile(pass *analysis.Pass, f *ast.File) []string {
-	tags := []string{}
+// Problem is a linting error found in the build constraints of a Go file.
+type Problem struct {
+	Pos     token.Pos
+	Message string
+}
+
+// CheckGoFile analyses a single Go file and returns its build constraints. It also reports any linting error.
+func CheckGoFile(pass *analysis.Pass, f *ast.File) Constraints {
+	constraints, problems := ParseGoFile(f)
+	for _, p := range problems {
+		pass.Reportf(p.Pos, "%s", p.Message)
+	}
+	return constraints
+}
+
+// ParseGoFile analyses a single Go file and returns its build constraints, along with the linting errors found.
+//
+// Both the "//go:build" and the legacy "// +build" forms are supported. When a file contains both of them,
+// the returned constraints are the combination of the constraints found in each form. Like for the Go toolchain, the
+// carriage returns of the files with CRLF line endings and the spaces and tabs around the expressions are ignored.
+// Like for go/build/constraint, the "// +build" lines keep their structure: the space-separated options of a line are
+// ORed, the comma-separated terms of an option are ANDed, and the lines are ANDed, so that "linux,386 darwin,!cgo"
+// does not have the "cgo" tag.
+func ParseGoFile(f *ast.File) (Constraints, []Problem) {
+	var p lineParser
 	pastCutoff := false
 	for _, group := range f.Comments {
 		// A +build comment is ignored after or adjoining the package declaration.
@@ -34,28 +59,138 @@ func CheckGoFile(pass *analysis.Pass, f *ast.File) []string {
 
 		// Check each line of a //-comment.
 		for _, c := range group.List {
-			if !strings.Contains(c.Text, "+build") {
-				continue
-			}
-			lineTags, err := checkLine(c.Text, pastCutoff)
-			if err != nil {
-				pass.Reportf(c.Pos(), "%s", err)
-				continue
-			}
-			tags = append(tags, lineTags...)
+			p.parse(c, pastCutoff)
 		}
+		p.endBlock()
+	}
+	return p.constraints, p.problems
+}
+
+// CheckOtherFile analyses a single non-Go file of a package, such as an assembly file, and returns its build
+// constraints. It also reports any linting error.
+func CheckOtherFile(pass *analysis.Pass, tf *token.File, content []byte) Constraints {
+	constraints, problems := ParseOtherFile(tf, content)
+	for _, p := range problems {
+		pass.Reportf(p.Pos, "%s", p.Message)
 	}
-	return tags
+	return constraints
 }
 
-// checkLine checks a line that starts with "//" and contains "+build".
-func checkLine(line string, pastCutoff bool) ([]string, error) {
-	tags := []string{}
-	line = strings.TrimPrefix(line, "//")
-	line = strings.TrimSpace(line)
+// ParseOtherFile analyses the content of a single non-Go file, positioned using its token.File, and returns its build
+// constraints along with the linting errors found. As these files have no package clause, their build constraints
+// are the "//" comments found before the first other line which is not blank.
+func ParseOtherFile(tf *token.File, content []byte) (Constraints, []Problem) {
+	var p lineParser
+	for i, line := range strings.Split(string(content), "\n") {
+		text := strings.TrimSpace(line)
+		if text == "" {
+			p.endBlock()
+			continue
+		}
+		if !strings.HasPrefix(text, "//") {
+			break
+		}
+		slash := tf.LineStart(i+1) + token.Pos(strings.Index(line, "//"))
+		p.parse(&ast.Comment{Slash: slash, Text: text}, false)
+	}
+	p.endBlock()
+	return p.constraints, p.problems
+}
+
+// lineParser parses the comment lines of a file one at a time, collecting its build constraints and the linting
+// errors found.
+type lineParser struct {
+	constraints Constraints
+	problems    []Problem
+	sawGoBuild  bool
+	// block are the texts of the comment lines other than build constraints of the block of adjoining lines being
+	// parsed, and constrained whether the block has build constraint lines.
+	block       []string
+	constrained bool
+}
+
+// endBlock ends the block of adjoining comment lines being parsed, whose other lines are the rationale of the build
+// constraints when it has some.
+func (p *lineParser) endBlock() {
+	if p.constrained && p.constraints.Rationale == "" {
+		p.constraints.Rationale = strings.Join(p.block, " ")
+	}
+	p.block, p.constrained = nil, false
+}
+
+// note records the comment line as part of the rationale of the block, unless it is empty or a directive such as
+// "//go:generate", which has no space after the slashes.
+func (p *lineParser) note(c *ast.Comment) {
+	text := strings.TrimPrefix(c.Text, "//")
+	if !strings.HasPrefix(text, " ") && !strings.HasPrefix(text, "\t") {
+		return
+	}
+	if text = strings.TrimSpace(text); text != "" {
+		p.block = append(p.block, text)
+	}
+}
 
-	if strings.HasPrefix(line, "+build") {
-		fields := strings.Fields(line)
+// parse parses a "//" comment line, pastCutoff being whether it is found after the build constraints section.
+func (p *lineParser) parse(c *ast.Comment, pastCutoff bool) {
+	if constraint.IsGoBuild(c.Text) {
+		// A //go:build comment is ignored after or adjoining the package declaration, which is most likely
+		// a mistake as the file is then not constrained as expected.
+		if pastCutoff {
+			p.report(c, errMisplacedGoBuild.Error())
+			return
+		}
+		if p.sawGoBuild {
+			p.report(c, errExtraGoBuild.Error())
+			return
+		}
+		p.sawGoBuild = true
+		expr, err := parseLine(c.Text)
+		if err != nil {
+			p.report(c, fmt.Sprintf("invalid //go:build comment: %v", err))
+			return
+		}
+		p.constraints.add(c, expr)
+		p.constrained = true
+		return
+	}
+	// A "// go:build" comment is silently ignored by the Go toolchain, leaving the file unconstrained.
+	if !pastCutoff && constraint.IsGoBuild("//"+strings.TrimSpace(strings.TrimPrefix(c.Text, "//"))) {
+		p.report(c, errSpacedGoBuild.Error())
+		return
+	}
+	if !strings.Contains(c.Text, "+build") {
+		p.note(c)
+		return
+	}
+	expr, err := checkLine(c.Text, pastCutoff)
+	if err != nil {
+		p.report(c, err.Error())
+		return
+	}
+	if expr != nil {
+		p.constraints.add(c, expr)
+		p.constrained = true
+	}
+}
+
+func (p *lineParser) report(c *ast.Comment, message string) {
+	p.problems = append(p.problems, Problem{Pos: c.Pos(), Message: message})
+}
+
+var (
+	errMisplacedGoBuild = errors.New("misplaced //go:build comment: it must appear before package clause and be followed by a blank line")
+	errExtraGoBuild     = errors.New("unexpected extra //go:build comment: a file must have at most one")
+	errSpacedGoBuild    = errors.New(`malformed //go:build comment: there must be no space between "//" and "go:build"`)
+)
+
+// checkLine checks a line that starts with "//" and contains "+build". It returns the constraint expression of
+// the line, if any.
+func checkLine(line string, pastCutoff bool) (constraint.Expr, error) {
+	text := strings.TrimPrefix(line, "//")
+	text = strings.TrimSpace(text)
+
+	if strings.HasPrefix(text, "+build") {
+		fields := strings.Fields(text)
 		if fields[0] != "+build" {
 			// Comment is something like +buildasdf not +build.
 			return nil, fmt.Errorf("possible malformed +build comment")
@@ -63,42 +198,35 @@ func checkLine(line string, pastCutoff bool) ([]string, error) {
 		if pastCutoff {
 			return nil, fmt.Errorf("+build comment must appear before package clause and be followed by a blank line")
 		}
-		argTags, err := checkArguments(fields)
-		if err != nil {
+		if err := checkArguments(fields); err != nil {
 			return nil, err
 		}
-		tags = append(tags, argTags...)
-	} else {
-		// Comment with +build but not at beginning.
-		if !pastCutoff {
-			return nil, fmt.Errorf("possible malformed +build comment")
+		if len(fields) == 1 {
+			return nil, nil
 		}
+		return parseLine(line)
+	}
+
+	// Comment with +build but not at beginning.
+	if !pastCutoff {
+		return nil, fmt.Errorf("possible malformed +build comment")
 	}
-	return tags, nil
+	return nil, nil
 }
 
-func checkArguments(fields []string) ([]string, error) {
-	tags := []string{}
+func checkArguments(fields []string) error {
 	for _, arg := range fields[1:] {
 		for _, elem := range strings.Split(arg, ",") {
 			if strings.HasPrefix(elem, "!!") {
-				return nil, fmt.Errorf("invalid double negative in build constraint: %s", arg)
-			}
-
-			isTagExcluded := strings.HasPrefix(elem, "!")
-			if isTagExcluded {
-				elem = elem[1:]
+				return fmt.Errorf("invalid double negative in build constraint: %s", arg)
 			}
+			elem = strings.TrimPrefix(elem, "!")
 			for _, c := range elem {
 				if !unicode.IsLetter(c) && !unicode.IsDigit(c) && c != '_' && c != '.' {
-					return nil, fmt.Errorf("invalid non-alphanumeric build constraint: %s", arg)
+					return fmt.Errorf("invalid non-alphanumeric build constraint: %s", arg)
 				}
 			}
-			if isTagExcluded {
-				continue
-			}
-			tags = append(tags, elem)
 		}
 	}
-	return tags, nil
+	return nil
 }
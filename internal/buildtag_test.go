@@ -0,0 +1,314 @@
+package internal
+
+import (
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ParseGoFile(t *testing.T) {
+	testCases := map[string]struct {
+		src      string
+		expected []string
+	}{
+		"valid constraint": {
+			src: "//go:build (foo || bar) && !baz\n\npackage foo\n",
+		},
+		"unbalanced parentheses": {
+			src:      "//go:build (foo || bar\n\npackage foo\n",
+			expected: []string{"invalid //go:build comment: missing close paren"},
+		},
+		"missing operand": {
+			src:      "//go:build foo &&\n\npackage foo\n",
+			expected: []string{"invalid //go:build comment: unexpected end of expression"},
+		},
+		"invalid operator": {
+			src:      "//go:build foo & bar\n\npackage foo\n",
+			expected: []string{"invalid //go:build comment: invalid syntax at &"},
+		},
+		"missing operator": {
+			src:      "//go:build foo bar\n\npackage foo\n",
+			expected: []string{"invalid //go:build comment: unexpected token bar"},
+		},
+		"double negative": {
+			src:      "//go:build !!foo\n\npackage foo\n",
+			expected: []string{"invalid //go:build comment: double negation not allowed"},
+		},
+		"extra line": {
+			src:      "//go:build foo\n//go:build bar\n\npackage foo\n",
+			expected: []string{"unexpected extra //go:build comment: a file must have at most one"},
+		},
+		"space after the slashes": {
+			src:      "// go:build foo\n\npackage foo\n",
+			expected: []string{`malformed //go:build comment: there must be no space between "//" and "go:build"`},
+		},
+		"misplaced line": {
+			src:      "package foo\n\n//go:build foo\n",
+			expected: []string{errMisplacedGoBuild.Error()},
+		},
+	}
+	for name, tt := range testCases {
+		t.Run(name, func(t *testing.T) {
+			f, err := parser.ParseFile(token.NewFileSet(), "foo.go", tt.src, parser.ParseComments)
+			require.NoError(t, err)
+
+			_, problems := ParseGoFile(f)
+			var found []string
+			for _, p := range problems {
+				found = append(found, p.Message)
+			}
+			assert.Equal(t, tt.expected, found)
+		})
+	}
+}
+
+func Test_ParseGoFile_constraintOnly(t *testing.T) {
+	testCases := map[string]struct {
+		src          string
+		expectedTags []string
+		expected     []string
+	}{
+		"go:build line": {
+			src:          "//go:build integration\n\npackage foo\n",
+			expectedTags: []string{"integration"},
+		},
+		"no trailing newline": {
+			src:          "//go:build integration\n\npackage foo",
+			expectedTags: []string{"integration"},
+		},
+		"plus build lines": {
+			src:          "// +build integration\n// +build linux\n\npackage foo",
+			expectedTags: []string{"integration", "linux"},
+		},
+		"CRLF line endings": {
+			src:          "//go:build integration\r\n\r\npackage foo\r\n",
+			expectedTags: []string{"integration"},
+		},
+		"package doc": {
+			src:          "//go:build integration\n\n// Package foo is gated.\npackage foo\n",
+			expectedTags: []string{"integration"},
+		},
+		"package clause adjoining the line": {
+			src:          "//go:build integration\npackage foo",
+			expectedTags: []string{},
+			expected:     []string{errMisplacedGoBuild.Error()},
+		},
+		"package clause only": {
+			src:          "package foo",
+			expectedTags: []string{},
+		},
+	}
+	for name, tt := range testCases {
+		t.Run(name, func(t *testing.T) {
+			f, err := parser.ParseFile(token.NewFileSet(), "foo.go", tt.src, parser.ParseComments)
+			require.NoError(t, err)
+			require.Empty(t, f.Decls)
+
+			constraints, problems := ParseGoFile(f)
+			var found []string
+			for _, p := range problems {
+				found = append(found, p.Message)
+			}
+			assert.Equal(t, tt.expected, found)
+			assert.Equal(t, tt.expectedTags, constraints.Tags())
+		})
+	}
+}
+
+func Test_ParseGoFile_whitespace(t *testing.T) {
+	testCases := map[string]struct {
+		src      string
+		expected []string
+	}{
+		"CRLF line endings": {
+			src:      "//go:build linux\r\n\r\npackage foo\r\n",
+			expected: []string{"linux"},
+		},
+		"tab and trailing carriage return": {
+			src:      "//go:build\t linux \r\n\npackage foo\n",
+			expected: []string{"linux"},
+		},
+		"multiple carriage returns": {
+			src:      "//go:build linux\r\r\n// +build linux\r\r\n\npackage foo\n",
+			expected: []string{"linux"},
+		},
+		"multiple spaces": {
+			src:      "//go:build   linux  &&   amd64\n\npackage foo\n",
+			expected: []string{"linux", "amd64"},
+		},
+		"tabs within the expression": {
+			src:      "//go:build\tlinux\t||\t(darwin\t&&\tamd64)\t\n\npackage foo\n",
+			expected: []string{"linux", "darwin", "amd64"},
+		},
+		"plus build lines with CRLF line endings": {
+			src:      "// +build linux  darwin\r\n// +build\tamd64 \r\n\r\npackage foo\r\n",
+			expected: []string{"linux", "darwin", "amd64"},
+		},
+		"both lines with CRLF line endings": {
+			src:      "//go:build linux && amd64\r\n// +build linux,amd64\r\n\r\npackage foo\r\n",
+			expected: []string{"linux", "amd64"},
+		},
+	}
+	for name, tt := range testCases {
+		t.Run(name, func(t *testing.T) {
+			f, err := parser.ParseFile(token.NewFileSet(), "foo.go", tt.src, parser.ParseComments)
+			require.NoError(t, err)
+
+			constraints, problems := ParseGoFile(f)
+			assert.Empty(t, problems)
+			assert.Equal(t, tt.expected, constraints.Tags())
+		})
+	}
+}
+
+func Test_ParseGoFile_plusBuildGroups(t *testing.T) {
+	testCases := map[string]struct {
+		src             string
+		expr            string
+		expectedTags    []string
+		expectedNegated []string
+	}{
+		"disjunction of conjunctions": {
+			src:             "// +build linux,386 darwin,!cgo\n\npackage foo\n",
+			expr:            "(linux && 386) || (darwin && !cgo)",
+			expectedTags:    []string{"linux", "386", "darwin"},
+			expectedNegated: []string{"cgo"},
+		},
+		"negated operand of each group": {
+			src:             "// +build !windows,amd64 !windows,arm64\n\npackage foo\n",
+			expr:            "(!windows && amd64) || (!windows && arm64)",
+			expectedTags:    []string{"amd64", "arm64"},
+			expectedNegated: []string{"windows"},
+		},
+		"groups of several lines": {
+			src:             "// +build linux,cgo darwin\n// +build amd64 arm64,!purego\n\npackage foo\n",
+			expr:            "((linux && cgo) || darwin) && (amd64 || (arm64 && !purego))",
+			expectedTags:    []string{"linux", "cgo", "darwin", "amd64", "arm64"},
+			expectedNegated: []string{"purego"},
+		},
+	}
+	for name, tt := range testCases {
+		t.Run(name, func(t *testing.T) {
+			f, err := parser.ParseFile(token.NewFileSet(), "foo.go", tt.src, parser.ParseComments)
+			require.NoError(t, err)
+
+			constraints, problems := ParseGoFile(f)
+			assert.Empty(t, problems)
+			require.NotNil(t, constraints.Expr)
+			assert.Equal(t, tt.expr, constraints.Expr.String())
+			assert.Equal(t, tt.expectedTags, constraints.Tags())
+			assert.Equal(t, tt.expectedNegated, constraints.NegatedTags())
+		})
+	}
+}
+
+func Test_ParseGoFile_rationale(t *testing.T) {
+	testCases := map[string]struct {
+		src      string
+		expected string
+	}{
+		"line before the constraint": {
+			src:      "// Requires docker.\n//go:build integration\n\npackage foo\n",
+			expected: "Requires docker.",
+		},
+		"lines after the constraints": {
+			src:      "//go:build integration\n// +build integration\n// Requires docker\n// and a database.\n\npackage foo\n",
+			expected: "Requires docker and a database.",
+		},
+		"separate comment": {
+			src:      "// Copyright.\n\n//go:build integration\n\npackage foo\n",
+			expected: "",
+		},
+		"directive": {
+			src:      "//go:build integration\n//go:generate stringer\n\npackage foo\n",
+			expected: "",
+		},
+		"empty comment": {
+			src:      "//\n//go:build integration\n\npackage foo\n",
+			expected: "",
+		},
+		"no constraint": {
+			src:      "// Package foo.\npackage foo\n",
+			expected: "",
+		},
+	}
+	for name, tt := range testCases {
+		t.Run(name, func(t *testing.T) {
+			f, err := parser.ParseFile(token.NewFileSet(), "foo.go", tt.src, parser.ParseComments)
+			require.NoError(t, err)
+
+			constraints, problems := ParseGoFile(f)
+			assert.Empty(t, problems)
+			assert.Equal(t, tt.expected, constraints.Rationale)
+		})
+	}
+}
+
+func Test_ParseOtherFile(t *testing.T) {
+	testCases := map[string]struct {
+		src               string
+		expectedTags      []string
+		expectedLine      int
+		expectedProblems  []string
+		expectedRationale string
+	}{
+		"go:build line": {
+			src:          "// Copyright.\n\n//go:build amd64 && !purego\n\n#include \"textflag.h\"\n",
+			expectedTags: []string{"amd64"},
+			expectedLine: 3,
+		},
+		"plus build lines": {
+			src:          "// +build linux\n// +build amd64\n\nTEXT ·foo(SB),0,$0\n",
+			expectedTags: []string{"linux", "amd64"},
+			expectedLine: 1,
+		},
+		"rationale line": {
+			src:               "// Copyright.\n\n//go:build amd64\n// Uses AVX2.\n\n#include \"textflag.h\"\n",
+			expectedTags:      []string{"amd64"},
+			expectedLine:      3,
+			expectedRationale: "Uses AVX2.",
+		},
+		"line after the header": {
+			src:          "#include \"textflag.h\"\n\n//go:build amd64\n",
+			expectedTags: []string{},
+		},
+		"extra line": {
+			src:              "//go:build amd64\n//go:build arm64\n",
+			expectedTags:     []string{"amd64"},
+			expectedLine:     1,
+			expectedProblems: []string{"unexpected extra //go:build comment: a file must have at most one"},
+		},
+		"CRLF line endings": {
+			src:          "// Copyright.\r\n\r\n//go:build\t amd64 \r\n// +build amd64\r\n\r\n#include \"textflag.h\"\r\n",
+			expectedTags: []string{"amd64"},
+			expectedLine: 3,
+		},
+		"invalid line": {
+			src:              "//go:build amd64 &&\n",
+			expectedTags:     []string{},
+			expectedProblems: []string{"invalid //go:build comment: unexpected end of expression"},
+		},
+	}
+	for name, tt := range testCases {
+		t.Run(name, func(t *testing.T) {
+			fset := token.NewFileSet()
+			tf := fset.AddFile("foo_amd64.s", -1, len(tt.src))
+			tf.SetLinesForContent([]byte(tt.src))
+
+			constraints, problems := ParseOtherFile(tf, []byte(tt.src))
+			var found []string
+			for _, p := range problems {
+				found = append(found, p.Message)
+			}
+			assert.Equal(t, tt.expectedProblems, found)
+			assert.Equal(t, tt.expectedTags, constraints.Tags())
+			assert.Equal(t, tt.expectedRationale, constraints.Rationale)
+			if tt.expectedLine > 0 {
+				assert.Equal(t, tt.expectedLine, fset.Position(constraints.Pos).Line)
+			}
+		})
+	}
+}
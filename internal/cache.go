@@ -0,0 +1,38 @@
+package internal
+
+import (
+	"go/build/constraint"
+	"sync"
+	"sync/atomic"
+)
+
+// maxParsedLines is the maximum number of constraint lines cached by parseLine, which bounds the memory used by the
+// cache when linting huge code bases.
+const maxParsedLines = 4096
+
+// parsedLines caches the result of parsing the constraint lines, as most of the files of a module usually share a
+// few identical lines. Packages can be analysed concurrently, hence the sync.Map.
+var (
+	parsedLines      sync.Map
+	parsedLinesCount atomic.Int64
+)
+
+type parsedLine struct {
+	expr constraint.Expr
+	err  error
+}
+
+// parseLine is constraint.Parse, memoized on the line. The returned expression is shared, so it must not be modified.
+func parseLine(line string) (constraint.Expr, error) {
+	if v, ok := parsedLines.Load(line); ok {
+		p := v.(parsedLine)
+		return p.expr, p.err
+	}
+	expr, err := constraint.Parse(line)
+	if parsedLinesCount.Load() < maxParsedLines {
+		if _, loaded := parsedLines.LoadOrStore(line, parsedLine{expr: expr, err: err}); !loaded {
+			parsedLinesCount.Add(1)
+		}
+	}
+	return expr, err
+}
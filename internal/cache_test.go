@@ -0,0 +1,47 @@
+package internal
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build/constraint"
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+func Benchmark_parseLine(b *testing.B) {
+	const line = "//go:build (linux && amd64) || (darwin && !cgo) || integration"
+	b.Run("uncached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := constraint.Parse(line); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("cached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := parseLine(line); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkParseGoFile(b *testing.B) {
+	fset := token.NewFileSet()
+	files := make([]*ast.File, 0, 100)
+	for i := 0; i < cap(files); i++ {
+		src := "//go:build (linux && amd64) || integration\n// +build linux,amd64 integration\n\npackage foo\n"
+		f, err := parser.ParseFile(fset, fmt.Sprintf("foo%d.go", i), src, parser.ParseComments)
+		if err != nil {
+			b.Fatal(err)
+		}
+		files = append(files, f)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, f := range files {
+			ParseGoFile(f)
+		}
+	}
+}
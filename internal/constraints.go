@@ -0,0 +1,283 @@
+package internal
+
+import (
+	"go/ast"
+	"go/build/constraint"
+	"go/token"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Constraints are the build constraints of a Go file.
+type Constraints struct {
+	// Expr is the combination of all the build constraint lines of the file, or nil when the file has none.
+	Expr constraint.Expr
+	// Pos is the position of the first build constraint line of the file, or token.NoPos when the file has none.
+	Pos token.Pos
+	// Comments are the build constraint lines of the file, in order of appearance.
+	Comments []*ast.Comment
+	// GoBuild is the expression of the "//go:build" line of the file, or nil when it has none.
+	GoBuild constraint.Expr
+	// PlusBuild is the combination of the "// +build" lines of the file, or nil when it has none.
+	PlusBuild constraint.Expr
+	// Rationale is the text of the other comment lines adjoining the build constraint lines, such as "Requires
+	// docker." for a "// Requires docker." line right before or after "//go:build integration", or empty when there
+	// are none. Trailing comments are not part of the constraint lines, as the Go toolchain rejects them.
+	Rationale string
+	// Ignored are the tags considered absent from the constraints, whether they are referenced or not. They are
+	// left out of the tags, while the expressions are kept as is.
+	Ignored []string
+}
+
+// add combines the expression of the comment with the existing ones. Lines are combined using a logical AND,
+// which is how the Go toolchain evaluates several "// +build" lines.
+func (c *Constraints) add(comment *ast.Comment, expr constraint.Expr) {
+	c.Comments = append(c.Comments, comment)
+	if constraint.IsGoBuild(comment.Text) {
+		c.GoBuild = and(c.GoBuild, expr)
+	} else {
+		c.PlusBuild = and(c.PlusBuild, expr)
+	}
+	if c.Expr == nil {
+		c.Pos = comment.Pos()
+	}
+	c.Expr = and(c.Expr, expr)
+}
+
+// and returns the combination of the expressions using a logical AND, x being nil for the first expression.
+func and(x, y constraint.Expr) constraint.Expr {
+	if x == nil {
+		return y
+	}
+	return &constraint.AndExpr{X: x, Y: y}
+}
+
+// HasPlusBuild reports whether the constraints contain legacy "// +build" lines.
+func (c Constraints) HasPlusBuild() bool {
+	for _, comment := range c.Comments {
+		if constraint.IsPlusBuild(comment.Text) {
+			return true
+		}
+	}
+	return false
+}
+
+// Has reports whether the tag is referenced by the constraints without being negated. For example, "linux" is
+// present in both "linux && amd64" and "linux || darwin", but not in "!linux", while it is in "!(!linux)".
+func (c Constraints) Has(tag string) bool {
+	for _, t := range c.Tags() {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// HasFold is like Has, but compares tags using Unicode case-folding.
+func (c Constraints) HasFold(tag string) bool {
+	for _, t := range c.Tags() {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// Tags returns the list of tags referenced by the constraints without being negated, in order of appearance
+// and without duplicates. A tag negated twice, such as in "!(!linux)", is not negated.
+func (c Constraints) Tags() []string {
+	return c.unignored(uniqueTags(tagRefs(c.Expr, false, false)))
+}
+
+// NegatedTags returns the list of tags referenced by the constraints in a negated sense, in order of appearance and
+// without duplicates, such as "windows" for both "!windows" and "!(windows && amd64)". A tag can be both negated and
+// not, such as in "linux || !linux".
+func (c Constraints) NegatedTags() []string {
+	return c.unignored(uniqueTags(tagRefs(c.Expr, false, true)))
+}
+
+// DuplicateTags returns the tags referenced more than once by the effective build constraints, in order of appearance
+// and whatever their polarity, such as "linux" for both "linux && linux" and "linux || !linux". The "//go:build" line
+// is the effective one when the file has both forms, as its "// +build" lines are expected to repeat its tags.
+func (c Constraints) DuplicateTags() []string {
+	expr := c.GoBuild
+	if expr == nil {
+		expr = c.PlusBuild
+	}
+	refs := allTagRefs(expr)
+	count := make(map[string]int)
+	for _, tag := range refs {
+		count[tag]++
+	}
+	duplicates := []string{}
+	for _, tag := range c.unignored(uniqueTags(refs)) {
+		if count[tag] > 1 {
+			duplicates = append(duplicates, tag)
+		}
+	}
+	return duplicates
+}
+
+// unignored returns the tags which are not ignored, keeping their order.
+func (c Constraints) unignored(tags []string) []string {
+	if len(c.Ignored) == 0 {
+		return tags
+	}
+	kept := []string{}
+	for _, tag := range tags {
+		if !slices.Contains(c.Ignored, tag) {
+			kept = append(kept, tag)
+		}
+	}
+	return kept
+}
+
+// tagRefs returns the tags of an expression whose references are negated, or not, as wanted. The expression is
+// itself negated when negated is true.
+func tagRefs(expr constraint.Expr, negated, wantNegated bool) []string {
+	switch e := expr.(type) {
+	case *constraint.TagExpr:
+		if negated == wantNegated {
+			return []string{e.Tag}
+		}
+	case *constraint.NotExpr:
+		return tagRefs(e.X, !negated, wantNegated)
+	case *constraint.AndExpr:
+		return append(tagRefs(e.X, negated, wantNegated), tagRefs(e.Y, negated, wantNegated)...)
+	case *constraint.OrExpr:
+		return append(tagRefs(e.X, negated, wantNegated), tagRefs(e.Y, negated, wantNegated)...)
+	}
+	return nil
+}
+
+// allTagRefs returns the tags of an expression in order of appearance, whether their references are negated or not.
+func allTagRefs(expr constraint.Expr) []string {
+	switch e := expr.(type) {
+	case *constraint.TagExpr:
+		return []string{e.Tag}
+	case *constraint.NotExpr:
+		return allTagRefs(e.X)
+	case *constraint.AndExpr:
+		return append(allTagRefs(e.X), allTagRefs(e.Y)...)
+	case *constraint.OrExpr:
+		return append(allTagRefs(e.X), allTagRefs(e.Y)...)
+	}
+	return nil
+}
+
+// uniqueTags returns the tags without duplicates, keeping their order.
+func uniqueTags(refs []string) []string {
+	tags := []string{}
+	seen := make(map[string]bool)
+	for _, tag := range refs {
+		if seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// Canonical returns the canonical form of an expression, so that equivalent expressions only differing by the order
+// or the repetition of their operands have the same canonical form. For example, the canonical form of both
+// "linux && amd64" and "amd64 && linux && amd64" is "amd64 && linux". The canonical form of a nil expression is
+// the empty string.
+func Canonical(expr constraint.Expr) string {
+	switch e := expr.(type) {
+	case *constraint.TagExpr:
+		return e.Tag
+	case *constraint.NotExpr:
+		return "!" + canonicalOperand(e.X)
+	case *constraint.AndExpr:
+		return canonicalOperands(expr, " && ")
+	case *constraint.OrExpr:
+		return canonicalOperands(expr, " || ")
+	}
+	return ""
+}
+
+// canonicalOperands returns the canonical form of a chain of AND or OR expressions: its sorted and deduplicated
+// operands, joined by the operator.
+func canonicalOperands(expr constraint.Expr, op string) string {
+	var operands []string
+	seen := make(map[string]bool)
+	for _, operand := range flatten(expr) {
+		canonical := canonicalOperand(operand)
+		if seen[canonical] {
+			continue
+		}
+		seen[canonical] = true
+		operands = append(operands, canonical)
+	}
+	sort.Strings(operands)
+	return strings.Join(operands, op)
+}
+
+// canonicalOperand returns the canonical form of an operand, wrapped in parentheses unless it is a tag or a negation.
+func canonicalOperand(expr constraint.Expr) string {
+	switch expr.(type) {
+	case *constraint.AndExpr, *constraint.OrExpr:
+		return "(" + Canonical(expr) + ")"
+	}
+	return Canonical(expr)
+}
+
+// Sorted returns the expression with the operands of each chain of AND or OR expressions sorted like the ones of the
+// canonical form: in lexical order of their canonical forms, compared byte per byte, so that "amd64 && linux" is
+// sorted while "linux && amd64" is not, and that negations and parenthesized operands come before the tags, such as
+// in "!cgo && (darwin || linux) && amd64". Unlike the canonical form, the repeated operands are kept. It also reports
+// whether the expression was already sorted, a chain such as "a && (b && c)" being sorted as it reads.
+func Sorted(expr constraint.Expr) (constraint.Expr, bool) {
+	switch e := expr.(type) {
+	case *constraint.NotExpr:
+		x, sorted := Sorted(e.X)
+		return &constraint.NotExpr{X: x}, sorted
+	case *constraint.AndExpr, *constraint.OrExpr:
+		operands := flatten(expr)
+		keys := make([]string, len(operands))
+		sorted := true
+		for i, operand := range operands {
+			var ok bool
+			operands[i], ok = Sorted(operand)
+			sorted = sorted && ok
+			keys[i] = canonicalOperand(operands[i])
+		}
+		if !sort.StringsAreSorted(keys) {
+			sorted = false
+			sort.SliceStable(operands, func(i, j int) bool {
+				return canonicalOperand(operands[i]) < canonicalOperand(operands[j])
+			})
+		}
+		chain := operands[0]
+		for _, operand := range operands[1:] {
+			if _, ok := expr.(*constraint.AndExpr); ok {
+				chain = &constraint.AndExpr{X: chain, Y: operand}
+			} else {
+				chain = &constraint.OrExpr{X: chain, Y: operand}
+			}
+		}
+		return chain, sorted
+	}
+	return expr, true
+}
+
+// flatten returns the operands of a chain of expressions of the same kind, such as "a && (b && c)".
+func flatten(expr constraint.Expr) []constraint.Expr {
+	switch e := expr.(type) {
+	case *constraint.AndExpr:
+		return append(flattenAs[*constraint.AndExpr](e.X), flattenAs[*constraint.AndExpr](e.Y)...)
+	case *constraint.OrExpr:
+		return append(flattenAs[*constraint.OrExpr](e.X), flattenAs[*constraint.OrExpr](e.Y)...)
+	}
+	return []constraint.Expr{expr}
+}
+
+// flattenAs flattens the expression if it is of type T, or returns it as is otherwise.
+func flattenAs[T constraint.Expr](expr constraint.Expr) []constraint.Expr {
+	if _, ok := expr.(T); ok {
+		return flatten(expr)
+	}
+	return []constraint.Expr{expr}
+}
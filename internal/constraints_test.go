@@ -0,0 +1,123 @@
+package internal
+
+import (
+	"go/ast"
+	"go/build/constraint"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Constraints_Tags(t *testing.T) {
+	testCases := map[string]struct {
+		expected        []string
+		expectedNegated []string
+	}{
+		"windows":                     {expected: []string{"windows"}, expectedNegated: []string{}},
+		"!windows":                    {expected: []string{}, expectedNegated: []string{"windows"}},
+		"!(!windows)":                 {expected: []string{"windows"}, expectedNegated: []string{}},
+		"!(windows && amd64)":         {expected: []string{}, expectedNegated: []string{"windows", "amd64"}},
+		"!(!windows || linux)":        {expected: []string{"windows"}, expectedNegated: []string{"linux"}},
+		"linux || !linux":             {expected: []string{"linux"}, expectedNegated: []string{"linux"}},
+		"(linux && amd64) || !cgo":    {expected: []string{"linux", "amd64"}, expectedNegated: []string{"cgo"}},
+		"!windows && !windows && cgo": {expected: []string{"cgo"}, expectedNegated: []string{"windows"}},
+	}
+	for line, tt := range testCases {
+		t.Run(line, func(t *testing.T) {
+			expr, err := constraint.Parse("//go:build " + line)
+			require.NoError(t, err)
+			constraints := Constraints{Expr: expr}
+			assert.Equal(t, tt.expected, constraints.Tags())
+			assert.Equal(t, tt.expectedNegated, constraints.NegatedTags())
+		})
+	}
+}
+
+func Test_Constraints_Has(t *testing.T) {
+	testCases := map[string]bool{
+		"windows":              true,
+		"!windows":             false,
+		"!(!windows)":          true,
+		"!(windows && amd64)":  false,
+		"windows || !windows":  true,
+		"!(!windows && linux)": true,
+	}
+	for line, expected := range testCases {
+		t.Run(line, func(t *testing.T) {
+			expr, err := constraint.Parse("//go:build " + line)
+			require.NoError(t, err)
+			assert.Equal(t, expected, Constraints{Expr: expr}.Has("windows"))
+		})
+	}
+}
+
+func Test_Constraints_Ignored(t *testing.T) {
+	expr, err := constraint.Parse("//go:build (legacy && linux) || !legacy || !cgo")
+	require.NoError(t, err)
+	constraints := Constraints{Expr: expr, Ignored: []string{"legacy"}}
+	assert.Equal(t, []string{"linux"}, constraints.Tags())
+	assert.Equal(t, []string{"cgo"}, constraints.NegatedTags())
+	assert.False(t, constraints.Has("legacy"))
+	assert.False(t, constraints.HasFold("LEGACY"))
+	assert.True(t, constraints.Has("linux"))
+	assert.Equal(t, expr, constraints.Expr)
+}
+
+func Test_Sorted(t *testing.T) {
+	testCases := map[string]struct {
+		expected string
+		sorted   bool
+	}{
+		"linux":                            {expected: "linux", sorted: true},
+		"amd64 && linux":                   {expected: "amd64 && linux", sorted: true},
+		"linux && amd64":                   {expected: "amd64 && linux", sorted: false},
+		"linux && amd64 && linux":          {expected: "amd64 && linux && linux", sorted: false},
+		"a && (b && c)":                    {expected: "a && b && c", sorted: true},
+		"c && (b && a)":                    {expected: "a && b && c", sorted: false},
+		"linux || darwin":                  {expected: "darwin || linux", sorted: false},
+		"amd64 && !cgo":                    {expected: "!cgo && amd64", sorted: false},
+		"amd64 && (linux || darwin)":       {expected: "(darwin || linux) && amd64", sorted: false},
+		"(darwin || linux) && amd64":       {expected: "(darwin || linux) && amd64", sorted: true},
+		"!(linux && amd64)":                {expected: "!(amd64 && linux)", sorted: false},
+		"(b && a) || c":                    {expected: "(a && b) || c", sorted: false},
+		"(a || b) && (a || c) && (b || a)": {expected: "(a || b) && (a || b) && (a || c)", sorted: false},
+	}
+	for line, tt := range testCases {
+		t.Run(line, func(t *testing.T) {
+			expr, err := constraint.Parse("//go:build " + line)
+			require.NoError(t, err)
+			sorted, ok := Sorted(expr)
+			assert.Equal(t, tt.sorted, ok)
+			assert.Equal(t, tt.expected, sorted.String())
+		})
+	}
+}
+
+func Test_Constraints_DuplicateTags(t *testing.T) {
+	testCases := map[string]struct {
+		lines    []string
+		expected []string
+	}{
+		"no duplicate":                {lines: []string{"//go:build linux && amd64"}, expected: []string{}},
+		"repeated tag":                {lines: []string{"//go:build linux && amd64 && linux"}, expected: []string{"linux"}},
+		"negated repetition":          {lines: []string{"//go:build linux || !linux"}, expected: []string{"linux"}},
+		"several duplicates in order": {lines: []string{"//go:build (cgo || amd64) && (amd64 || cgo)"}, expected: []string{"cgo", "amd64"}},
+		"repeated plus build lines":   {lines: []string{"// +build linux", "// +build linux,cgo"}, expected: []string{"linux"}},
+		"plus build repeating go:build": {
+			lines:    []string{"//go:build linux && cgo", "// +build linux,cgo"},
+			expected: []string{},
+		},
+	}
+	for name, tt := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var constraints Constraints
+			for _, line := range tt.lines {
+				expr, err := constraint.Parse(line)
+				require.NoError(t, err)
+				constraints.add(&ast.Comment{Text: line}, expr)
+			}
+			assert.Equal(t, tt.expected, constraints.DuplicateTags())
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package internal
+
+import (
+	"go/build"
+	"slices"
+)
+
+// UnixTag is the build tag satisfied by the Unix-like GOOS values, since Go 1.19.
+const UnixTag = "unix"
+
+// unixOS are the GOOS values satisfying the UnixTag, as listed by the go/build package.
+var unixOS = map[string]bool{
+	"aix": true, "android": true, "darwin": true, "dragonfly": true, "freebsd": true, "hurd": true, "illumos": true,
+	"ios": true, "linux": true, "netbsd": true, "openbsd": true, "solaris": true,
+}
+
+// IsUnixOS reports whether the GOOS satisfies the UnixTag.
+func IsUnixOS(goos string) bool {
+	return unixOS[goos]
+}
+
+// MatchContext reports whether a file is included in the builds using the context, evaluating both its build
+// constraints and the constraint implied by its name, such as "linux" for "foo_linux.go".
+func MatchContext(ctxt *build.Context, filename string, constraints Constraints) bool {
+	match := func(tag string) bool { return matchTag(ctxt, tag) }
+	if implicit := ImplicitConstraint(filename); implicit != nil && !implicit.Eval(match) {
+		return false
+	}
+	return constraints.Expr == nil || constraints.Expr.Eval(match)
+}
+
+// matchTag reports whether the tag is satisfied by the context, using the same rules as the go/build package.
+func matchTag(ctxt *build.Context, tag string) bool {
+	switch {
+	case tag == ctxt.GOOS, tag == ctxt.GOARCH, tag == ctxt.Compiler, ctxt.CgoEnabled && tag == "cgo":
+		return true
+	case tag == "linux" && ctxt.GOOS == "android",
+		tag == "solaris" && ctxt.GOOS == "illumos",
+		tag == "darwin" && ctxt.GOOS == "ios",
+		tag == UnixTag && unixOS[ctxt.GOOS]:
+		return true
+	}
+	return slices.Contains(ctxt.BuildTags, tag) || slices.Contains(ctxt.ToolTags, tag) ||
+		slices.Contains(ctxt.ReleaseTags, tag)
+}
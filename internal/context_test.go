@@ -0,0 +1,80 @@
+package internal
+
+import (
+	"go/build"
+	"go/build/constraint"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_MatchContext(t *testing.T) {
+	ctxt := &build.Context{
+		GOOS:        "android",
+		GOARCH:      "arm64",
+		Compiler:    "gc",
+		CgoEnabled:  true,
+		BuildTags:   []string{"integration"},
+		ReleaseTags: []string{"go1.21", "go1.22"},
+	}
+	testCases := map[string]struct {
+		filename   string
+		constraint string
+		expected   bool
+	}{
+		"no constraints": {
+			filename: "foo.go",
+			expected: true,
+		},
+		"build tag": {
+			filename:   "foo.go",
+			constraint: "//go:build integration && !e2e",
+			expected:   true,
+		},
+		"missing build tag": {
+			filename:   "foo.go",
+			constraint: "//go:build e2e",
+			expected:   false,
+		},
+		"GOOS, GOARCH and cgo": {
+			filename:   "foo.go",
+			constraint: "//go:build android && arm64 && cgo && gc",
+			expected:   true,
+		},
+		"GOOS implied by the GOOS": {
+			filename:   "foo.go",
+			constraint: "//go:build linux && unix",
+			expected:   true,
+		},
+		"release tag": {
+			filename:   "foo.go",
+			constraint: "//go:build go1.22 && !go1.23",
+			expected:   true,
+		},
+		"file name of another GOOS": {
+			filename: "foo_windows.go",
+			expected: false,
+		},
+		"file name of an implied GOOS": {
+			filename: "foo_linux_test.go",
+			expected: true,
+		},
+		"file name of another GOARCH": {
+			filename:   "foo_amd64.go",
+			constraint: "//go:build integration",
+			expected:   false,
+		},
+	}
+	for name, tt := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var constraints Constraints
+			if tt.constraint != "" {
+				expr, err := constraint.Parse(tt.constraint)
+				require.NoError(t, err)
+				constraints.Expr = expr
+			}
+			assert.Equal(t, tt.expected, MatchContext(ctxt, tt.filename, constraints))
+		})
+	}
+}
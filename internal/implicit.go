@@ -0,0 +1,67 @@
+package internal
+
+import (
+	"go/build/constraint"
+	"sort"
+	"strings"
+)
+
+// knownOS and knownArch are the GOOS and GOARCH values the Go toolchain recognizes in file names, as listed by the
+// go/build package.
+var (
+	knownOS = map[string]bool{
+		"aix": true, "android": true, "darwin": true, "dragonfly": true, "freebsd": true, "hurd": true,
+		"illumos": true, "ios": true, "js": true, "linux": true, "nacl": true, "netbsd": true, "openbsd": true,
+		"plan9": true, "solaris": true, "wasip1": true, "windows": true, "zos": true,
+	}
+	knownArch = map[string]bool{
+		"386": true, "amd64": true, "amd64p32": true, "arm": true, "armbe": true, "arm64": true, "arm64be": true,
+		"loong64": true, "mips": true, "mipsle": true, "mips64": true, "mips64le": true, "mips64p32": true,
+		"mips64p32le": true, "ppc": true, "ppc64": true, "ppc64le": true, "riscv": true, "riscv64": true,
+		"s390": true, "s390x": true, "sparc": true, "sparc64": true, "wasm": true,
+	}
+)
+
+// KnownOS returns the GOOS values the Go toolchain recognizes in file names, sorted.
+func KnownOS() []string {
+	return sortedKeys(knownOS)
+}
+
+// KnownArch returns the GOARCH values the Go toolchain recognizes in file names, sorted.
+func KnownArch() []string {
+	return sortedKeys(knownArch)
+}
+
+// sortedKeys returns the keys of the set, sorted.
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ImplicitConstraint returns the build constraint implied by the name of a Go file, or nil when it has none. Like the
+// Go toolchain, files named "*_GOOS", "*_GOARCH" or "*_GOOS_GOARCH", optionally followed by "_test", are only built
+// for the given GOOS and GOARCH, such as "linux && amd64" for "foo_linux_amd64_test.go".
+func ImplicitConstraint(filename string) constraint.Expr {
+	name, _, _ := strings.Cut(filename, ".")
+	i := strings.Index(name, "_")
+	if i < 0 {
+		return nil
+	}
+	// The part before the first "_" is ignored, so that "linux.go" is not constrained.
+	parts := strings.Split(name[i:], "_")
+	if n := len(parts); n > 0 && parts[n-1] == "test" {
+		parts = parts[:n-1]
+	}
+	n := len(parts)
+	switch {
+	case n >= 2 && knownOS[parts[n-2]] && knownArch[parts[n-1]]:
+		return &constraint.AndExpr{X: &constraint.TagExpr{Tag: parts[n-2]}, Y: &constraint.TagExpr{Tag: parts[n-1]}}
+	case n >= 1 && (knownOS[parts[n-1]] || knownArch[parts[n-1]]):
+		return &constraint.TagExpr{Tag: parts[n-1]}
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package internal
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ImplicitConstraint(t *testing.T) {
+	testCases := map[string]string{
+		"foo.go":                    "",
+		"linux.go":                  "",
+		"foo_linux.go":              "linux",
+		"foo_amd64.go":              "amd64",
+		"foo_linux_amd64.go":        "amd64 && linux",
+		"foo_linux_test.go":         "linux",
+		"foo_windows_arm64_test.go": "arm64 && windows",
+		"foo_amd64_linux.go":        "linux",
+		"foo_bar.go":                "",
+		"foo_linux.pb.go":           "linux",
+	}
+	for filename, expected := range testCases {
+		t.Run(filename, func(t *testing.T) {
+			assert.Equal(t, expected, Canonical(ImplicitConstraint(filename)))
+		})
+	}
+}
+
+func Test_KnownOS(t *testing.T) {
+	os := KnownOS()
+	assert.Contains(t, os, "linux")
+	assert.NotContains(t, os, "amd64")
+	assert.True(t, sort.StringsAreSorted(os))
+	for _, goos := range os {
+		assert.Equal(t, goos, Canonical(ImplicitConstraint("foo_"+goos+".go")))
+	}
+}
+
+func Test_KnownArch(t *testing.T) {
+	arch := KnownArch()
+	assert.Contains(t, arch, "amd64")
+	assert.NotContains(t, arch, "linux")
+	assert.True(t, sort.StringsAreSorted(arch))
+}
@@ -2,11 +2,17 @@
 package filebuildtag
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"go/ast"
+	"go/token"
+	"os"
+	"path"
 	"path/filepath"
+	"reflect"
 	"strings"
+	"sync"
 
 	"github.com/aziule/filebuildtag/internal"
 	"golang.org/x/tools/go/analysis"
@@ -15,6 +21,8 @@ import (
 )
 
 const (
+	// Name of the linter, also used in "//nolint" directives.
+	Name = "filebuildtag"
 	// Doc of the linter.
 	Doc = `ensure Go files have the expected "// +build <tag>" instruction based on the file name
 
@@ -26,85 +34,369 @@ Bind file names to their expected build tags, such as:
 	// FlagFiletagsDoc is the usage doc of the default filetags flag. It is exported to be reused from linters runners.
 	FlagFiletagsDoc = `Comma-separated list of file names and build tags using the form "pattern:tag". For example:
 - Single pattern: "*foo.go:tag1"
-- Multiple patterns: "*foo.go:tag1,*foo2.go:tag2"`
+- Multiple patterns: "*foo.go:tag1,*foo2.go:tag2"
+- Multiple tags: "*foo.go:tag1+tag2"
+- Forbidden tag: "*foo.go:!tag1"
+- Exactly one, at least one or all the tags of a group: "*_env.go:oneof(dev,prod),*_os.go:anyof(linux,darwin),*_all.go:allof(tag1,tag2)"
+- Regular expression: "re:.*_v[0-9]+\.go:tag1"
+- Tag captured by a regular expression: "re:service_(.+)_integration_test\.go:$1"
+- Pattern alternatives: "{*_a.go|*_b.go}:tag1"
+- Negated pattern parts: "*.go&!*_generated.go:tag1"
+- Path relative to the module root: "internal/legacy/*.go:tag1"
+- Any number of directories: "internal/**/legacy_*.go:tag1"
+- Package name: "pkg:integrationtest:tag1"
+- Directory and its nested directories: "dir:internal/legacy:legacy"
+- Directory name: "dirname:*_integration:integration"
+- Imported package: "imports:database/sql:db"
+- Line count: "lines>500:large"
+- Files having a build tag: "tag:integration=>slow"
+- Test files only, or non-test files only: "test:*.go:tag1,nontest:*.go:!tag1"
+- Excluded files: "*_test.go:tag1,!*_mock_test.go"
+- Build constraint expression: "*_linux_amd64.go:linux && amd64"
+- Escaped colons and commas: "foo\:bar.go:legacy"
+- Environment variables: "*_test.go:${TEAM}_unit"
+- Severity, "error" by default: "*_test.go:unit+fast@warning"`
+	// FlagFiletagsConfigName is the name of the filetags config flag. It is exported to be reused from linters runners.
+	FlagFiletagsConfigName = "filetags-config"
+	// FlagFiletagsConfigDoc is the usage doc of the filetags config flag. It is exported to be reused from linters runners.
+	FlagFiletagsConfigDoc = `Path to a YAML or JSON file binding file patterns to build tags, merged with the filetags flag. For example:
+filetags:
+  "*foo.go": tag1
+  "*foo2.go": [tag2, "!tag3"]
+exclude:
+  - "*_mock.go"`
+	// FlagProfileName is the name of the profile flag. It is exported to be reused from linters runners.
+	FlagProfileName = "profile"
+	// FlagProfileDoc is the usage doc of the profile flag. It is exported to be reused from linters runners.
+	FlagProfileDoc = `Name of the profile of the filetags-config file whose rules apply along with its other rules, such as "strict"`
+	// FlagExcludeName is the name of the exclude flag. It is exported to be reused from linters runners.
+	FlagExcludeName = "exclude"
+	// FlagExcludeDoc is the usage doc of the exclude flag. It is exported to be reused from linters runners.
+	FlagExcludeDoc = `Comma-separated list of patterns of the files to skip entirely, such as "*.pb.go,*_mock.go"`
+	// FlagIgnoreFileName is the name of the ignore-file flag. It is exported to be reused from linters runners.
+	FlagIgnoreFileName = "ignore-file"
+	// FlagIgnoreFileDoc is the usage doc of the ignore-file flag. It is exported to be reused from linters runners.
+	FlagIgnoreFileDoc = `Path of a file listing the patterns of the files to skip, one per line, matched against the file names or, when ending with "/", against the directories the files are located in, such as "testdata/"`
+	// FlagRequireConstraintName is the name of the require-constraint flag. It is exported to be reused from linters runners.
+	FlagRequireConstraintName = "require-constraint"
+	// FlagRequireConstraintDoc is the usage doc of the require-constraint flag. It is exported to be reused from linters runners.
+	FlagRequireConstraintDoc = `Comma-separated list of patterns of the files which must have a build constraint, whatever its tags, such as "internal/crypto/*.go"`
+	// FlagPlatformGroupName is the name of the platform-group flag. It is exported to be reused from linters runners.
+	FlagPlatformGroupName = "platform-group"
+	// FlagPlatformGroupDoc is the usage doc of the platform-group flag. It is exported to be reused from linters runners.
+	FlagPlatformGroupDoc = `Comma-separated list of patterns of the groups of files of which exactly one file must build for each platform of the platforms flag, such as "foo_*.go"`
+	// FlagPlatformsName is the name of the platforms flag. It is exported to be reused from linters runners.
+	FlagPlatformsName = "platforms"
+	// FlagPlatformsDoc is the usage doc of the platforms flag. It is exported to be reused from linters runners.
+	FlagPlatformsDoc = `Comma-separated list of the platforms the platform groups must build for, of the form "goos" or "goos/goarch", such as "linux,darwin,windows/amd64"`
+	// FlagOnlyName is the name of the only flag. It is exported to be reused from linters runners.
+	FlagOnlyName = "only"
+	// FlagOnlyDoc is the usage doc of the only flag. It is exported to be reused from linters runners.
+	FlagOnlyDoc = `Comma-separated list of the only files to report, such as the files changed by a pull request, or "@path" to read them from a file listing one file per line. Files containing a "/" are relative to the root of the module`
+	// FlagRequireConfigName is the name of the require-config flag. It is exported to be reused from linters runners.
+	FlagRequireConfigName = "require-config"
+	// FlagRequireConfigDoc is the usage doc of the require-config flag. It is exported to be reused from linters runners.
+	FlagRequireConfigDoc = `Fail when no filetags are configured, neither by the filetags and filetags-config flags nor by a directory config, rather than checking nothing`
+	// FlagDefaultTagName is the name of the default-tag flag. It is exported to be reused from linters runners.
+	FlagDefaultTagName = "default-tag"
+	// FlagDefaultTagDoc is the usage doc of the default-tag flag. It is exported to be reused from linters runners.
+	FlagDefaultTagDoc = `Build tags expected on the Go files matching no pattern of the filetags, using the same form as the tags of a pattern, such as "internal" or "internal+!legacy"`
+	// FlagIncludeGeneratedName is the name of the include-generated flag. It is exported to be reused from linters runners.
+	FlagIncludeGeneratedName = "include-generated"
+	// FlagIncludeGeneratedDoc is the usage doc of the include-generated flag. It is exported to be reused from linters runners.
+	FlagIncludeGeneratedDoc = `Also check generated files, having a "// Code generated ... DO NOT EDIT." comment, which are skipped by default`
+	// FlagGeneratedTagName is the name of the generated-tag flag. It is exported to be reused from linters runners.
+	FlagGeneratedTagName = "generated-tag"
+	// FlagGeneratedTagDoc is the usage doc of the generated-tag flag. It is exported to be reused from linters runners.
+	FlagGeneratedTagDoc = `Build tag required on the generated files, having a "// Code generated ... DO NOT EDIT." comment, unless they have the "ignore" build tag, such as "generated"`
+	// FlagIncludeIgnoredName is the name of the include-ignored flag. It is exported to be reused from linters runners.
+	FlagIncludeIgnoredName = "include-ignored"
+	// FlagIncludeIgnoredDoc is the usage doc of the include-ignored flag. It is exported to be reused from linters runners.
+	FlagIncludeIgnoredDoc = `Also check the files having the "ignore" build tag, such as standalone scripts, which are skipped by default`
+	// FlagIncludeTestdataName is the name of the include-testdata flag. It is exported to be reused from linters runners.
+	FlagIncludeTestdataName = "include-testdata"
+	// FlagIncludeTestdataDoc is the usage doc of the include-testdata flag. It is exported to be reused from linters runners.
+	FlagIncludeTestdataDoc = `Also check the Go files of the "testdata" directories of the packages, which the Go toolchain ignores, as if they were files of the packages`
+	// FlagBuildContextName is the name of the build-context flag. It is exported to be reused from linters runners.
+	FlagBuildContextName = "build-context"
+	// FlagBuildContextDoc is the usage doc of the build-context flag. It is exported to be reused from linters runners.
+	FlagBuildContextDoc = "Only check the files included in the current build context, as defined by GOOS, GOARCH and CGO_ENABLED, rather than all the files"
+	// FlagPrintConfigName is the name of the print-config flag. It is exported to be reused from linters runners.
+	FlagPrintConfigName = "print-config"
+	// FlagPrintConfigDoc is the usage doc of the print-config flag. It is exported to be reused from linters runners.
+	FlagPrintConfigDoc = "Print the effective rules to stderr, as parsed from the filetags, filetags-config and exclude flags, before checking the files"
+	// FlagDebugTimingName is the name of the debug-timing flag. It is exported to be reused from linters runners.
+	FlagDebugTimingName = "debug-timing"
+	// FlagDebugTimingDoc is the usage doc of the debug-timing flag. It is exported to be reused from linters runners.
+	FlagDebugTimingDoc = "Print to stderr the time spent walking the files, parsing their build constraints and matching the patterns, once each package is checked"
+	// FlagPathFormatName is the name of the path-format flag. It is exported to be reused from linters runners.
+	FlagPathFormatName = "path-format"
+	// FlagPathFormatDoc is the usage doc of the path-format flag. It is exported to be reused from linters runners.
+	FlagPathFormatDoc = `Format of the file names in the messages: "base" for the base name, "module" for the path relative to the root of the module, or "absolute" for the absolute path`
+	// FlagDefaultSeverityName is the name of the default-severity flag. It is exported to be reused from linters runners.
+	FlagDefaultSeverityName = "default-severity"
+	// FlagDefaultSeverityDoc is the usage doc of the default-severity flag. It is exported to be reused from linters runners.
+	FlagDefaultSeverityDoc = `Severity of the violations given no severity by their rule, "error" or "warning"`
+	// FlagSeverityPrefixName is the name of the severity-prefix flag. It is exported to be reused from linters runners.
+	FlagSeverityPrefixName = "severity-prefix"
+	// FlagSeverityPrefixDoc is the usage doc of the severity-prefix flag. It is exported to be reused from linters runners.
+	FlagSeverityPrefixDoc = `Prefix the messages of the violations of the warning severity with "warning: ", for the linters runners matching the severities on the messages`
+	// FlagReverseName is the name of the reverse flag. It is exported to be reused from linters runners.
+	FlagReverseName = "reverse"
+	// FlagReverseDoc is the usage doc of the reverse flag. It is exported to be reused from linters runners.
+	FlagReverseDoc = `Also report files having an expected build tag while not matching any of the patterns expecting it`
+	// FlagCaseInsensitiveName is the name of the case-insensitive flag. It is exported to be reused from linters runners.
+	FlagCaseInsensitiveName = "case-insensitive"
+	// FlagCaseInsensitiveDoc is the usage doc of the case-insensitive flag. It is exported to be reused from linters runners.
+	FlagCaseInsensitiveDoc = `Match file patterns and build tags regardless of their case`
+	// FlagUnusedPatternsName is the name of the unused-patterns flag. It is exported to be reused from linters runners.
+	FlagUnusedPatternsName = "unused-patterns"
+	// FlagUnusedPatternsDoc is the usage doc of the unused-patterns flag. It is exported to be reused from linters runners.
+	FlagUnusedPatternsDoc = `Also report the patterns matching no file of the package`
+	// FlagMatchPlusBuildName is the name of the match-plus-build flag. It is exported to be reused from linters runners.
+	FlagMatchPlusBuildName = "match-plus-build"
+	// FlagMatchPlusBuildDoc is the usage doc of the match-plus-build flag. It is exported to be reused from linters runners.
+	FlagMatchPlusBuildDoc = `Also report files whose "//go:build" and "// +build" constraints are not equivalent`
+	// FlagRedundantName is the name of the redundant flag. It is exported to be reused from linters runners.
+	FlagRedundantName = "redundant"
+	// FlagRedundantDoc is the usage doc of the redundant flag. It is exported to be reused from linters runners.
+	FlagRedundantDoc = `Also report build constraints duplicating the GOOS and GOARCH constraints implied by the file name, such as "//go:build linux" in "foo_linux.go"`
+	// FlagConstraintOrderName is the name of the constraint-order flag. It is exported to be reused from linters runners.
+	FlagConstraintOrderName = "constraint-order"
+	// FlagConstraintOrderDoc is the usage doc of the constraint-order flag. It is exported to be reused from linters runners.
+	FlagConstraintOrderDoc = `Also report build constraint lines which are not in the order gofmt emits, the "//go:build" line directly followed by the "// +build" lines`
+	// FlagSortedOperandsName is the name of the sorted-operands flag. It is exported to be reused from linters runners.
+	FlagSortedOperandsName = "sorted-operands"
+	// FlagSortedOperandsDoc is the usage doc of the sorted-operands flag. It is exported to be reused from linters runners.
+	FlagSortedOperandsDoc = `Also report build constraints whose "&&" and "||" operands are not in lexical order, such as "//go:build linux && amd64" rather than "//go:build amd64 && linux"`
+	// FlagAggregateMissingName is the name of the aggregate-missing flag. It is exported to be reused from linters runners.
+	FlagAggregateMissingName = "aggregate-missing"
+	// FlagAggregateMissingDoc is the usage doc of the aggregate-missing flag. It is exported to be reused from linters runners.
+	FlagAggregateMissingDoc = `Report the expected build tags missing from a file as a single diagnostic listing all of them, rather than one diagnostic per tag`
+	// FlagFirstOnlyName is the name of the first-only flag. It is exported to be reused from linters runners.
+	FlagFirstOnlyName = "first-only"
+	// FlagFirstOnlyDoc is the usage doc of the first-only flag. It is exported to be reused from linters runners.
+	FlagFirstOnlyDoc = `Report at most one missing tag per file, the one of the first rule in the order of the config, to fix large migrations iteratively`
+	// FlagDuplicateTagsName is the name of the duplicate-tags flag. It is exported to be reused from linters runners.
+	FlagDuplicateTagsName = "duplicate-tags"
+	// FlagDuplicateTagsDoc is the usage doc of the duplicate-tags flag. It is exported to be reused from linters runners.
+	FlagDuplicateTagsDoc = `Also report build tags referenced more than once by the build constraints of a file, such as "linux" in "//go:build linux && (linux || cgo)"`
+	// FlagRequireRationaleName is the name of the require-rationale flag. It is exported to be reused from linters runners.
+	FlagRequireRationaleName = "require-rationale"
+	// FlagRequireRationaleDoc is the usage doc of the require-rationale flag. It is exported to be reused from linters runners.
+	FlagRequireRationaleDoc = `Also report the files matching a pattern whose build constraint lines have no adjoining comment explaining them, such as "// Requires docker." right before "//go:build integration"`
+	// FlagMaxReportsName is the name of the max-reports flag. It is exported to be reused from linters runners.
+	FlagMaxReportsName = "max-reports"
+	// FlagMaxReportsDoc is the usage doc of the max-reports flag. It is exported to be reused from linters runners.
+	FlagMaxReportsDoc = `Report at most this number of diagnostics per package, or per run for the report and scan commands, followed by the number of the suppressed ones, 0 reporting them all`
+	// FlagConstraintStyleName is the name of the constraint-style flag. It is exported to be reused from linters runners.
+	FlagConstraintStyleName = "constraint-style"
+	// FlagConstraintStyleDoc is the usage doc of the constraint-style flag. It is exported to be reused from linters runners.
+	FlagConstraintStyleDoc = `Style of the build constraint lines files must use: "any", "new-only" for the "//go:build" line only, or "both" for the "//go:build" line along with the "// +build" lines`
+	// FlagUnixTagName is the name of the unix-tag flag. It is exported to be reused from linters runners.
+	FlagUnixTagName = "unix-tag"
+	// FlagUnixTagDoc is the usage doc of the unix-tag flag. It is exported to be reused from linters runners.
+	FlagUnixTagDoc = `Treat the "unix" build tag as satisfying the expected GOOS tags it covers, such as "linux" or "darwin"`
+	// FlagTagAliasName is the name of the tag-alias flag. It is exported to be reused from linters runners.
+	FlagTagAliasName = "tag-alias"
+	// FlagTagAliasDoc is the usage doc of the tag-alias flag. It is exported to be reused from linters runners.
+	FlagTagAliasDoc = `Comma-separated list of "tag=alias" entries, the alias satisfying the expected tag, such as "e2e=end_to_end" to accept the files having the "end_to_end" build tag where "e2e" is expected`
+	// FlagIgnoreTagsName is the name of the ignore-tags flag. It is exported to be reused from linters runners.
+	FlagIgnoreTagsName = "ignore-tags"
+	// FlagIgnoreTagsDoc is the usage doc of the ignore-tags flag. It is exported to be reused from linters runners.
+	FlagIgnoreTagsDoc = `Comma-separated list of build tags considered absent from the files when checking the rules, such as tags applied by generators`
 )
 
+// Analyzer is the filebuildtag linter. Its result is a *Result.
+//
+// Like Go's buildtag linter, it runs despite the errors of the package, as malformed build constraints prevent
+// packages from being loaded.
 var Analyzer = &analysis.Analyzer{
-	Name:     "filebuildtag",
-	Doc:      Doc,
-	Flags:    flags(),
-	Run:      run,
-	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Name:             Name,
+	Doc:              Doc,
+	Flags:            flags(),
+	Run:              run,
+	RunDespiteErrors: true,
+	Requires:         []*analysis.Analyzer{inspect.Analyzer},
+	ResultType:       reflect.TypeOf((*Result)(nil)),
 }
 
 func flags() flag.FlagSet {
 	fs := flag.NewFlagSet("", flag.ExitOnError)
 	fs.String(FlagFiletagsName, "", FlagFiletagsDoc)
+	fs.String(FlagFiletagsConfigName, "", FlagFiletagsConfigDoc)
+	fs.String(FlagProfileName, "", FlagProfileDoc)
+	fs.String(FlagExcludeName, "", FlagExcludeDoc)
+	fs.String(FlagIgnoreFileName, "", FlagIgnoreFileDoc)
+	fs.String(FlagRequireConstraintName, "", FlagRequireConstraintDoc)
+	fs.String(FlagPlatformGroupName, "", FlagPlatformGroupDoc)
+	fs.String(FlagPlatformsName, "", FlagPlatformsDoc)
+	fs.String(FlagOnlyName, "", FlagOnlyDoc)
+	fs.Bool(FlagRequireConfigName, false, FlagRequireConfigDoc)
+	fs.String(FlagDefaultTagName, "", FlagDefaultTagDoc)
+	fs.Bool(FlagIncludeGeneratedName, false, FlagIncludeGeneratedDoc)
+	fs.String(FlagGeneratedTagName, "", FlagGeneratedTagDoc)
+	fs.Bool(FlagIncludeIgnoredName, false, FlagIncludeIgnoredDoc)
+	fs.Bool(FlagIncludeTestdataName, false, FlagIncludeTestdataDoc)
+	fs.Bool(FlagBuildContextName, false, FlagBuildContextDoc)
+	fs.Bool(FlagPrintConfigName, false, FlagPrintConfigDoc)
+	fs.Bool(FlagDebugTimingName, false, FlagDebugTimingDoc)
+	fs.String(FlagPathFormatName, pathFormatBase, FlagPathFormatDoc)
+	fs.String(FlagDefaultSeverityName, string(SeverityError), FlagDefaultSeverityDoc)
+	fs.Bool(FlagSeverityPrefixName, false, FlagSeverityPrefixDoc)
+	fs.Bool(FlagReverseName, false, FlagReverseDoc)
+	fs.Bool(FlagCaseInsensitiveName, false, FlagCaseInsensitiveDoc)
+	fs.Bool(FlagUnusedPatternsName, false, FlagUnusedPatternsDoc)
+	fs.Bool(FlagMatchPlusBuildName, false, FlagMatchPlusBuildDoc)
+	fs.Bool(FlagRedundantName, false, FlagRedundantDoc)
+	fs.Bool(FlagConstraintOrderName, false, FlagConstraintOrderDoc)
+	fs.Bool(FlagSortedOperandsName, false, FlagSortedOperandsDoc)
+	fs.String(FlagConstraintStyleName, styleAny, FlagConstraintStyleDoc)
+	fs.Bool(FlagUnixTagName, false, FlagUnixTagDoc)
+	fs.String(FlagTagAliasName, "", FlagTagAliasDoc)
+	fs.String(FlagIgnoreTagsName, "", FlagIgnoreTagsDoc)
+	fs.Bool(FlagAggregateMissingName, false, FlagAggregateMissingDoc)
+	fs.Bool(FlagFirstOnlyName, false, FlagFirstOnlyDoc)
+	fs.Int(FlagMaxReportsName, 0, FlagMaxReportsDoc)
+	fs.Bool(FlagDuplicateTagsName, false, FlagDuplicateTagsDoc)
+	fs.Bool(FlagRequireRationaleName, false, FlagRequireRationaleDoc)
 	return *fs
 }
 
+// NewAnalyzer returns an analyzer using the config rather than flags, for library consumers. It has no flags, and
+// reports the errors of the config when run.
+func NewAnalyzer(cfg Config) *analysis.Analyzer {
+	rules, err := cfg.rules()
+	checkedTestdata := &testdataCache{}
+	return &analysis.Analyzer{
+		Name: Analyzer.Name,
+		Doc:  Doc,
+		Run: func(pass *analysis.Pass) (interface{}, error) {
+			if err != nil {
+				return nil, err
+			}
+			opts := cfg.options()
+			opts.checkedTestdata = checkedTestdata
+			return check(pass, rules, opts)
+		},
+		RunDespiteErrors: true,
+		Requires:         []*analysis.Analyzer{inspect.Analyzer},
+		ResultType:       reflect.TypeOf((*Result)(nil)),
+	}
+}
+
+// printConfigOnce prints the rules once per process, as the analyzer runs once per package.
+var printConfigOnce sync.Once
+
 func run(pass *analysis.Pass) (interface{}, error) {
-	filetags, err := parseFlags(pass.Analyzer.Flags)
+	rules, err := parseFlags(pass.Analyzer.Flags)
 	if err != nil {
 		return nil, err
 	}
+	if boolFlag(pass.Analyzer.Flags, FlagPrintConfigName) {
+		printConfigOnce.Do(func() {
+			fmt.Fprint(os.Stderr, rules.describe())
+		})
+	}
+	opts := parseOptions(pass.Analyzer.Flags)
+	opts.checkedTestdata = analyzerTestdata
+	return check(pass, rules, opts)
+}
 
+// check checks the files of the package against the rules.
+func check(pass *analysis.Pass, rules rules, opts options) (*Result, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	if len(rules.groups) > 0 && len(opts.platforms) == 0 {
+		return nil, errors.New("platform groups require the platforms they must build for")
+	}
+	reportSuppressed := func() {}
+	if opts.maxReports > 0 {
+		pass, reportSuppressed = limitedPass(pass, opts.maxReports)
+	}
+	var isListed func(token.Pos) bool
+	if rules.only != nil {
+		pass, isListed = onlyPass(pass, rules.only)
+	}
+	c := newChecker(pass, rules, opts)
 	inspector := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
 	nodeFilter := []ast.Node{
 		(*ast.File)(nil),
 	}
+	started := c.timing.start()
 	inspector.Preorder(nodeFilter, func(node ast.Node) {
-		f := node.(*ast.File)
-		filename := getFilename(pass, f)
-		tags := internal.CheckGoFile(pass, f)
-		for pattern, tag := range filetags {
-			ok, _ := filepath.Match(pattern, filename)
-			if !ok {
-				continue
-			}
-
-			foundTag := false
-			for i := range tags {
-				if tags[i] == tag {
-					foundTag = true
-					break
-				}
-			}
-
-			if !foundTag {
-				pass.Reportf(f.Pos(), `missing expected build tag: "%s"`, tag)
-			}
-		}
+		c.checkFile(node.(*ast.File))
 	})
-	return nil, nil
+	c.timing.stop(phasePreorder, started)
+	for _, filename := range pass.OtherFiles {
+		c.checkOtherFile(filename)
+	}
+	if c.opts.includeTestdata {
+		c.checkTestdata()
+	}
+	if len(rules.groups) > 0 {
+		c.checkPlatformGroups()
+	}
+	c.timing.print(timingOutput, pass.Pkg.Path())
+
+	if c.err != nil {
+		return nil, c.err
+	}
+	if c.opts.requireConfig && !c.configured() {
+		return nil, errNoFiletags
+	}
+	if c.opts.unusedPatterns {
+		c.reportUnusedPatterns()
+	}
+	reportSuppressed()
+	if isListed != nil {
+		return c.result.filter(isListed), nil
+	}
+	return c.result, nil
 }
 
-func parseFlags(flags flag.FlagSet) (map[string]string, error) {
-	filetags := make(map[string]string)
-	f := flags.Lookup(FlagFiletagsName)
-	if f == nil {
-		return filetags, nil
-	}
-	args := strings.Split(f.Value.String(), ",")
-	for i := 0; i < len(args); i++ {
-		filetag := strings.TrimSpace(args[i])
-		if filetag == "" {
-			continue
-		}
+// errNoFiletags is the error of the require-config flag when no filetags are configured.
+var errNoFiletags = errors.New("no filetags configured, while required by the require-config flag")
 
-		parts := strings.Split(filetag, ":")
-		if len(parts) != 2 {
-			return nil, fmt.Errorf(`malformed argument: "%s", must be of the form "pattern:tag"`, filetag)
-		}
+// reportPos returns the position diagnostics are reported at: the build constraints of the file, or the package
+// clause when the file has none.
+func reportPos(f *ast.File, constraints internal.Constraints) token.Pos {
+	if constraints.Pos.IsValid() {
+		return constraints.Pos
+	}
+	return f.Pos()
+}
+
+// The path formats of the path-format flag: the base name of the file, its path relative to the root of its module,
+// or its absolute path.
+const (
+	pathFormatBase     = "base"
+	pathFormatModule   = "module"
+	pathFormatAbsolute = "absolute"
+)
 
-		parts[0] = strings.TrimSpace(parts[0])
-		parts[1] = strings.TrimSpace(parts[1])
-		if parts[0] == "" || parts[1] == "" {
-			return nil, fmt.Errorf(`malformed argument: "%s", must be of the form "pattern:tag"`, filetag)
+// formatPath returns the name of the file shown in the messages, according to the path format, its base name by
+// default. The module path is the path of the file relative to the root of its module.
+func formatPath(pass *analysis.Pass, file *ast.File, modulePath, format string) string {
+	switch format {
+	case pathFormatModule:
+		return modulePath
+	case pathFormatAbsolute:
+		filename := pass.Fset.Position(file.Pos()).Filename
+		if abs, err := filepath.Abs(filename); err == nil {
+			return abs
 		}
-		filetags[parts[0]] = parts[1]
+		return filename
 	}
-	return filetags, nil
+	return getFilename(pass, file)
+}
+
+// hasPackageClause reports whether the file has a package clause. The parser still returns the files missing it, such
+// as the partially written ones, but without their comments and positioned nowhere, so they are skipped: the Go
+// toolchain reports their syntax error anyway.
+func hasPackageClause(f *ast.File) bool {
+	return f.Package.IsValid() && f.Name != nil && f.Name.Name != ""
 }
 
 func getFilename(pass *analysis.Pass, file *ast.File) string {
@@ -112,3 +404,31 @@ func getFilename(pass *analysis.Pass, file *ast.File) string {
 	_, filename := filepath.Split(path)
 	return filename
 }
+
+// getDirname returns the name of the directory of the file on disk, as recorded in the file set of the pass.
+func getDirname(pass *analysis.Pass, file *ast.File) string {
+	return filepath.Base(filepath.Dir(pass.Fset.Position(file.Pos()).Filename))
+}
+
+// getPath returns the path of the file relative to the root of its module, using forward slashes. It is derived
+// from the import path of the package, hence it is relative to the "src" directory of the GOPATH when the module
+// is unknown.
+func getPath(pass *analysis.Pass, file *ast.File) string {
+	return path.Join(pkgDir(pass), getFilename(pass, file))
+}
+
+// pkgDir returns the directory of the package relative to the root of its module, using forward slashes, like getPath.
+func pkgDir(pass *analysis.Pass) string {
+	dir := pass.Pkg.Path()
+	if strings.HasSuffix(pass.Pkg.Name(), "_test") {
+		// External test packages share the directory of the package they test.
+		dir = strings.TrimSuffix(dir, "_test")
+	}
+	if pass.Module != nil {
+		if dir == pass.Module.Path {
+			dir = ""
+		}
+		dir = strings.TrimPrefix(dir, pass.Module.Path+"/")
+	}
+	return dir
+}
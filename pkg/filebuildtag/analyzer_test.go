@@ -1,11 +1,15 @@
 package filebuildtag
 
 import (
-	"errors"
+	"bytes"
 	"flag"
+	"fmt"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
-	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/tools/go/analysis/analysistest"
 )
@@ -15,6 +19,7 @@ func Test_Lint(t *testing.T) {
 	testCases := map[string]struct {
 		pattern string
 		flags   string
+		options map[string]string
 	}{
 		"successfully match files with a wildcard": {
 			pattern: "filebuildtag_wildcard",
@@ -24,10 +29,255 @@ func Test_Lint(t *testing.T) {
 			pattern: "filebuildtag_exact",
 			flags:   "pref_tag1_suff.go:tag1,pref_tag2_suff.go:tag2",
 		},
+		"successfully match files with pattern alternatives": {
+			pattern: "filebuildtag_exact",
+			flags:   "{pref_tag1_suff.go|nope.go}:tag1,{re:nope|pref_tag2_*.go}:tag2",
+		},
 		"successfully match exact file name without tags": {
 			pattern: "filebuildtag_exact",
 			flags:   "",
 		},
+		"successfully match files using the go:build syntax": {
+			pattern: "filebuildtag_gobuild",
+			flags:   "*tag1*:tag1,*tag2*:tag2",
+		},
+		"successfully match tags within boolean expressions": {
+			pattern: "filebuildtag_expr",
+			flags:   "*:linux",
+		},
+		"successfully match files requiring several tags": {
+			pattern: "filebuildtag_multiple",
+			flags:   "*_suff.go:tag1+tag2,*_suff.go:tag3",
+		},
+		"successfully report forbidden tags": {
+			pattern: "filebuildtag_forbidden",
+			flags:   "*_nocgo.go:!cgo,*_tag1.go:tag1+!cgo",
+		},
+		"successfully match files having exactly one tag of a set": {
+			pattern: "filebuildtag_oneof",
+			flags:   "*_env.go:oneof(dev,staging,prod)",
+		},
+		"successfully match files having any or all the tags of a group": {
+			pattern: "filebuildtag_taggroup",
+			flags:   "*_platform.go:anyof(linux,darwin,windows),*_bundle.go:allof(foo,bar,baz)",
+		},
+		"successfully report rules both expecting and forbidding a tag": {
+			pattern: "filebuildtag_conflict",
+			flags:   "*.go:cgo,*_nocgo.go:!cgo+tag1",
+		},
+		"successfully match files with a regular expression": {
+			pattern: "filebuildtag_regex",
+			flags:   `re:.*_v[0-9]+\.go:legacy,re:(foo|bar)\.go:foobar`,
+		},
+		"successfully match files with a path pattern": {
+			pattern: "filebuildtag_path/...",
+			flags:   "filebuildtag_path/legacy/*.go:legacy,re:filebuildtag_path/.*/deep\\.go:deep,*.go:all",
+		},
+		"successfully restrict patterns to test files or to the other files": {
+			pattern: "filebuildtag_qualifier",
+			flags:   "test:*.go:unit,nontest:*.go:prod",
+		},
+		"successfully match files by package name along with their file name": {
+			pattern: "filebuildtag_pkg",
+			flags:   "pkg:integrationtest:integration,*_suff.go:tag1,pkg:other:other",
+		},
+		"successfully suggest present tags looking like typos": {
+			pattern: "filebuildtag_typo",
+			flags:   "*_integration_test.go:integration,*_unit_test.go:unit",
+		},
+		"successfully report files having a tag without matching the patterns expecting it": {
+			pattern: "filebuildtag_reverse",
+			flags:   "*_integration_test.go:integration,*_e2e_test.go:integration+!unit,*_unit_test.go:unit",
+			options: map[string]string{FlagReverseName: "true"},
+		},
+		"successfully report each tag once per file with overlapping patterns": {
+			pattern: "filebuildtag_overlap",
+			flags:   "*.go:foo+!bar,*_suff.go:foo+!bar,re:.*_suff\\.go:foo",
+		},
+		"successfully match files and tags regardless of their case": {
+			pattern: "filebuildtag_case",
+			flags:   "*_suff.go:tag1,re:été_.*:ÉTÉ",
+			options: map[string]string{FlagCaseInsensitiveName: "true"},
+		},
+		"successfully consider the ignored tags absent": {
+			pattern: "filebuildtag_ignored_tags",
+			flags:   "*_test.go:unit,*_gen.go:!legacy,tag:legacy=>special,*_legacy.go:legacy",
+			options: map[string]string{FlagIgnoreTagsName: "legacy"},
+		},
+		"successfully skip excluded files": {
+			pattern: "filebuildtag_exclude",
+			flags:   "*_suff.go:tag1,*_other_suff.go:tag2,!*_mock_suff.go,!re:gen_.*",
+		},
+		"successfully skip generated files and files matching the exclude flag": {
+			pattern: "filebuildtag_generated",
+			flags:   "*_suff.go:tag1",
+			options: map[string]string{FlagExcludeName: "mock_*, *.pb.go"},
+		},
+		"successfully skip files matching the ignore file": {
+			pattern: "filebuildtag_ignorefile/...",
+			flags:   "*_suff.go:tag1",
+			options: map[string]string{FlagIgnoreFileName: "testdata/ignorefile"},
+		},
+		"successfully check the default tag of files matching no pattern": {
+			pattern: "filebuildtag_default",
+			flags:   "*_integration_test.go:integration,tag:integration=>slow,!mock_*.go",
+			options: map[string]string{FlagDefaultTagName: "internal"},
+		},
+		"successfully check non-Go files": {
+			pattern: "filebuildtag_other",
+			flags:   "*_asm.s:amd64,*.h:!cgo",
+		},
+		"successfully match Go and non-Go files using the same pattern": {
+			pattern: "filebuildtag_extensions",
+			flags:   "*_amd64.*:amd64",
+		},
+		"successfully skip files having the ignore tag": {
+			pattern: "filebuildtag_ignoretag",
+			flags:   "*.go:tag1",
+		},
+		"successfully check files having the ignore tag when included": {
+			pattern: "filebuildtag_ignoretag_included",
+			flags:   "*.go:tag1",
+			options: map[string]string{FlagIncludeIgnoredName: "true"},
+		},
+		"successfully check conditional rules": {
+			pattern: "filebuildtag_condition",
+			flags:   "tag:integration=>slow+!unit,*_suff.go:integration",
+		},
+		"successfully match files above a line count": {
+			pattern: "filebuildtag_lines",
+			flags:   "lines>3:large",
+		},
+		"successfully apply the negated parts of patterns": {
+			pattern: "filebuildtag_negated",
+			flags:   "*_suff.go&!*_generated_suff.go:tag1,*_generated_suff.go:tag3",
+		},
+		"successfully skip files with a suppression directive": {
+			pattern: "filebuildtag_ignore",
+			flags:   "*_suff.go:tag1",
+		},
+		"successfully check generated files when included": {
+			pattern: "filebuildtag_generated_included",
+			flags:   "*_suff.go:tag1",
+			options: map[string]string{FlagIncludeGeneratedName: "true"},
+		},
+		"successfully require the generated tag on generated files": {
+			pattern: "filebuildtag_generated_tag",
+			flags:   "*_suff.go:tag1",
+			options: map[string]string{FlagGeneratedTagName: "generated"},
+		},
+		"successfully report patterns matching no file": {
+			pattern: "filebuildtag_unused",
+			flags:   "*_suff.go:tag1,*_integraton_test.go:integration,re:foo.*:foo,*_skipped.go:tag1,!*_skipped.go",
+			options: map[string]string{FlagUnusedPatternsName: "true"},
+		},
+		"successfully merge the nearest directory config with the global rules": {
+			pattern: "filebuildtag_dirconfig/...",
+			flags:   "*_suff.go:tag1,*_other.go:all,tag_*:tagged",
+		},
+		"successfully merge the module config and the nearest directory config with the global rules": {
+			pattern: "filebuildtag_modconfig/...",
+			flags:   "*_suff.go:tag1,*_mod.go:mod",
+		},
+		"successfully match the files of a directory and its nested directories": {
+			pattern: "filebuildtag_dir/...",
+			flags:   "dir:filebuildtag_dir/legacy:legacy",
+		},
+		"successfully match the files of a directory by its name": {
+			pattern: "filebuildtag_dirname/...",
+			flags:   "dirname:*_integration:integration,*_slow.go:slow",
+		},
+		"successfully check the tags captured from the file names": {
+			pattern: "filebuildtag_capture",
+			flags:   `re:service_(.*)_integration_test\.go:integration+$1,re:legacy_(\w+)_test\.go:!$1`,
+		},
+		"successfully match the files importing a package": {
+			pattern: "filebuildtag_imports",
+			flags:   "imports:database/sql:db,imports:net/**:net",
+		},
+		"successfully report the listed files only": {
+			pattern: "filebuildtag_only",
+			flags:   "*_suff.go:tag1",
+			options: map[string]string{FlagOnlyName: "./filebuildtag_only/listed_suff.go, named_suff.go"},
+		},
+		"successfully report the files of a list only": {
+			pattern: "filebuildtag_only",
+			flags:   "*_suff.go:tag1",
+			options: map[string]string{FlagOnlyName: "@testdata/only.txt"},
+		},
+		"successfully tell the negated tags from the other ones": {
+			pattern: "filebuildtag_negated_tags",
+			flags:   "*_pos.go:windows,*_forb.go:!windows",
+		},
+		"successfully report the files missing a required build constraint": {
+			pattern: "filebuildtag_constrained",
+			flags:   "",
+			options: map[string]string{FlagRequireConstraintName: "*_secure.go,crypto_*"},
+		},
+		"successfully report the gaps and overlaps of the platform groups": {
+			pattern: "filebuildtag_platforms",
+			flags:   "",
+			options: map[string]string{
+				FlagPlatformGroupName: "net_*.go,proc_*.go,sys*.go",
+				FlagPlatformsName:     "linux,darwin,windows",
+			},
+		},
+		"successfully split the platform groups by the values captured by their pattern": {
+			pattern: "filebuildtag_platform_split",
+			flags:   "",
+			options: map[string]string{
+				FlagPlatformGroupName: `re:(.+)_.+\.go`,
+				FlagPlatformsName:     "linux,darwin,windows",
+			},
+		},
+		"successfully report build constraints implied by the file name": {
+			pattern: "filebuildtag_redundant",
+			flags:   "",
+			options: map[string]string{FlagRedundantName: "true"},
+		},
+		"successfully report build constraints without a rationale comment": {
+			pattern: "filebuildtag_rationale",
+			flags:   "*_suff.go:tag1",
+			options: map[string]string{FlagRequireRationaleName: "true"},
+		},
+		"successfully skip the files without a package clause": {
+			pattern: "filebuildtag_no_package",
+			flags:   "*:tag1",
+			options: map[string]string{FlagPlatformGroupName: "*_suff.go", FlagPlatformsName: "linux"},
+		},
+		"successfully report the file names relative to the root of the module": {
+			pattern: "filebuildtag_path_format",
+			flags:   "",
+			options: map[string]string{FlagRedundantName: "true", FlagPathFormatName: pathFormatModule},
+		},
+		"successfully report only the first missing tag of each file": {
+			pattern: "filebuildtag_first",
+			flags:   "*_suff.go:tag1+tag2+!tag5,*_b_suff.go:tag3",
+			options: map[string]string{FlagFirstOnlyName: "true"},
+		},
+		"successfully report build tags referenced more than once": {
+			pattern: "filebuildtag_duplicate",
+			flags:   "",
+			options: map[string]string{FlagDuplicateTagsName: "true"},
+		},
+		"successfully treat the unix build tag as satisfying its GOOS tags": {
+			pattern: "filebuildtag_unix",
+			flags:   "*_suff.go:linux,*_win.go:windows",
+			options: map[string]string{FlagUnixTagName: "true"},
+		},
+		"successfully treat the aliases as satisfying their expected tags": {
+			pattern: "filebuildtag_alias",
+			flags:   "*_e2e.go:e2e,*_new.go:end_to_end",
+			options: map[string]string{FlagTagAliasName: "e2e=end_to_end"},
+		},
+		"successfully report malformed build constraints": {
+			pattern: "filebuildtag_malformed",
+			flags:   "",
+		},
+		"successfully match build constraint expressions": {
+			pattern: "filebuildtag_fix_expr",
+			flags:   "*_expr.go:!testfix && !nope",
+		},
 		"successfully assess that the std lib linter's original test file must have the foo tag": {
 			pattern: "buildtag",
 			flags:   "*:foo",
@@ -37,66 +287,529 @@ func Test_Lint(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			analyzer := Analyzer
 			flags := newFlagSet(t, tt.flags)
+			for name, value := range tt.options {
+				require.NoError(t, flags.Set(name, value))
+			}
 			analyzer.Flags = flags
 			analysistest.Run(t, testdata, analyzer, tt.pattern)
 		})
 	}
 }
 
-func Test_parseFlags(t *testing.T) {
-	emptyFiletags := map[string]string{}
+func Test_SuggestedFixes(t *testing.T) {
+	testdata := analysistest.TestData()
 	testCases := map[string]struct {
-		flags       flag.FlagSet
-		expected    map[string]string
-		expectedErr error
+		pattern   string
+		flags     string
+		options   map[string]string
+		buildTags string
 	}{
-		"no flags": {
-			flags:       flag.FlagSet{},
-			expected:    emptyFiletags,
-			expectedErr: nil,
-		},
-		"empty tag": {
-			flags:       newFlagSet(t, "  "),
-			expected:    emptyFiletags,
-			expectedErr: nil,
-		},
-		"malformed flag": {
-			flags:       newFlagSet(t, "foo"),
-			expectedErr: errors.New(`malformed argument: "foo", must be of the form "pattern:tag"`),
-		},
-		"empty file pattern": {
-			flags:       newFlagSet(t, ":foo"),
-			expectedErr: errors.New(`malformed argument: ":foo", must be of the form "pattern:tag"`),
-		},
-		"empty build tag": {
-			flags:       newFlagSet(t, "foo:"),
-			expectedErr: errors.New(`malformed argument: "foo:", must be of the form "pattern:tag"`),
-		},
-		"single file pattern": {
-			flags: newFlagSet(t, "*:foo"),
-			expected: map[string]string{
-				"*": "foo",
+		"successfully add missing tags": {
+			pattern: "filebuildtag_fix",
+			flags:   "*_tag1.go:tag1",
+		},
+		"successfully replace build constraints not matching the expected expression": {
+			pattern: "filebuildtag_fix_expr",
+			flags:   "*_expr.go:!testfix && !nope",
+		},
+		"successfully rewrite +build lines not matching the go:build line": {
+			pattern: "filebuildtag_plusbuild",
+			flags:   "",
+			options: map[string]string{FlagMatchPlusBuildName: "true"},
+		},
+		"successfully add all the aggregated missing tags": {
+			pattern: "filebuildtag_aggregate",
+			flags:   "*_suff.go:tag1+tag2,*_b_suff.go:tag3@warning",
+			options: map[string]string{FlagAggregateMissingName: "true"},
+		},
+		"successfully rewrite build constraint lines using the new-only style": {
+			pattern: "filebuildtag_style_new",
+			flags:   "",
+			options: map[string]string{FlagConstraintStyleName: "new-only"},
+		},
+		"successfully rewrite build constraint lines using both styles": {
+			pattern: "filebuildtag_style_both",
+			flags:   "",
+			options: map[string]string{FlagConstraintStyleName: "both"},
+		},
+		"successfully reorder build constraint lines": {
+			pattern: "filebuildtag_order",
+			flags:   "",
+			options: map[string]string{FlagConstraintOrderName: "true"},
+		},
+		"successfully sort the operands of build constraints": {
+			pattern: "filebuildtag_sorted",
+			flags:   "",
+			options: map[string]string{FlagSortedOperandsName: "true"},
+		},
+		"successfully fix constraint-only files, without a trailing newline": {
+			pattern: "filebuildtag_constraint_only",
+			flags:   "*_gate.go:integration,*_debug.go:!debug",
+		},
+		"successfully remove forbidden tags": {
+			pattern:   "filebuildtag_fix_forbidden",
+			flags:     "*_forb.go:!forb",
+			buildTags: "forb",
+		},
+	}
+	for name, tt := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if tt.buildTags != "" {
+				// The files only having the forbidden tag are left out of the package otherwise.
+				t.Setenv("GOFLAGS", "-tags="+tt.buildTags)
+			}
+			analyzer := Analyzer
+			flags := newFlagSet(t, tt.flags)
+			for name, value := range tt.options {
+				require.NoError(t, flags.Set(name, value))
+			}
+			analyzer.Flags = flags
+			analysistest.RunWithSuggestedFixes(t, testdata, analyzer, tt.pattern)
+		})
+	}
+}
+
+func Test_NewAnalyzer(t *testing.T) {
+	analyzer := NewAnalyzer(Config{
+		Filetags: map[string][]string{
+			"*_suff.go": {"tag1+tag2", "tag3"},
+		},
+	})
+	analysistest.Run(t, analysistest.TestData(), analyzer, "filebuildtag_multiple")
+}
+
+func Test_ConventionAnalyzer(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), ConventionAnalyzer, "filebuildtag_convention", "filebuildtag_convention/tests")
+}
+
+func Test_DebugTiming(t *testing.T) {
+	testCases := map[string]struct {
+		debugTiming bool
+		expected    string
+	}{
+		"successfully print the timing of the package": {
+			debugTiming: true,
+			expected: `^filebuildtag: timing of filebuildtag_multiple: 4 files in \S+, preorder \S+, parsing \S+, ` +
+				`matching \S+\n$`,
+		},
+		"successfully print nothing without the flag": {
+			expected: `^$`,
+		},
+	}
+	for name, tt := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var output bytes.Buffer
+			timingOutput = &output
+			t.Cleanup(func() { timingOutput = os.Stderr })
+			analyzer := NewAnalyzer(Config{
+				Filetags:    map[string][]string{"*_suff.go": {"tag1+tag2", "tag3"}},
+				DebugTiming: tt.debugTiming,
+			})
+			analysistest.Run(t, analysistest.TestData(), analyzer, "filebuildtag_multiple")
+			require.Regexp(t, tt.expected, output.String())
+		})
+	}
+}
+
+func Test_Result(t *testing.T) {
+	analyzer := Analyzer
+	analyzer.Flags = newFlagSet(t, "*_suff.go:tag1+tag2,*_suff.go:tag3")
+	results := analysistest.Run(t, analysistest.TestData(), analyzer, "filebuildtag_multiple")
+	require.Len(t, results, 1)
+	result, ok := results[0].Result.(*Result)
+	require.True(t, ok)
+
+	type violation struct {
+		file     string
+		kind     Kind
+		patterns []string
+		tag      string
+		found    []string
+	}
+	var found []violation
+	for _, v := range result.Violations {
+		found = append(found, violation{
+			file:     filepath.Base(results[0].Pass.Fset.Position(v.Pos).Filename),
+			kind:     v.Kind,
+			patterns: v.Patterns,
+			tag:      v.Tag,
+			found:    v.Found,
+		})
+	}
+	require.ElementsMatch(t, []violation{
+		{file: "none_suff.go", kind: KindMissingTag, patterns: []string{"*_suff.go"}, tag: "tag1", found: []string{}},
+		{file: "none_suff.go", kind: KindMissingTag, patterns: []string{"*_suff.go"}, tag: "tag2", found: []string{}},
+		{file: "none_suff.go", kind: KindMissingTag, patterns: []string{"*_suff.go"}, tag: "tag3", found: []string{}},
+		{file: "tag1_suff.go", kind: KindMissingTag, patterns: []string{"*_suff.go"}, tag: "tag2", found: []string{"tag1"}},
+		{file: "tag1_suff.go", kind: KindMissingTag, patterns: []string{"*_suff.go"}, tag: "tag3", found: []string{"tag1"}},
+	}, found)
+	require.Equal(t, map[string]int{"tag1": 1, "tag2": 2, "tag3": 2}, result.TagViolations)
+
+	type check struct {
+		file   string
+		tag    string
+		found  []string
+		passed bool
+	}
+	var checks []check
+	for _, c := range result.Checks {
+		require.Equal(t, "*_suff.go", c.Pattern)
+		require.Equal(t, results[0].Pass.Fset.Position(c.Pos).Filename, c.File)
+		checks = append(checks, check{file: filepath.Base(c.File), tag: c.Tag, found: c.Found, passed: c.Passed})
+	}
+	require.ElementsMatch(t, []check{
+		{file: "all_suff.go", tag: "tag1", found: []string{"tag1", "tag2", "tag3"}, passed: true},
+		{file: "all_suff.go", tag: "tag2", found: []string{"tag1", "tag2", "tag3"}, passed: true},
+		{file: "all_suff.go", tag: "tag3", found: []string{"tag1", "tag2", "tag3"}, passed: true},
+		{file: "none_suff.go", tag: "tag1", found: []string{}, passed: false},
+		{file: "none_suff.go", tag: "tag2", found: []string{}, passed: false},
+		{file: "none_suff.go", tag: "tag3", found: []string{}, passed: false},
+		{file: "tag1_suff.go", tag: "tag1", found: []string{"tag1"}, passed: true},
+		{file: "tag1_suff.go", tag: "tag2", found: []string{"tag1"}, passed: false},
+		{file: "tag1_suff.go", tag: "tag3", found: []string{"tag1"}, passed: false},
+	}, checks)
+}
+
+func Test_Severity(t *testing.T) {
+	analyzer := Analyzer
+	analyzer.Flags = newFlagSet(t, "*_suff.go:tag1+tag2@warning,*_suff.go:tag3@error")
+	results := analysistest.Run(t, analysistest.TestData(), analyzer, "filebuildtag_multiple")
+	require.Len(t, results, 1)
+	result, ok := results[0].Result.(*Result)
+	require.True(t, ok)
+
+	type violation struct {
+		file     string
+		severity Severity
+		tag      string
+	}
+	var found []violation
+	for _, v := range result.Violations {
+		found = append(found, violation{
+			file:     filepath.Base(results[0].Pass.Fset.Position(v.Pos).Filename),
+			severity: v.Severity,
+			tag:      v.Tag,
+		})
+	}
+	require.ElementsMatch(t, []violation{
+		{file: "none_suff.go", severity: SeverityError, tag: "tag1"},
+		{file: "none_suff.go", severity: SeverityWarning, tag: "tag2"},
+		{file: "none_suff.go", severity: SeverityError, tag: "tag3"},
+		{file: "tag1_suff.go", severity: SeverityWarning, tag: "tag2"},
+		{file: "tag1_suff.go", severity: SeverityError, tag: "tag3"},
+	}, found)
+	var categories []string
+	for _, d := range results[0].Diagnostics {
+		categories = append(categories, d.Category)
+	}
+	require.ElementsMatch(t, []string{
+		"filebuildtag/tag1", "filebuildtag/tag2", "filebuildtag/tag3", "filebuildtag/tag2", "filebuildtag/tag3",
+	}, categories)
+}
+
+func Test_DefaultSeverity(t *testing.T) {
+	analyzer := Analyzer
+	analyzer.Flags = withFlag(t,
+		withFlag(t, newFlagSet(t, "*_suff.go:tag1+tag2,*_suff.go:tag3@error"), FlagDefaultSeverityName, "warning"),
+		FlagSeverityPrefixName, "true")
+	results := analysistest.Run(t, analysistest.TestData(), analyzer, "filebuildtag_multiple")
+	require.Len(t, results, 1)
+	result, ok := results[0].Result.(*Result)
+	require.True(t, ok)
+
+	type violation struct {
+		severity Severity
+		tag      string
+		prefixed bool
+	}
+	var found []violation
+	for _, v := range result.Violations {
+		found = append(found, violation{severity: v.Severity, tag: v.Tag, prefixed: strings.HasPrefix(v.Message, "warning: ")})
+	}
+	require.ElementsMatch(t, []violation{
+		{severity: SeverityWarning, tag: "tag1", prefixed: true},
+		{severity: SeverityWarning, tag: "tag2", prefixed: true},
+		{severity: SeverityError, tag: "tag3", prefixed: false},
+		{severity: SeverityWarning, tag: "tag2", prefixed: true},
+		{severity: SeverityError, tag: "tag3", prefixed: false},
+	}, found)
+	var categories []string
+	for _, d := range results[0].Diagnostics {
+		categories = append(categories, d.Category)
+	}
+	require.ElementsMatch(t, []string{
+		"filebuildtag/tag1", "filebuildtag/tag2", "filebuildtag/tag3", "filebuildtag/tag2", "filebuildtag/tag3",
+	}, categories)
+}
+
+func Test_category(t *testing.T) {
+	tests := []struct {
+		violation Violation
+		expected  string
+	}{
+		{violation: Violation{Kind: KindMissingTag, Tag: "integration"}, expected: "filebuildtag/integration"},
+		{violation: Violation{Kind: KindForbiddenTag, Tag: "unit"}, expected: "filebuildtag/unit"},
+		{violation: Violation{Kind: KindDuplicateTag, Tag: "linux"}, expected: "filebuildtag/linux"},
+		{violation: Violation{Kind: KindMissingTag, Tag: "oneof(dev,prod)"}, expected: "filebuildtag/missing-tag"},
+		{violation: Violation{Kind: KindMissingTag, Tag: "tag1+tag2"}, expected: "filebuildtag/missing-tag"},
+		{
+			violation: Violation{Kind: KindConstraintMismatch, Tag: "linux && amd64"},
+			expected:  "filebuildtag/constraint-mismatch",
+		},
+		{violation: Violation{Kind: KindUnusedPattern}, expected: "filebuildtag/unused-pattern"},
+	}
+	for _, test := range tests {
+		require.Equal(t, test.expected, category(test.violation))
+	}
+}
+
+func Test_Only(t *testing.T) {
+	analyzer := NewAnalyzer(Config{
+		Filetags: map[string][]string{"*_suff.go": {"tag1"}},
+		Only:     []string{"filebuildtag_only/listed_suff.go", "named_suff.go"},
+	})
+	results := analysistest.Run(t, analysistest.TestData(), analyzer, "filebuildtag_only")
+	require.Len(t, results, 1)
+	result, ok := results[0].Result.(*Result)
+	require.True(t, ok)
+
+	var files []string
+	for _, v := range result.Violations {
+		files = append(files, filepath.Base(results[0].Pass.Fset.Position(v.Pos).Filename))
+	}
+	require.ElementsMatch(t, []string{"listed_suff.go", "named_suff.go"}, files)
+	require.Len(t, result.Checks, 2)
+	require.Equal(t, map[string]int{"tag1": 2}, result.TagViolations)
+}
+
+func Test_MaxReports(t *testing.T) {
+	analyzer := NewAnalyzer(Config{
+		Filetags:   map[string][]string{"*_suff.go": {"tag1"}},
+		MaxReports: 2,
+	})
+	results := analysistest.Run(t, analysistest.TestData(), analyzer, "filebuildtag_max_reports")
+	require.Len(t, results, 1)
+	result, ok := results[0].Result.(*Result)
+	require.True(t, ok)
+	// The result still lists every violation, only the diagnostics are capped.
+	require.Len(t, result.Violations, 4)
+}
+
+func Test_Resolver(t *testing.T) {
+	resolved := map[string][]string{
+		"filebuildtag_resolver/listed.go":    {"manifest"},
+		"filebuildtag_resolver/missing.go":   {"manifest"},
+		"filebuildtag_resolver/forbidden.go": {"!legacy"},
+		"filebuildtag_resolver/both_suff.go": {"tag1"},
+	}
+	analyzer := NewAnalyzer(Config{
+		Filetags: map[string][]string{"*_suff.go": {"tag1"}},
+		Resolver: func(filename string) []string { return resolved[filename] },
+	})
+	results := analysistest.Run(t, analysistest.TestData(), analyzer, "filebuildtag_resolver")
+	require.Len(t, results, 1)
+	result, ok := results[0].Result.(*Result)
+	require.True(t, ok)
+	require.Len(t, result.Checks, 4)
+}
+
+func Test_Resolver_malformedTag(t *testing.T) {
+	for _, tag := range []string{"foo bar", "${1}"} {
+		t.Run(tag, func(t *testing.T) {
+			analyzer := NewAnalyzer(Config{
+				Resolver: func(string) []string { return []string{tag} },
+			})
+			recorder := &errorsRecorder{}
+			analysistest.Run(recorder, analysistest.TestData(), analyzer, "filebuildtag_resolver")
+			require.NotEmpty(t, recorder.errors)
+			require.Contains(t, recorder.errors[0], `malformed build tag "`+tag+`" resolved for file`)
+		})
+	}
+}
+
+func Test_IncludeTestdata(t *testing.T) {
+	testCases := map[string]struct {
+		includeTestdata bool
+		only            []string
+		expected        []string
+	}{
+		"successfully check the files of the testdata directory": {
+			includeTestdata: true,
+			expected: []string{
+				`testdata/untagged_sample.go: missing expected build tag: "sample" required by pattern "*_sample.go" (file has no build tags)`,
+				`testdata/golden/want_sample.go: missing expected build tag: "golden" required by pattern "filebuildtag_testdata/testdata/golden/*.go" (file has: [sample])`,
 			},
 		},
-		"several file patterns": {
-			flags: newFlagSet(t, "foo:bar,bar:baz"),
-			expected: map[string]string{
-				"foo": "bar",
-				"bar": "baz",
+		"successfully skip the testdata directory without the flag": {},
+		"successfully only report the files of the testdata directory listed by their path": {
+			includeTestdata: true,
+			only:            []string{"filebuildtag_testdata/testdata/golden/want_sample.go"},
+			expected: []string{
+				`testdata/golden/want_sample.go: missing expected build tag: "golden" required by pattern "filebuildtag_testdata/testdata/golden/*.go" (file has: [sample])`,
 			},
 		},
+		"successfully not report the files of the testdata directory listed by their name": {
+			includeTestdata: true,
+			only:            []string{"untagged_sample.go", "want_sample.go"},
+		},
+	}
+	for name, tt := range testCases {
+		t.Run(name, func(t *testing.T) {
+			analyzer := NewAnalyzer(Config{
+				Filetags: map[string][]string{
+					"*_sample.go": {"sample"},
+					"filebuildtag_testdata/testdata/golden/*.go": {"golden"},
+				},
+				IncludeTestdata: tt.includeTestdata,
+				Only:            tt.only,
+			})
+			// The diagnostics of the files of the testdata directory are unexpected, as analysistest only reads the
+			// expectations of the files of the packages.
+			recorder := &errorsRecorder{}
+			results := analysistest.Run(recorder, analysistest.TestData(), analyzer, "filebuildtag_testdata")
+			require.Len(t, results, 1)
+			result, ok := results[0].Result.(*Result)
+			require.True(t, ok)
+
+			dir := filepath.Join(analysistest.TestData(), "src", "filebuildtag_testdata")
+			var violations []string
+			for _, v := range result.Violations {
+				rel, err := filepath.Rel(dir, results[0].Pass.Fset.Position(v.Pos).Filename)
+				require.NoError(t, err)
+				violations = append(violations, filepath.ToSlash(rel)+": "+v.Message)
+			}
+			require.ElementsMatch(t, tt.expected, violations)
+			require.Len(t, recorder.errors, len(tt.expected))
+		})
+	}
+}
+
+func Test_testdataCache(t *testing.T) {
+	cache := &testdataCache{}
+	fset, next := token.NewFileSet(), token.NewFileSet()
+	require.True(t, cache.claim(fset, "foo/testdata"))
+	// The variants of a package loaded by the same run share its file set.
+	require.False(t, cache.claim(fset, "foo/testdata"))
+	require.True(t, cache.claim(fset, "bar/testdata"))
+	// Another run drops the directories of the previous one.
+	require.True(t, cache.claim(next, "foo/testdata"))
+	require.Len(t, cache.dirs, 1)
+	require.True(t, cache.claim(fset, "foo/testdata"))
+}
+
+func Test_FilesCounts(t *testing.T) {
+	testCases := map[string]struct {
+		only            []string
+		expectedChecked int
+		expectedMatched int
+	}{
+		"successfully count the checked and matched files": {
+			expectedChecked: 2,
+			expectedMatched: 1,
+		},
+		"successfully count the files of the only config": {
+			only:            []string{"unmatched.go"},
+			expectedChecked: 1,
+			expectedMatched: 0,
+		},
+	}
+	for name, tt := range testCases {
+		t.Run(name, func(t *testing.T) {
+			analyzer := NewAnalyzer(Config{
+				Filetags: map[string][]string{"*_suff.go": {"tag1"}},
+				Exclude:  []string{"excluded_*.go"},
+				Only:     tt.only,
+			})
+			results := analysistest.Run(t, analysistest.TestData(), analyzer, "filebuildtag_counts")
+			require.Len(t, results, 1)
+			result, ok := results[0].Result.(*Result)
+			require.True(t, ok)
+			require.Equal(t, tt.expectedChecked, result.FilesChecked)
+			require.Equal(t, tt.expectedMatched, result.FilesMatched)
+		})
+	}
+}
+
+func Test_RequireConfig(t *testing.T) {
+	testCases := map[string]struct {
+		config      Config
+		pattern     string
+		expectedErr bool
+	}{
+		"fail when no filetags are configured": {
+			config:      Config{RequireConfig: true},
+			pattern:     "filebuildtag_exact",
+			expectedErr: true,
+		},
+		"successfully check the files when filetags are configured": {
+			config:  Config{RequireConfig: true, Filetags: map[string][]string{"*_none.go": {"tag1"}}},
+			pattern: "filebuildtag_exact",
+		},
+		"successfully check the files when a directory config configures filetags": {
+			config:  Config{RequireConfig: true},
+			pattern: "filebuildtag_dirconfig/sub/deeper",
+		},
+		"successfully check nothing when no filetags are configured without the flag": {
+			config:  Config{},
+			pattern: "filebuildtag_exact",
+		},
 	}
 	for name, tt := range testCases {
 		t.Run(name, func(t *testing.T) {
-			found, err := parseFlags(tt.flags)
-			require.Equal(t, tt.expected, found)
-			if tt.expectedErr != nil {
-				assert.EqualError(t, err, tt.expectedErr.Error())
-			} else {
-				assert.NoError(t, err)
+			recorder := &errorsRecorder{}
+			analysistest.Run(recorder, analysistest.TestData(), NewAnalyzer(tt.config), tt.pattern)
+			if !tt.expectedErr {
+				require.Empty(t, recorder.errors)
+				return
 			}
+			require.Len(t, recorder.errors, 1)
+			require.Contains(t, recorder.errors[0], errNoFiletags.Error())
+		})
+	}
+}
+
+// errorsRecorder records the errors of analysistest rather than failing the test.
+type errorsRecorder struct {
+	errors []string
+}
+
+func (r *errorsRecorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func Test_AggregateMissing(t *testing.T) {
+	analyzer := Analyzer
+	flags := newFlagSet(t, "*_suff.go:tag1+tag2,*_b_suff.go:tag3@warning")
+	require.NoError(t, flags.Set(FlagAggregateMissingName, "true"))
+	analyzer.Flags = flags
+	results := analysistest.Run(t, analysistest.TestData(), analyzer, "filebuildtag_aggregate")
+	require.Len(t, results, 1)
+	result, ok := results[0].Result.(*Result)
+	require.True(t, ok)
+
+	type violation struct {
+		file     string
+		severity Severity
+		patterns []string
+		tag      string
+		tags     []string
+	}
+	var found []violation
+	for _, v := range result.Violations {
+		found = append(found, violation{
+			file:     filepath.Base(results[0].Pass.Fset.Position(v.Pos).Filename),
+			severity: v.Severity,
+			patterns: v.Patterns,
+			tag:      v.Tag,
+			tags:     v.Tags,
 		})
 	}
+	require.ElementsMatch(t, []violation{
+		{file: "none_suff.go", severity: SeverityError, patterns: []string{"*_suff.go"}, tag: "tag1+tag2", tags: []string{"tag1", "tag2"}},
+		{file: "one_suff.go", severity: SeverityError, patterns: []string{"*_suff.go"}, tag: "tag2"},
+		{file: "x_b_suff.go", severity: SeverityError, patterns: []string{"*_suff.go", "*_b_suff.go"}, tag: "tag1+tag3", tags: []string{"tag1", "tag3"}},
+	}, found)
+	require.Equal(t, map[string]int{"tag1": 2, "tag2": 2, "tag3": 1}, result.TagViolations)
 }
 
 func newFlagSet(t *testing.T, args string) flag.FlagSet {
@@ -0,0 +1,25 @@
+package filebuildtag
+
+import (
+	"go/ast"
+
+	"github.com/aziule/filebuildtag/internal"
+)
+
+// BuildTags returns the build tags of a Go file, as found by the analyzer. The file must have been parsed with
+// the parser.ParseComments mode.
+//
+// Both the "//go:build" and the legacy "// +build" forms are supported. The returned tags are the ones referenced
+// by the constraints without being negated, in order of appearance and without duplicates. For example, the tags
+// of "//go:build (linux && amd64) || !cgo" are "linux" and "amd64". Malformed constraint lines are ignored.
+func BuildTags(f *ast.File) []string {
+	constraints, _ := internal.ParseGoFile(f)
+	return constraints.Tags()
+}
+
+// ImplicitTags returns the GOOS and GOARCH tags implied by the name of a Go file, such as "linux" and "amd64" for
+// "foo_linux_amd64.go", as the Go toolchain only builds such files for the given GOOS and GOARCH. It returns nil
+// when the name implies no constraint.
+func ImplicitTags(filename string) []string {
+	return internal.Constraints{Expr: internal.ImplicitConstraint(filename)}.Tags()
+}
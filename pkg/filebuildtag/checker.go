@@ -0,0 +1,912 @@
+package filebuildtag
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/build/constraint"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aziule/filebuildtag/internal"
+	"golang.org/x/tools/go/analysis"
+)
+
+// checker checks the files of a package against the rules, reporting and recording their violations.
+type checker struct {
+	pass  *analysis.Pass
+	opts  options
+	rules *ruleSet
+	// dirRules are the rules of the files of each directory, merged with their directory config if any.
+	dirRules map[string]*ruleSet
+	// used are the patterns matching at least one file of the package.
+	used   map[string]bool
+	result *Result
+	// err is the first error met while loading the directory configs or reading the non-Go files.
+	err error
+	// missing are the missing tags of the file being checked, reported at once when missing tags are aggregated.
+	missing []missingTag
+	// missingReported is whether a missing tag of the file being checked was reported, which is then the only one
+	// reported with the first-only flag.
+	missingReported bool
+	// suppressed is the number of missing tags left unreported because of the first-only flag.
+	suppressed int
+	// timing records the time spent in each phase of the check for the debug-timing flag, nil without the flag.
+	timing *timing
+}
+
+// missingTag is a tag missing from a file, along with the fix adding it.
+type missingTag struct {
+	v   Violation
+	fix analysis.SuggestedFix
+}
+
+// ruleSet are the rules along with their matchers.
+type ruleSet struct {
+	rules       rules
+	patterns    *patternIndex
+	excluded    matcher
+	constrained map[string]matcher
+	groups      map[string]matcher
+	tagPatterns map[string][]string
+}
+
+func newChecker(pass *analysis.Pass, rules rules, opts options) *checker {
+	return &checker{
+		pass:     pass,
+		opts:     opts,
+		rules:    newRuleSet(rules, opts),
+		dirRules: make(map[string]*ruleSet),
+		used:     make(map[string]bool),
+		result:   &Result{TagViolations: make(map[string]int)},
+		timing:   newTiming(opts.debugTiming),
+	}
+}
+
+func newRuleSet(rules rules, opts options) *ruleSet {
+	return &ruleSet{
+		rules:       rules,
+		patterns:    newPatternIndex(rules.patterns(), opts),
+		excluded:    matchAny(newMatchers(rules.excludes, opts)),
+		constrained: newMatchers(rules.constrained, opts),
+		groups:      newMatchers(rules.groups, opts),
+		tagPatterns: patternsByTag(rules.filetags, opts),
+	}
+}
+
+// rulesFor returns the rules of the files of the directory: the rules merged with the config of their module, then
+// with the nearest directory config.
+func (c *checker) rulesFor(dir string) (*ruleSet, error) {
+	if rs, ok := c.dirRules[dir]; ok {
+		return rs, nil
+	}
+	rs := c.rules
+	if configPaths := findDirConfigs(dir); len(configPaths) > 0 {
+		merged := c.rules.rules
+		for _, configPath := range configPaths {
+			dirRules := rules{filetags: make(map[string][]string), defaultSeverity: merged.defaultSeverity}
+			if err := loadConfigFile(configPath, "", &dirRules); err != nil {
+				return nil, err
+			}
+			merged = merged.override(dirRules)
+		}
+		rs = newRuleSet(merged, c.opts)
+	}
+	c.dirRules[dir] = rs
+	return rs, nil
+}
+
+// checkFile checks a single Go file against the rules.
+func (c *checker) checkFile(f *ast.File) {
+	c.checkFileAt(f, getPath(c.pass, f))
+}
+
+// checkFileAt checks a single Go file against the rules, the path of the file relative to the root of its module
+// being given.
+func (c *checker) checkFileAt(f *ast.File, path string) {
+	if !hasPackageClause(f) {
+		return
+	}
+	rs, err := c.rulesFor(filepath.Dir(c.pass.Fset.Position(f.Pos()).Filename))
+	if err != nil {
+		c.fail(err)
+		return
+	}
+	file := file{
+		name:      getFilename(c.pass, f),
+		path:      path,
+		pkg:       f.Name.Name,
+		lines:     c.pass.Fset.Position(f.FileEnd).Line,
+		dirname:   getDirname(c.pass, f),
+		imports:   importPaths(f),
+		generated: ast.IsGenerated(f),
+	}
+	// The generated files are skipped, unless they are included or required to have the generated tag.
+	skipGenerated := file.generated && !c.opts.includeGenerated && c.opts.generatedTag == ""
+	started := c.timing.start()
+	excluded := rs.excluded(file) || ignoredBy(rs.rules.ignores, file)
+	c.timing.stop(phaseMatching, started)
+	if excluded || skipGenerated || isIgnored(c.pass.Fset, f) {
+		return
+	}
+	started = c.timing.start()
+	constraints := internal.CheckGoFile(c.pass, f)
+	c.timing.stop(phaseParsing, started)
+	c.checkConstraints(f, rs, file, constraints)
+}
+
+// checkOtherFile checks a single non-Go file of the package against the rules, such as an assembly file. Such files
+// have no syntax tree, so they are checked through a file only holding the positions the checks use: its start,
+// where the diagnostics and the fixes of the files without build constraints are located.
+func (c *checker) checkOtherFile(filename string) {
+	if filepath.Ext(filename) == ".syso" {
+		// System object files are binary files, which cannot have build constraints.
+		return
+	}
+	rs, err := c.rulesFor(filepath.Dir(filename))
+	if err != nil {
+		c.fail(err)
+		return
+	}
+	content, err := c.pass.ReadFile(filename)
+	if err != nil {
+		c.fail(fmt.Errorf("cannot read file: %w", err))
+		return
+	}
+	tf := c.pass.Fset.AddFile(filename, -1, len(content))
+	tf.SetLinesForContent(content)
+	f := &ast.File{
+		FileStart: tf.Pos(0),
+		FileEnd:   tf.Pos(len(content)),
+		Package:   tf.Pos(0),
+		Name:      &ast.Ident{NamePos: tf.Pos(0), Name: c.pass.Pkg.Name()},
+	}
+	file := file{
+		name:    getFilename(c.pass, f),
+		path:    getPath(c.pass, f),
+		pkg:     f.Name.Name,
+		lines:   c.pass.Fset.Position(f.FileEnd).Line,
+		dirname: getDirname(c.pass, f),
+	}
+	started := c.timing.start()
+	excluded := rs.excluded(file) || ignoredBy(rs.rules.ignores, file)
+	c.timing.stop(phaseMatching, started)
+	if excluded {
+		return
+	}
+	started = c.timing.start()
+	constraints := internal.CheckOtherFile(c.pass, tf, content)
+	c.timing.stop(phaseParsing, started)
+	c.checkConstraints(f, rs, file, constraints)
+}
+
+// importPaths returns the import paths of the file.
+func importPaths(f *ast.File) []string {
+	paths := make([]string, 0, len(f.Imports))
+	for _, spec := range f.Imports {
+		if importPath, err := strconv.Unquote(spec.Path.Value); err == nil {
+			paths = append(paths, importPath)
+		}
+	}
+	return paths
+}
+
+// configured reports whether filetags are configured for the files of the package, by the flags or by the directory
+// configs of the files.
+func (c *checker) configured() bool {
+	if len(c.rules.rules.filetags) > 0 {
+		return true
+	}
+	for _, rs := range c.dirRules {
+		if len(rs.rules.filetags) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// fail records the error, unless an error was already met.
+func (c *checker) fail(err error) {
+	if c.err == nil {
+		c.err = err
+	}
+}
+
+// checkConstraints checks the build constraints of a file against the rules.
+func (c *checker) checkConstraints(f *ast.File, rs *ruleSet, file file, constraints internal.Constraints) {
+	c.missingReported = false
+	if !c.opts.includeIgnored && constraints.Has(ignoreTag) {
+		return
+	}
+	if c.opts.buildContext && !internal.MatchContext(&build.Default, file.name, constraints) {
+		return
+	}
+	constraints = c.opts.ignoring(constraints)
+	if file.generated && !c.opts.includeGenerated {
+		// Only the generated tag is checked on the generated files which are not included.
+		c.checkGeneratedTag(f, constraints, rs.rules, make(map[string]bool))
+		c.reportMissingTags(f, constraints)
+		return
+	}
+	file.tags = constraints.Tags()
+	started := c.timing.start()
+	patterns := rs.patterns.match(file)
+	c.timing.stop(phaseMatching, started)
+	c.result.addFile(f.Pos(), len(patterns) > 0)
+	matched := make(map[string]bool, len(patterns))
+	for _, pattern := range patterns {
+		matched[pattern] = true
+		c.used[pattern] = true
+	}
+
+	// Patterns can overlap, so each tag is reported at most once per file. The rules are checked in order of
+	// appearance, so that the diagnostics are reported in a stable order.
+	checked := c.checkConflictingRules(f, constraints, rs.rules, patterns)
+	for _, rl := range rs.rules.list {
+		if !matched[rl.pattern] {
+			continue
+		}
+		tag := rl.tag
+		if hasGroupRefs(tag) {
+			var ok bool
+			if tag, ok = c.expandGroupRefs(f, constraints, rs.rules, rl, file); !ok {
+				continue
+			}
+		}
+		if checked[c.opts.fold(tag)] {
+			continue
+		}
+		checked[c.opts.fold(tag)] = true
+		c.checkRule(f, constraints, rs.rules.severity(rl.pattern, rl.tag), rl.pattern, tag)
+	}
+	if len(rs.rules.defaults) > 0 && strings.HasSuffix(file.name, ".go") && !matchesFilePattern(patterns) {
+		for _, tag := range rs.rules.defaults {
+			if !checked[c.opts.fold(tag)] {
+				checked[c.opts.fold(tag)] = true
+				c.checkRule(f, constraints, rs.rules.severity(defaultPattern, tag), defaultPattern, tag)
+			}
+		}
+	}
+	if c.opts.resolver != nil {
+		c.checkResolvedTags(f, constraints, file, checked)
+	}
+	if file.generated {
+		c.checkGeneratedTag(f, constraints, rs.rules, checked)
+	}
+	c.reportMissingTags(f, constraints)
+
+	if constraints.Expr == nil {
+		c.checkRequiredConstraint(f, constraints, rs.rules.constrained, rs.constrained, file)
+	}
+	if c.opts.reverse {
+		c.checkUnexpectedTags(f, constraints, rs.tagPatterns, matched)
+	}
+	if c.opts.matchPlusBuild {
+		c.checkPlusBuild(f, constraints)
+	}
+	if c.opts.redundant {
+		c.checkRedundantConstraints(f, constraints, file)
+	}
+	if c.opts.duplicateTags {
+		c.checkDuplicateTags(f, constraints)
+	}
+	if c.opts.requireRationale && len(patterns) > 0 {
+		c.checkRationale(f, constraints)
+	}
+	if c.opts.constraintOrder {
+		c.checkConstraintOrder(f, constraints)
+	}
+	if c.opts.sortedOperands {
+		c.checkSortedOperands(f, constraints)
+	}
+	if c.opts.constraintStyle != "" && c.opts.constraintStyle != styleAny {
+		c.checkConstraintStyle(f, constraints)
+	}
+}
+
+// checkGeneratedTag checks that the generated file has the tag of the generated-tag flag, unless it was already
+// checked or the file has the ignore build tag, which excludes it from all the builds anyway.
+func (c *checker) checkGeneratedTag(f *ast.File, constraints internal.Constraints, rules rules, checked map[string]bool) {
+	tag := c.opts.generatedTag
+	if tag == "" || checked[c.opts.fold(tag)] || constraints.Has(ignoreTag) {
+		return
+	}
+	checked[c.opts.fold(tag)] = true
+	c.checkRule(f, constraints, rules.severity(generatedPattern, tag), generatedPattern, tag)
+}
+
+// checkConflictingRules reports the tags which are both expected and forbidden by the rules of the patterns the file
+// matches, as the file cannot satisfy them. It returns the tags it reported and their forbidden form, which must not
+// be checked again.
+func (c *checker) checkConflictingRules(
+	f *ast.File, constraints internal.Constraints, rules rules, patterns []string,
+) map[string]bool {
+	expected, forbidden := make(map[string]string), make(map[string]string)
+	var tags []string
+	for _, pattern := range patterns {
+		for _, tag := range rules.filetags[pattern] {
+			if t, ok := forbiddenTag(tag); ok {
+				if _, ok := forbidden[c.opts.fold(t)]; !ok {
+					forbidden[c.opts.fold(t)] = pattern
+				}
+			} else if _, ok := expected[c.opts.fold(tag)]; !ok && isTag(tag) && !isExpression(tag) && !hasGroupRefs(tag) {
+				expected[c.opts.fold(tag)] = pattern
+				tags = append(tags, tag)
+			}
+		}
+	}
+
+	checked := make(map[string]bool)
+	for _, tag := range tags {
+		expectedBy := expected[c.opts.fold(tag)]
+		forbiddenBy, ok := forbidden[c.opts.fold(tag)]
+		if !ok {
+			continue
+		}
+		checked[c.opts.fold(tag)], checked[c.opts.fold("!"+tag)] = true, true
+		c.report(f, constraints, Violation{
+			Kind: KindConflictingRules,
+			Message: fmt.Sprintf(`conflicting rules: "%s" expects the build tag "%s" while "%s" forbids it`,
+				expectedBy, tag, forbiddenBy),
+			Patterns: []string{expectedBy, forbiddenBy},
+			Tag:      tag,
+		})
+	}
+	return checked
+}
+
+// expandGroupRefs returns the tag of the rule for the file, its references being replaced by the groups captured by
+// the regular expression of its pattern. When the resulting tag is empty or is not a valid build tag, such as
+// "foo-bar", it reports it and returns false, as no file could have it.
+func (c *checker) expandGroupRefs(
+	f *ast.File, constraints internal.Constraints, rules rules, rl rule, file file,
+) (string, bool) {
+	tag := rules.captures[rl.pattern].expand(rl.tag, file, c.opts.caseInsensitive)
+	name, _ := strings.CutPrefix(tag, "!")
+	if name != "" && validateTag(name) == nil {
+		return tag, true
+	}
+	c.report(f, constraints, Violation{
+		Kind:     KindInvalidCapture,
+		Severity: rules.severity(rl.pattern, rl.tag),
+		Message: fmt.Sprintf(`build tag "%s" of pattern "%s" captures the invalid build tag "%s"`,
+			rl.tag, rl.pattern, tag),
+		Patterns: []string{rl.pattern},
+		Tag:      rl.tag,
+	})
+	return "", false
+}
+
+// checkResolvedTags checks that the file has the tags returned by the resolver of the config, unless the rules already
+// checked them. The tags are parsed like the ones of the rules, a malformed tag failing the analysis as the resolver
+// is then most likely broken.
+func (c *checker) checkResolvedTags(f *ast.File, constraints internal.Constraints, file file, checked map[string]bool) {
+	for _, resolved := range c.opts.resolver(file.path) {
+		tags, warnings, err := addTags(nil, nil, make(map[string]bool), resolved, c.rules.rules.implicitSeverity())
+		if err == nil && hasGroupRefs(resolved) {
+			err = errors.New("resolved build tags cannot refer to the groups of a pattern")
+		}
+		if err != nil {
+			c.fail(fmt.Errorf(`malformed build tag "%s" resolved for file "%s": %w`, resolved, file.path, err))
+			return
+		}
+		for _, tag := range tags {
+			if checked[c.opts.fold(tag)] {
+				continue
+			}
+			checked[c.opts.fold(tag)] = true
+			severity := SeverityError
+			if contains(warnings, tag) {
+				severity = SeverityWarning
+			}
+			c.checkRule(f, constraints, severity, resolverPattern, tag)
+		}
+	}
+}
+
+// checkRule checks that the file has the tag of a rule, reporting its violations with the severity, and records the
+// check.
+func (c *checker) checkRule(f *ast.File, constraints internal.Constraints, severity Severity, pattern, tag string) {
+	violations, suppressed := len(c.result.Violations), c.suppressed
+	c.checkTag(f, constraints, pattern, tag, severity)
+	c.result.Checks = append(c.result.Checks, Check{
+		Pos:      reportPos(f, constraints),
+		File:     c.pass.Fset.Position(f.Pos()).Filename,
+		Pattern:  pattern,
+		Tag:      tag,
+		Severity: severity,
+		Found:    constraints.Tags(),
+		Passed:   len(c.result.Violations) == violations && c.suppressed == suppressed,
+	})
+}
+
+// checkTag checks that the file has the tag of a rule, reporting its violations with the severity.
+func (c *checker) checkTag(f *ast.File, constraints internal.Constraints, pattern, tag string, severity Severity) {
+	if isExpression(tag) {
+		c.checkExpression(f, constraints, pattern, tag, severity)
+		return
+	}
+	if group, tags, ok := tagGroup(tag); ok {
+		c.checkTagGroup(f, constraints, pattern, tag, group, tags, severity)
+		return
+	}
+	if forbidden, ok := forbiddenTag(tag); ok {
+		if c.opts.has(constraints, forbidden) {
+			match := func(tag string) bool { return c.opts.fold(tag) == c.opts.fold(forbidden) }
+			c.report(f, constraints, Violation{
+				Kind:     KindForbiddenTag,
+				Severity: severity,
+				Message:  fmt.Sprintf(`forbidden build tag: "%s"`, forbidden),
+				Patterns: []string{pattern},
+				Tag:      forbidden,
+			}, removeTagFix(c.pass, f, constraints, forbidden, match))
+		}
+		return
+	}
+	if !c.opts.satisfies(constraints, tag) {
+		v := Violation{
+			Kind:     KindMissingTag,
+			Severity: severity,
+			Message:  missingTagMessage(tag, pattern, constraints),
+			Patterns: []string{pattern},
+			Tag:      tag,
+		}
+		fix := addTagFix(c.pass, f, constraints, tag)
+		if c.opts.aggregateMissing {
+			c.missing = append(c.missing, missingTag{v: v, fix: fix})
+			return
+		}
+		c.report(f, constraints, v, fix)
+	}
+}
+
+// reportMissingTags reports the missing tags of the file aggregated by checkTag, as a single violation listing all
+// of them when there are several ones. Its severity is the error one unless all the tags are warnings, and its fix
+// adds all of them.
+func (c *checker) reportMissingTags(f *ast.File, constraints internal.Constraints) {
+	missing := c.missing
+	c.missing = nil
+	switch len(missing) {
+	case 0:
+		return
+	case 1:
+		c.report(f, constraints, missing[0].v, missing[0].fix)
+		return
+	}
+	v := Violation{Kind: KindMissingTag, Severity: SeverityWarning}
+	for _, m := range missing {
+		v.Tags = append(v.Tags, m.v.Tag)
+		for _, pattern := range m.v.Patterns {
+			if !contains(v.Patterns, pattern) {
+				v.Patterns = append(v.Patterns, pattern)
+			}
+		}
+		if m.v.Severity == SeverityError {
+			v.Severity = SeverityError
+		}
+	}
+	v.Tag = strings.Join(v.Tags, "+")
+	by := fmt.Sprintf(`pattern "%s"`, v.Patterns[0])
+	if len(v.Patterns) > 1 {
+		by = "patterns " + quoteAll(v.Patterns)
+	}
+	v.Message = fmt.Sprintf(`missing expected build tags: %s required by %s (%s)`, quoteAll(v.Tags), by, foundTags(constraints))
+	c.report(f, constraints, v, addTagFix(c.pass, f, constraints, v.Tags...))
+}
+
+// checkTagGroup checks that the file has exactly one, at least one or all of the tags of a tag group, depending on
+// the group.
+func (c *checker) checkTagGroup(
+	f *ast.File, constraints internal.Constraints, pattern, tag, group string, tags []string, severity Severity,
+) {
+	var present, missing []string
+	for _, t := range tags {
+		if c.opts.has(constraints, t) {
+			present = append(present, t)
+		} else {
+			missing = append(missing, t)
+		}
+	}
+	v := Violation{Kind: KindMissingTag, Severity: severity, Patterns: []string{pattern}, Tag: tag}
+	switch {
+	case group == allOf && len(missing) > 0:
+		v.Message = fmt.Sprintf(`missing expected build tags %s of "%s" required by pattern "%s" (%s)`,
+			quoteAll(missing), tag, pattern, foundTags(constraints))
+	case group != allOf && len(present) == 0:
+		v.Message = fmt.Sprintf(`missing %s of the expected build tags of "%s" required by pattern "%s" (%s)`,
+			groupQuantifier[group], tag, pattern, foundTags(constraints))
+	case group == oneOf && len(present) > 1:
+		v.Kind = KindConflictingTags
+		v.Message = fmt.Sprintf(`conflicting build tags %s of "%s", only one is expected`, quoteAll(present), tag)
+	default:
+		return
+	}
+	c.report(f, constraints, v)
+}
+
+// groupQuantifier describes the number of tags of the tag groups files must have at least.
+var groupQuantifier = map[string]string{oneOf: "one", anyOf: "any"}
+
+// checkExpression checks that the build constraints of the file are equivalent to the expected expression, which
+// must be in its canonical form.
+func (c *checker) checkExpression(
+	f *ast.File, constraints internal.Constraints, pattern, expected string, severity Severity,
+) {
+	actual := internal.Canonical(constraints.Expr)
+	if actual == expected {
+		return
+	}
+	msg := fmt.Sprintf(`build constraint "%s" does not match the expected "%s"`, actual, expected)
+	if constraints.Expr == nil {
+		msg = fmt.Sprintf(`missing expected build constraint: "%s"`, expected)
+	}
+	var fixes []analysis.SuggestedFix
+	if expr, err := constraint.Parse("//go:build " + expected); err == nil {
+		fixes = append(fixes, replaceConstraintsFix(c.pass, f, constraints, expr))
+	}
+	c.report(f, constraints, Violation{
+		Kind:     KindConstraintMismatch,
+		Severity: severity,
+		Message:  msg,
+		Patterns: []string{pattern},
+		Tag:      expected,
+	}, fixes...)
+}
+
+// checkUnexpectedTags checks that the file does not have tags expected by patterns it does not match.
+func (c *checker) checkUnexpectedTags(
+	f *ast.File, constraints internal.Constraints, tagPatterns map[string][]string, matched map[string]bool,
+) {
+	tags := make([]string, 0, len(tagPatterns))
+	for tag := range tagPatterns {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	for _, tag := range tags {
+		patterns := tagPatterns[tag]
+		if !c.opts.has(constraints, tag) || matchesAny(matched, patterns) {
+			continue
+		}
+		c.report(f, constraints, Violation{
+			Kind:     KindUnexpectedTag,
+			Message:  fmt.Sprintf(`unexpected build tag: "%s", only expected on files matching %s`, tag, quoteAll(patterns)),
+			Patterns: patterns,
+			Tag:      tag,
+		})
+	}
+}
+
+// checkPlusBuild checks that the "//go:build" and "// +build" constraints of the file are equivalent when it has
+// both of them. The suggested fix rewrites the "// +build" lines from the "//go:build" line, like gofmt does.
+func (c *checker) checkPlusBuild(f *ast.File, constraints internal.Constraints) {
+	if constraints.GoBuild == nil || constraints.PlusBuild == nil {
+		return
+	}
+	goBuild, plusBuild := internal.Canonical(constraints.GoBuild), internal.Canonical(constraints.PlusBuild)
+	if goBuild == plusBuild {
+		return
+	}
+	c.report(f, constraints, Violation{
+		Kind:    KindMismatchedPlusBuild,
+		Message: fmt.Sprintf(`//go:build constraint "%s" does not match the // +build constraint "%s"`, goBuild, plusBuild),
+		Tag:     goBuild,
+	}, replaceConstraintsFix(c.pass, f, constraints, constraints.GoBuild))
+}
+
+// checkConstraintOrder checks that the build constraint lines of the file are in the canonical order gofmt emits:
+// the "//go:build" line first, directly followed by the "// +build" lines, if any. Files without a "//go:build" line
+// are left out, as gofmt would add one. The suggested fix rewrites the lines from the "//go:build" line.
+func (c *checker) checkConstraintOrder(f *ast.File, constraints internal.Constraints) {
+	if constraints.GoBuild == nil || len(constraints.Comments) < 2 {
+		return
+	}
+	var msg string
+	first := c.pass.Fset.Position(constraints.Comments[0].Pos()).Line
+	for i, comment := range constraints.Comments[1:] {
+		if constraint.IsGoBuild(comment.Text) {
+			msg = "//go:build comment must precede the // +build comments"
+			break
+		}
+		if c.pass.Fset.Position(comment.Pos()).Line != first+i+1 {
+			msg = "// +build comments must directly follow the //go:build comment"
+			break
+		}
+	}
+	if msg == "" {
+		return
+	}
+	c.report(f, constraints, Violation{
+		Kind:    KindMisorderedConstraints,
+		Message: msg,
+		Tag:     internal.Canonical(constraints.GoBuild),
+	}, replaceConstraintsFix(c.pass, f, constraints, constraints.GoBuild))
+}
+
+// checkSortedOperands checks that the operands of each "&&" and "||" chain of the effective build constraints are in
+// lexical order, as defined by internal.Sorted. The "//go:build" line is the effective one when the file has both
+// forms. The suggested fix rewrites the lines from the sorted expression.
+func (c *checker) checkSortedOperands(f *ast.File, constraints internal.Constraints) {
+	expr := constraints.GoBuild
+	if expr == nil {
+		expr = constraints.PlusBuild
+	}
+	if expr == nil {
+		return
+	}
+	sorted, ok := internal.Sorted(expr)
+	if ok {
+		return
+	}
+	c.report(f, constraints, Violation{
+		Kind:    KindUnsortedOperands,
+		Message: fmt.Sprintf(`build constraint operands are not sorted: "%s" rather than "%s"`, expr, sorted),
+		Tag:     sorted.String(),
+	}, replaceConstraintsFix(c.pass, f, constraints, sorted))
+}
+
+// The constraint styles of the constraint-style flag: any style, only the "//go:build" line, or both the
+// "//go:build" line and the "// +build" lines for the toolchains older than Go 1.17.
+const (
+	styleAny     = "any"
+	styleNewOnly = "new-only"
+	styleBoth    = "both"
+)
+
+// checkConstraintStyle checks that the build constraint lines of the file, if any, are of the style required by the
+// constraint-style flag. The suggested fix rewrites them in the required style from the "//go:build" line, or from
+// the "// +build" lines when the file has none.
+func (c *checker) checkConstraintStyle(f *ast.File, constraints internal.Constraints) {
+	hasGoBuild, hasPlusBuild := constraints.GoBuild != nil, constraints.PlusBuild != nil
+	if !hasGoBuild && !hasPlusBuild {
+		return
+	}
+	expr := constraints.GoBuild
+	if !hasGoBuild {
+		expr = constraints.PlusBuild
+	}
+	var msg string
+	switch {
+	case c.opts.constraintStyle == styleNewOnly && hasPlusBuild:
+		msg = `// +build lines are not allowed by the "new-only" constraint style, only the //go:build line is`
+	case c.opts.constraintStyle == styleBoth && !hasGoBuild:
+		msg = `missing //go:build line, required along with the // +build lines by the "both" constraint style`
+	case c.opts.constraintStyle == styleBoth && !hasPlusBuild:
+		msg = `missing // +build lines, required along with the //go:build line by the "both" constraint style`
+	default:
+		return
+	}
+	c.report(f, constraints, Violation{
+		Kind:    KindConstraintStyle,
+		Message: msg,
+		Tag:     internal.Canonical(expr),
+	}, styleFix(c.pass, f, constraints, expr, c.opts.constraintStyle == styleBoth))
+}
+
+// checkRequiredConstraint reports the file, which has no build constraint, when it matches the patterns of the files
+// which must have one, whatever its tags.
+func (c *checker) checkRequiredConstraint(
+	f *ast.File, constraints internal.Constraints, patterns []string, matchers map[string]matcher, file file,
+) {
+	var matched []string
+	for _, pattern := range patterns {
+		if match, ok := matchers[pattern]; ok && match(file) {
+			matched = append(matched, pattern)
+		}
+	}
+	if len(matched) == 0 {
+		return
+	}
+	c.report(f, constraints, Violation{
+		Kind:     KindMissingConstraint,
+		Message:  fmt.Sprintf(`missing build constraint: a build constraint is required by %s, whatever its tags`, quoteAll(matched)),
+		Patterns: matched,
+	})
+}
+
+// checkRedundantConstraints checks that the build constraints of the file do not merely duplicate the constraint
+// implied by its name.
+func (c *checker) checkRedundantConstraints(f *ast.File, constraints internal.Constraints, file file) {
+	implicit := internal.ImplicitConstraint(file.name)
+	if implicit == nil || constraints.Expr == nil {
+		return
+	}
+	if canonical := internal.Canonical(constraints.Expr); canonical == internal.Canonical(implicit) {
+		filename := formatPath(c.pass, f, file.path, c.opts.pathFormat)
+		c.report(f, constraints, Violation{
+			Kind:    KindRedundantConstraint,
+			Message: fmt.Sprintf(`build constraint "%s" is redundant with the file name "%s"`, canonical, filename),
+			Tag:     canonical,
+		})
+	}
+}
+
+// checkDuplicateTags checks that the build constraints of the file reference each tag at most once. Though harmless
+// to the Go toolchain, a repeated tag is most likely left over from a merge.
+func (c *checker) checkDuplicateTags(f *ast.File, constraints internal.Constraints) {
+	for _, tag := range constraints.DuplicateTags() {
+		c.report(f, constraints, Violation{
+			Kind:    KindDuplicateTag,
+			Message: fmt.Sprintf(`duplicate build tag "%s": it is referenced more than once by the build constraints`, tag),
+			Tag:     tag,
+		})
+	}
+}
+
+// checkRationale checks that the build constraint lines of the file, if any, are adjoined by a comment explaining
+// them, such as "// Requires docker." right before "//go:build integration". The rationale cannot trail the lines,
+// as the Go toolchain rejects such constraints.
+func (c *checker) checkRationale(f *ast.File, constraints internal.Constraints) {
+	if constraints.Expr == nil || constraints.Rationale != "" {
+		return
+	}
+	c.report(f, constraints, Violation{
+		Kind:    KindMissingRationale,
+		Message: fmt.Sprintf(`build constraint "%s" has no rationale comment adjoining it`, constraints.Expr),
+	})
+}
+
+// reportUnusedPatterns reports the patterns that did not match any file of the package, at the package clause of
+// its first file. As the analysis runs package per package, a pattern can be unused in some packages only. Patterns
+// of the directory configs are not reported.
+func (c *checker) reportUnusedPatterns() {
+	if len(c.pass.Files) == 0 {
+		return
+	}
+	patterns := c.rules.rules.patterns()
+	sort.Strings(patterns)
+	for _, pattern := range patterns {
+		if c.used[pattern] {
+			continue
+		}
+		v := Violation{
+			Kind:     KindUnusedPattern,
+			Severity: c.rules.rules.implicitSeverity(),
+			Pos:      c.pass.Files[0].Package,
+			Message:  fmt.Sprintf(`pattern "%s" does not match any file of the package`, pattern),
+			Patterns: []string{pattern},
+		}
+		v.Message = c.prefixed(v)
+		c.pass.Report(analysis.Diagnostic{Pos: v.Pos, Category: category(v), Message: v.Message})
+		c.result.add(v)
+	}
+}
+
+// prefixed returns the message of the violation, prefixed with its severity for the severity-prefix flag when it is
+// the warning one, such as "warning: missing expected build tag: ...". The runners which cannot read the severities
+// of the analyzer, such as golangci-lint, can then match them on the messages.
+func (c *checker) prefixed(v Violation) string {
+	if c.opts.severityPrefix && v.Severity == SeverityWarning {
+		return string(SeverityWarning) + ": " + v.Message
+	}
+	return v.Message
+}
+
+// report reports the violation of a rule by the file, along with the fixes, and records it. With the first-only flag,
+// the missing tags following the first one reported for the file are left out.
+func (c *checker) report(f *ast.File, constraints internal.Constraints, v Violation, fixes ...analysis.SuggestedFix) {
+	if v.Kind == KindMissingTag && c.opts.firstOnly {
+		if c.missingReported {
+			c.suppressed++
+			return
+		}
+		c.missingReported = true
+	}
+	v.Pos = reportPos(f, constraints)
+	if v.Severity == "" {
+		v.Severity = c.rules.rules.implicitSeverity()
+	}
+	v.Found = constraints.Tags()
+	if len(v.Patterns) == 1 && v.Kind != KindUnexpectedTag {
+		if antecedent, ok := conditionTag(v.Patterns[0]); ok {
+			v.Message += fmt.Sprintf(`, as the file has the build tag "%s"`, antecedent)
+		}
+	}
+	v.Message = c.prefixed(v)
+	c.pass.Report(analysis.Diagnostic{
+		Pos:            v.Pos,
+		Category:       category(v),
+		Message:        v.Message,
+		SuggestedFixes: fixes,
+	})
+	c.result.add(v)
+}
+
+// category returns the category of the diagnostic of the violation, for the tools filtering the diagnostics: the
+// name of the linter followed by the tag for the violations about a single build tag, such as
+// "filebuildtag/integration", or by the kind of the violation otherwise, such as "filebuildtag/constraint-mismatch".
+func category(v Violation) string {
+	switch v.Kind {
+	case KindMissingTag, KindForbiddenTag, KindUnexpectedTag, KindConflictingRules, KindDuplicateTag:
+		if isTag(v.Tag) && validateTag(v.Tag) == nil {
+			return Name + "/" + v.Tag
+		}
+	}
+	return Name + "/" + string(v.Kind)
+}
+
+// patternsByTag returns the sorted patterns expecting each tag of the filetags, including the tags of the tag groups.
+// Forbidden tags, expressions and tag templates, whose tags depend on the files, are left out.
+func patternsByTag(filetags map[string][]string, opts options) map[string][]string {
+	tagPatterns := make(map[string][]string)
+	for pattern, tags := range filetags {
+		for _, tag := range tags {
+			if _, ok := forbiddenTag(tag); ok || isExpression(tag) || hasGroupRefs(tag) {
+				continue
+			}
+			expected := []string{tag}
+			if _, members, ok := tagGroup(tag); ok {
+				expected = members
+			}
+			for _, tag := range expected {
+				if !contains(tagPatterns[opts.fold(tag)], pattern) {
+					tagPatterns[opts.fold(tag)] = append(tagPatterns[opts.fold(tag)], pattern)
+				}
+			}
+		}
+	}
+	for _, patterns := range tagPatterns {
+		sort.Strings(patterns)
+	}
+	return tagPatterns
+}
+
+// defaultPattern stands for the pattern of the default tags in the violations.
+const defaultPattern = "(default)"
+
+// resolverPattern stands for the pattern of the tags returned by the resolver of the config in the violations.
+const resolverPattern = "(resolver)"
+
+// generatedPattern stands for the pattern of the generated tag in the violations.
+const generatedPattern = "(generated)"
+
+// matchesFilePattern reports whether the patterns a file matches include a pattern other than the ones of the
+// conditional rules, which match the tags of the files rather than the files themselves. Such files are covered by
+// the rules, so the default tags do not apply to them, whether they satisfy the rules or not.
+func matchesFilePattern(patterns []string) bool {
+	for _, pattern := range patterns {
+		if _, ok := conditionTag(pattern); !ok {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesAny(matched map[string]bool, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matched[pattern] {
+			return true
+		}
+	}
+	return false
+}
+
+// quoteAll returns the comma-separated list of the quoted values.
+func quoteAll(values []string) string {
+	quoted := make([]string, 0, len(values))
+	for _, v := range values {
+		quoted = append(quoted, `"`+v+`"`)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// missingTagMessage returns the message reported when the tag expected by the pattern is missing, listing the tags
+// the file has and suggesting a present tag when it looks like a typo of the missing one.
+func missingTagMessage(tag, pattern string, constraints internal.Constraints) string {
+	msg := fmt.Sprintf(`missing expected build tag: "%s" required by pattern "%s" (%s`, tag, pattern, foundTags(constraints))
+	if closest, ok := closestTag(tag, constraints.Tags()); ok {
+		msg += fmt.Sprintf(`, did you mean "%s"?`, closest)
+	}
+	return msg + ")"
+}
+
+// foundTags describes the tags the file has, to help fixing it.
+func foundTags(constraints internal.Constraints) string {
+	present := constraints.Tags()
+	if len(present) == 0 {
+		return "file has no build tags"
+	}
+	return fmt.Sprintf("file has: [%s]", strings.Join(present, ", "))
+}
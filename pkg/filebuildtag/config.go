@@ -0,0 +1,1605 @@
+package filebuildtag
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/build/constraint"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aziule/filebuildtag/internal"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the configuration of an analyzer created using NewAnalyzer, as an alternative to the flags.
+type Config struct {
+	// Filetags binds file patterns to their expected build tags, using the same forms as the filetags flag without
+	// escaping, such as "*_test.go": {"unit", "!integration"} or "*_env.go": {"oneof(dev,prod)"}. As maps have no
+	// order, the rules are applied in sorted pattern order, such as for the first-only flag reporting the first missing
+	// tag of a file. The Rules keep the order they are written in instead.
+	Filetags map[string][]string
+	// Rules are rules of the same forms as the Filetags, applied in order after them, like the filetags flag.
+	Rules []Rule
+	// Exclude are the patterns of the files to skip, whatever the filetags they match.
+	Exclude []string
+	// IgnoreFile is the equivalent of the ignore-file flag.
+	IgnoreFile string
+	// RequireConstraint are the patterns of the files which must have a build constraint, whatever its tags.
+	RequireConstraint []string
+	// PlatformGroup are the patterns of the groups of files which must build for each of the Platforms once. The files
+	// of the regular expressions capturing groups are split into a group for each of the values they capture.
+	PlatformGroup []string
+	// Platforms is the equivalent of the platforms flag.
+	Platforms []string
+	// Only are the only files to report, like the only flag, such as "internal/foo.go" or "@changed.txt". All the
+	// files are reported when nil, none when empty.
+	Only []string
+	// DefaultTag is the equivalent of the default-tag flag.
+	DefaultTag string
+	// Reverse is the equivalent of the reverse flag.
+	Reverse bool
+	// CaseInsensitive is the equivalent of the case-insensitive flag.
+	CaseInsensitive bool
+	// UnusedPatterns is the equivalent of the unused-patterns flag.
+	UnusedPatterns bool
+	// MatchPlusBuild is the equivalent of the match-plus-build flag.
+	MatchPlusBuild bool
+	// Redundant is the equivalent of the redundant flag.
+	Redundant bool
+	// ConstraintOrder is the equivalent of the constraint-order flag.
+	ConstraintOrder bool
+	// SortedOperands is the equivalent of the sorted-operands flag.
+	SortedOperands bool
+	// ConstraintStyle is the equivalent of the constraint-style flag, "any" when empty.
+	ConstraintStyle string
+	// PathFormat is the equivalent of the path-format flag, "base" when empty.
+	PathFormat string
+	// DefaultSeverity is the equivalent of the default-severity flag, the error severity when empty.
+	DefaultSeverity Severity
+	// SeverityPrefix is the equivalent of the severity-prefix flag.
+	SeverityPrefix bool
+	// UnixTag is the equivalent of the unix-tag flag.
+	UnixTag bool
+	// TagAliases binds expected tags to the tags satisfying them too, like the tag-alias flag, such as
+	// "e2e": {"end_to_end"}.
+	TagAliases map[string][]string
+	// IgnoreTags is the equivalent of the ignore-tags flag.
+	IgnoreTags []string
+	// AggregateMissing is the equivalent of the aggregate-missing flag.
+	AggregateMissing bool
+	// FirstOnly is the equivalent of the first-only flag.
+	FirstOnly bool
+	// MaxReports is the equivalent of the max-reports flag, every diagnostic being reported when 0.
+	MaxReports int
+	// DuplicateTags is the equivalent of the duplicate-tags flag.
+	DuplicateTags bool
+	// RequireRationale is the equivalent of the require-rationale flag.
+	RequireRationale bool
+	// RequireConfig is the equivalent of the require-config flag.
+	RequireConfig bool
+	// IncludeGenerated is the equivalent of the include-generated flag.
+	IncludeGenerated bool
+	// GeneratedTag is the equivalent of the generated-tag flag.
+	GeneratedTag string
+	// IncludeIgnored is the equivalent of the include-ignored flag.
+	IncludeIgnored bool
+	// IncludeTestdata is the equivalent of the include-testdata flag.
+	IncludeTestdata bool
+	// BuildContext is the equivalent of the build-context flag.
+	BuildContext bool
+	// DebugTiming is the equivalent of the debug-timing flag.
+	DebugTiming bool
+	// Resolver returns the tags expected on a file in addition to the ones of the rules, given its path relative to
+	// the root of its module, using the same forms as the Filetags, such as "unit", "!integration" or
+	// "oneof(dev,prod)". It is the escape hatch of the conventions no pattern can express, such as the ones of an
+	// external manifest, and has no flag equivalent. Its violations have the "(resolver)" pattern.
+	Resolver func(filename string) []string
+}
+
+// Rule binds a file pattern to its expected build tags, like an entry of the Filetags of a Config.
+type Rule struct {
+	// Pattern is the pattern of the files, such as "*_test.go".
+	Pattern string
+	// Tags are the build tags expected on the files, such as {"unit", "!integration"}.
+	Tags []string
+}
+
+// rules validates and returns the rules of the config.
+func (cfg Config) rules() (rules, error) {
+	r := rules{filetags: make(map[string][]string), defaultSeverity: cfg.DefaultSeverity}
+	if err := validateDefaultSeverity(r.defaultSeverity); err != nil {
+		return rules{}, err
+	}
+	patterns := make([]string, 0, len(cfg.Filetags))
+	for pattern := range cfg.Filetags {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+	list := make([]Rule, 0, len(patterns)+len(cfg.Rules))
+	for _, pattern := range patterns {
+		list = append(list, Rule{Pattern: pattern, Tags: cfg.Filetags[pattern]})
+	}
+	for _, rl := range append(list, cfg.Rules...) {
+		for _, tags := range rl.Tags {
+			if err := r.addFiletag(strings.TrimSpace(rl.Pattern), tags); err != nil {
+				return rules{}, &RuleError{Rule: rl.Pattern + ": " + tags, Err: err, context: "malformed rule"}
+			}
+		}
+	}
+	for _, exclude := range cfg.Exclude {
+		if err := r.addExclude(strings.TrimSpace(exclude)); err != nil {
+			return rules{}, fmt.Errorf(`malformed exclude: "%s", %w`, exclude, err)
+		}
+	}
+	for _, pattern := range cfg.RequireConstraint {
+		if err := r.addConstrained(strings.TrimSpace(pattern)); err != nil {
+			return rules{}, fmt.Errorf(`malformed required constraint pattern: "%s", %w`, pattern, err)
+		}
+	}
+	for _, pattern := range cfg.PlatformGroup {
+		if err := r.addGroup(strings.TrimSpace(pattern)); err != nil {
+			return rules{}, fmt.Errorf(`malformed platform group pattern: "%s", %w`, pattern, err)
+		}
+	}
+	if cfg.Only != nil {
+		only, err := parseOnly(strings.Join(cfg.Only, ","))
+		if err != nil {
+			return rules{}, err
+		}
+		r.only = only
+	}
+	if cfg.IgnoreFile != "" {
+		ignores, err := loadIgnoreFile(cfg.IgnoreFile)
+		if err != nil {
+			return rules{}, err
+		}
+		r.ignores = ignores
+	}
+	if strings.TrimSpace(cfg.DefaultTag) != "" {
+		if err := r.addDefaultTags(cfg.DefaultTag); err != nil {
+			return rules{}, fmt.Errorf(`malformed default tag: "%s", %w`, cfg.DefaultTag, err)
+		}
+	}
+	return r, nil
+}
+
+// options returns the options of the config.
+func (cfg Config) options() options {
+	return options{
+		reverse:          cfg.Reverse,
+		caseInsensitive:  cfg.CaseInsensitive,
+		unusedPatterns:   cfg.UnusedPatterns,
+		matchPlusBuild:   cfg.MatchPlusBuild,
+		redundant:        cfg.Redundant,
+		constraintOrder:  cfg.ConstraintOrder,
+		sortedOperands:   cfg.SortedOperands,
+		constraintStyle:  cfg.ConstraintStyle,
+		pathFormat:       cfg.PathFormat,
+		severityPrefix:   cfg.SeverityPrefix,
+		unixTag:          cfg.UnixTag,
+		aliases:          aliasEntries(cfg.TagAliases),
+		ignoredTags:      trimValues(cfg.IgnoreTags),
+		aggregateMissing: cfg.AggregateMissing,
+		firstOnly:        cfg.FirstOnly,
+		maxReports:       cfg.MaxReports,
+		duplicateTags:    cfg.DuplicateTags,
+		requireRationale: cfg.RequireRationale,
+		platforms:        trimValues(cfg.Platforms),
+		requireConfig:    cfg.RequireConfig,
+		includeGenerated: cfg.IncludeGenerated,
+		generatedTag:     strings.TrimSpace(cfg.GeneratedTag),
+		includeIgnored:   cfg.IncludeIgnored,
+		includeTestdata:  cfg.IncludeTestdata,
+		buildContext:     cfg.BuildContext,
+		debugTiming:      cfg.DebugTiming,
+		resolver:         cfg.Resolver,
+	}
+}
+
+// rules are the parsed filetags and filetags-config flags.
+type rules struct {
+	// filetags binds file patterns to their expected build tags.
+	filetags map[string][]string
+	// list are the rules of the filetags, one per tag, in order of appearance: the ones of the config file first,
+	// then the ones of the filetags flag. The filetags are their index by pattern.
+	list []rule
+	// excludes are the patterns of the files to skip, whatever the filetags they match.
+	excludes []string
+	// defaults are the tags expected on the Go files matching no pattern, conditional rules aside.
+	defaults []string
+	// ignores are the patterns of the ignore file, whose files are skipped.
+	ignores []string
+	// constrained are the patterns of the files which must have a build constraint, whatever its tags.
+	constrained []string
+	// groups are the patterns of the platform groups, whose files must build for each platform once.
+	groups []string
+	// groupCaptures are the regular expressions of the patterns of the platform groups capturing groups, whose files
+	// are split into a platform group for each of the values they capture.
+	groupCaptures map[string]capture
+	// only are the only files to report, all of them being reported when nil.
+	only []string
+	// warnings are the tags of each pattern having the warning severity, the other ones having the error severity.
+	// The default tags are found at the defaultPattern.
+	warnings map[string][]string
+	// defaultSeverity is the severity of the tags given no severity, and of the violations of no tag, the error
+	// severity when empty. It must be set before the tags are added.
+	defaultSeverity Severity
+	// captures are the regular expressions of the patterns whose tags refer to the groups they capture.
+	captures map[string]capture
+	// indexed are the tags of each pattern, the default tags being found at the defaultPattern, so that repeating a
+	// pattern does not take quadratic time. They are indexed lazily, as the merged rules do not index them.
+	indexed map[string]map[string]bool
+}
+
+// parseFlags parses the filetags, filetags-config and exclude flags into a map of file patterns to their expected build
+// tags. A pattern can be bound to several tags, either using the "pattern:tag1+tag2" form or by repeating the pattern.
+// Tags prefixed with "!" are forbidden rather than expected. Tags of the form "oneof(tag1,tag2)", "anyof(tag1,tag2)"
+// and "allof(tag1,tag2)" are groups of tags of which files must have exactly one, at least one or all of them. Tags
+// containing "&&" or "||" are a build constraint expression which files must have, stored in its canonical form.
+// Patterns prefixed with "re:" are regular expressions, whose groups can be referenced by the tags such as "$1", and
+// the ones prefixed with "test:" or "nontest:" only match test files or the other files. Patterns prefixed with "pkg:"
+// match the package name of the files instead of their name. Conditional rules of the form "tag:tag1=>tag2" bind the
+// files having a build tag to other tags, using the "tag:tag1" pattern. References to environment variables such as
+// "${TEAM}" are expanded in both the patterns and the tags. Arguments of the form "!pattern" exclude the files matching
+// the pattern from every rule. Arguments can end with a comment starting with "#". Commas, colons and "#" escaped with
+// a backslash are part of the patterns and tags rather than separators.
+//
+// When both flags are provided, their rules are merged: a pattern found in both of them must have the tags of each, and
+// the excludes of both apply, along with the ones of the exclude flag.
+func parseFlags(flags flag.FlagSet) (rules, error) {
+	r := rules{filetags: make(map[string][]string), defaultSeverity: Severity(stringFlag(flags, FlagDefaultSeverityName))}
+	if err := validateDefaultSeverity(r.defaultSeverity); err != nil {
+		return rules{}, err
+	}
+	profile := strings.TrimSpace(stringFlag(flags, FlagProfileName))
+	if f := flags.Lookup(FlagFiletagsConfigName); f != nil && f.Value.String() != "" {
+		if err := loadConfigFile(f.Value.String(), profile, &r); err != nil {
+			return rules{}, err
+		}
+	} else if profile != "" {
+		return rules{}, fmt.Errorf(`profile "%s" requires the filetags-config flag`, profile)
+	}
+
+	if f := flags.Lookup(FlagExcludeName); f != nil {
+		for _, exclude := range splitArgs(f.Value.String()) {
+			if exclude = strings.TrimSpace(stripComment(exclude)); exclude == "" {
+				continue
+			}
+			if err := r.addExclude(unescape(exclude)); err != nil {
+				return rules{}, fmt.Errorf(`malformed exclude: "%s", %w`, exclude, err)
+			}
+		}
+	}
+
+	if f := flags.Lookup(FlagRequireConstraintName); f != nil {
+		for _, pattern := range splitArgs(f.Value.String()) {
+			if pattern = strings.TrimSpace(stripComment(pattern)); pattern == "" {
+				continue
+			}
+			if err := r.addConstrained(unescape(pattern)); err != nil {
+				return rules{}, fmt.Errorf(`malformed required constraint pattern: "%s", %w`, pattern, err)
+			}
+		}
+	}
+
+	if f := flags.Lookup(FlagPlatformGroupName); f != nil {
+		for _, pattern := range splitArgs(f.Value.String()) {
+			if pattern = strings.TrimSpace(stripComment(pattern)); pattern == "" {
+				continue
+			}
+			if err := r.addGroup(unescape(pattern)); err != nil {
+				return rules{}, fmt.Errorf(`malformed platform group pattern: "%s", %w`, pattern, err)
+			}
+		}
+	}
+
+	if f := flags.Lookup(FlagOnlyName); f != nil && strings.TrimSpace(f.Value.String()) != "" {
+		only, err := parseOnly(f.Value.String())
+		if err != nil {
+			return rules{}, err
+		}
+		r.only = only
+	}
+
+	if f := flags.Lookup(FlagIgnoreFileName); f != nil && f.Value.String() != "" {
+		ignores, err := loadIgnoreFile(f.Value.String())
+		if err != nil {
+			return rules{}, err
+		}
+		r.ignores = ignores
+	}
+
+	if f := flags.Lookup(FlagDefaultTagName); f != nil && strings.TrimSpace(f.Value.String()) != "" {
+		if err := r.addDefaultTags(f.Value.String()); err != nil {
+			return rules{}, fmt.Errorf(`malformed default tag: "%s", %w`, f.Value.String(), err)
+		}
+	}
+
+	f := flags.Lookup(FlagFiletagsName)
+	if f == nil {
+		return r, nil
+	}
+	args := splitArgs(f.Value.String())
+	for i := 0; i < len(args); i++ {
+		filetag := strings.TrimSpace(stripComment(args[i]))
+		if filetag == "" {
+			continue
+		}
+		if exclude, ok := strings.CutPrefix(filetag, "!"); ok {
+			exclude, err := expandEnv(exclude)
+			if err != nil {
+				return rules{}, &RuleError{Rule: filetag, Err: err, context: "malformed argument"}
+			}
+			if err := r.addExclude(unescape(strings.TrimSpace(exclude))); err != nil {
+				return rules{}, &RuleError{Rule: filetag, Err: err, context: "malformed argument"}
+			}
+			continue
+		}
+
+		prefix := patternPrefix(filetag)
+		parts := splitUnescaped(strings.TrimPrefix(filetag, prefix), ':', "{}")
+		if strings.HasSuffix(prefix, tagPrefix) && strings.Contains(filetag, "=>") {
+			parts = strings.SplitN(strings.TrimPrefix(filetag, prefix), "=>", 2)
+		}
+		if len(parts) != 2 {
+			return rules{}, &RuleError{Rule: filetag, Err: ErrMalformedRule, context: "malformed argument"}
+		}
+
+		for j := range parts {
+			expanded, err := expandEnv(parts[j])
+			if err != nil {
+				return rules{}, &RuleError{Rule: filetag, Err: err, context: "malformed argument"}
+			}
+			parts[j] = expanded
+		}
+
+		pattern := prefix + unescape(strings.TrimSpace(parts[0]))
+		if err := r.addFiletag(pattern, unescape(parts[1])); err != nil {
+			return rules{}, &RuleError{Rule: filetag, Err: err, context: "malformed argument"}
+		}
+	}
+	return r, nil
+}
+
+var (
+	// ErrMalformedRule is the error of the rules which are not of the form "pattern:tag", which callers can match
+	// using errors.Is. ErrEmptyPattern and ErrEmptyTag wrap it.
+	ErrMalformedRule = errors.New(`must be of the form "pattern:tag"`)
+	// ErrEmptyPattern is the error of the rules whose pattern is empty, such as ":tag".
+	ErrEmptyPattern = fmt.Errorf("%w: empty pattern", ErrMalformedRule)
+	// ErrEmptyTag is the error of the rules having an empty tag, such as "pattern:" or "pattern:tag1+".
+	ErrEmptyTag = fmt.Errorf("%w: empty tag", ErrMalformedRule)
+)
+
+// RuleError is the error of a malformed rule of the filetags flag, of a Config or of a config file, which callers
+// can access using errors.As.
+type RuleError struct {
+	// Rule is the offending rule, as written in the filetags flag, or of the form "pattern: tags" for the rules of
+	// a Config or of a config file.
+	Rule string
+	// Err is the reason the rule is malformed, such as ErrEmptyTag.
+	Err error
+	// context describes where the rule comes from, such as "malformed argument".
+	context string
+}
+
+func (e *RuleError) Error() string {
+	return fmt.Sprintf(`%s: "%s", %v`, e.context, e.Rule, e.Err)
+}
+
+// Unwrap returns the reason the rule is malformed, so that it can be matched using errors.Is.
+func (e *RuleError) Unwrap() error {
+	return e.Err
+}
+
+// expandEnv replaces the "${VAR}" and "$VAR" references to environment variables of the value with their values.
+// Undefined variables are an error, as expanding them to an empty value would silently produce another rule. The
+// numbered references such as "$1" are kept, as they refer to the groups captured by the patterns.
+func expandEnv(value string) (string, error) {
+	var undefined []string
+	expanded := os.Expand(value, func(name string) string {
+		if isGroupNumber(name) {
+			return "${" + name + "}"
+		}
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			undefined = append(undefined, name)
+		}
+		return v
+	})
+	if len(undefined) > 0 {
+		return "", fmt.Errorf(`undefined environment variable "%s"`, undefined[0])
+	}
+	return expanded, nil
+}
+
+// splitArgs splits the filetags flag on the unescaped commas found outside of parentheses and braces, which are
+// part of the tag groups such as "oneof(tag1,tag2)" and of the pattern alternatives.
+func splitArgs(value string) []string {
+	return splitUnescaped(value, ',', "(){}")
+}
+
+// splitUnescaped splits the value on the separator, unless it is escaped with a backslash or found within the
+// brackets, given as pairs of opening and closing characters such as "()". Escaped characters are kept escaped.
+func splitUnescaped(value string, sep byte, brackets string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		bracket := strings.IndexByte(brackets, c)
+		switch {
+		case c == '\\' && i+1 < len(value) && strings.IndexByte(escapable, value[i+1]) >= 0:
+			i++
+		case bracket >= 0 && bracket%2 == 0:
+			depth++
+		case bracket >= 0 && depth > 0:
+			depth--
+		case c == sep && depth == 0:
+			parts = append(parts, value[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, value[start:])
+}
+
+// stripComment returns the argument of the filetags flag without its trailing comment, starting with an unescaped
+// "#", such as "*_test.go:unit # run by the CI". As arguments are separated by commas, comments cannot contain
+// commas.
+func stripComment(arg string) string {
+	return splitUnescaped(arg, '#', "")[0]
+}
+
+// escapable are the characters of the filetags flag which must be escaped with a backslash to be used literally in
+// a pattern or a tag, such as "foo\:bar.go:tag1".
+const escapable = ",:#"
+
+var unescaper = strings.NewReplacer(`\,`, ",", `\:`, ":", `\#`, "#")
+
+// unescape returns the value with its escaped characters unescaped.
+func unescape(value string) string {
+	return unescaper.Replace(value)
+}
+
+// describe returns the rules as parsed, one pattern per line along with its tags in order of appearance, followed by
+// the excludes. Patterns and tags are quoted to reveal their surrounding spaces, if any.
+func (r rules) describe() string {
+	var b strings.Builder
+	patterns := r.patterns()
+	b.WriteString("filetags:\n")
+	if len(patterns) == 0 {
+		b.WriteString("  (none)\n")
+	}
+	for _, pattern := range patterns {
+		fmt.Fprintf(&b, "  %s: %s\n", strconv.Quote(pattern), quoteTags(r.filetags[pattern]))
+	}
+	b.WriteString("exclude:\n")
+	if len(r.excludes) == 0 {
+		b.WriteString("  (none)\n")
+	}
+	for _, exclude := range r.excludes {
+		fmt.Fprintf(&b, "  %s\n", strconv.Quote(exclude))
+	}
+	if len(r.defaults) > 0 {
+		fmt.Fprintf(&b, "default tags: %s\n", quoteTags(r.defaults))
+	}
+	if len(r.ignores) > 0 {
+		fmt.Fprintf(&b, "ignored: %s\n", quoteTags(r.ignores))
+	}
+	if len(r.constrained) > 0 {
+		fmt.Fprintf(&b, "constraint required: %s\n", quoteTags(r.constrained))
+	}
+	if len(r.groups) > 0 {
+		fmt.Fprintf(&b, "platform groups: %s\n", quoteTags(r.groups))
+	}
+	if r.only != nil {
+		fmt.Fprintf(&b, "only: %s\n", quoteTags(r.only))
+	}
+	return b.String()
+}
+
+// quoteTags returns the comma-separated list of the tags, quoted using Go syntax.
+func quoteTags(tags []string) string {
+	quoted := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		quoted = append(quoted, strconv.Quote(tag))
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// rule binds a file pattern to one of its expected build tags.
+type rule struct {
+	pattern string
+	tag     string
+}
+
+// rulesOf returns the rules of the pattern, in order of appearance.
+func (r rules) rulesOf(pattern string) []rule {
+	var list []rule
+	for _, rl := range r.list {
+		if rl.pattern == pattern {
+			list = append(list, rl)
+		}
+	}
+	return list
+}
+
+// patterns returns the patterns of the filetags, in order of appearance.
+func (r rules) patterns() []string {
+	patterns := make([]string, 0, len(r.filetags))
+	seen := make(map[string]bool, len(r.filetags))
+	for _, rl := range r.list {
+		if !seen[rl.pattern] {
+			seen[rl.pattern] = true
+			patterns = append(patterns, rl.pattern)
+		}
+	}
+	return patterns
+}
+
+// addFiletag binds the pattern to the tags, of the form "tag1+tag2".
+func (r *rules) addFiletag(pattern, tags string) error {
+	if pattern == patternPrefix(pattern) {
+		return ErrEmptyPattern
+	}
+	if _, err := newMatcher(pattern, options{}); err != nil {
+		return err
+	}
+	listed := r.index(pattern, r.filetags[pattern])
+	list, warnings, err := addTags(r.filetags[pattern], r.warnings[pattern], listed, tags, r.implicitSeverity())
+	if err != nil {
+		return err
+	}
+	for _, tag := range list[len(r.filetags[pattern]):] {
+		if hasGroupRefs(tag) {
+			if err := r.addCapture(pattern, tag); err != nil {
+				return err
+			}
+		}
+	}
+	for _, tag := range list[len(r.filetags[pattern]):] {
+		r.list = append(r.list, rule{pattern: pattern, tag: tag})
+		listed[tag] = true
+	}
+	r.filetags[pattern] = list
+	r.setWarnings(pattern, warnings)
+	return nil
+}
+
+// addDefaultTags adds the tags, of the form "tag1+tag2", to the ones expected on the Go files matching no pattern.
+func (r *rules) addDefaultTags(tags string) error {
+	listed := r.index(defaultPattern, r.defaults)
+	list, warnings, err := addTags(r.defaults, r.warnings[defaultPattern], listed, tags, r.implicitSeverity())
+	if err != nil {
+		return err
+	}
+	for _, tag := range list[len(r.defaults):] {
+		listed[tag] = true
+	}
+	r.defaults = list
+	r.setWarnings(defaultPattern, warnings)
+	return nil
+}
+
+// index returns the index of the tags of the pattern, indexing its list of tags if needed.
+func (r *rules) index(pattern string, list []string) map[string]bool {
+	if listed, ok := r.indexed[pattern]; ok {
+		return listed
+	}
+	if r.indexed == nil {
+		r.indexed = make(map[string]map[string]bool)
+	}
+	listed := make(map[string]bool, len(list))
+	for _, tag := range list {
+		listed[tag] = true
+	}
+	r.indexed[pattern] = listed
+	return listed
+}
+
+// setWarnings sets the tags of the pattern having the warning severity.
+func (r *rules) setWarnings(pattern string, warnings []string) {
+	if len(warnings) == 0 {
+		delete(r.warnings, pattern)
+		return
+	}
+	if r.warnings == nil {
+		r.warnings = make(map[string][]string)
+	}
+	r.warnings[pattern] = warnings
+}
+
+// severity returns the severity of the tag of the pattern.
+func (r rules) severity(pattern, tag string) Severity {
+	if contains(r.warnings[pattern], tag) {
+		return SeverityWarning
+	}
+	if pattern == generatedPattern {
+		// The generated tag is given no severity.
+		return r.implicitSeverity()
+	}
+	return SeverityError
+}
+
+// implicitSeverity returns the severity of the tags given no severity, and of the violations of no tag.
+func (r rules) implicitSeverity() Severity {
+	if r.defaultSeverity == "" {
+		return SeverityError
+	}
+	return r.defaultSeverity
+}
+
+// validateDefaultSeverity returns an error if the severity of the default-severity flag is unknown.
+func validateDefaultSeverity(severity Severity) error {
+	switch severity {
+	case "", SeverityError, SeverityWarning:
+		return nil
+	}
+	return fmt.Errorf(`unknown default severity "%s", must be "%s" or "%s"`, severity, SeverityError, SeverityWarning)
+}
+
+// addTags parses the tags, of the form "tag1+tag2", and returns the list with the tags it does not contain yet, along
+// with the tags having the warning severity. Each tag can be followed by its severity, such as "tag1@warning", the
+// default one being given. A tag which is given several severities has the error severity. The listed tags are the
+// index of the list, which is left untouched.
+func addTags(
+	list, warnings []string, listed map[string]bool, tags string, defaultSeverity Severity,
+) ([]string, []string, error) {
+	added := make(map[string]bool)
+	has := func(tag string) bool { return listed[tag] || added[tag] }
+	errored := make(map[string]bool)
+	add := func(tag string, severity Severity) {
+		switch {
+		case severity == SeverityError:
+			errored[tag] = true
+		case !has(tag):
+			warnings = append(warnings, tag)
+		}
+		if !has(tag) {
+			added[tag] = true
+			list = append(list, tag)
+		}
+	}
+	if isExpression(tags) {
+		tags, severity, err := cutSeverity(tags, defaultSeverity)
+		if err != nil {
+			return nil, nil, err
+		}
+		expr, err := constraint.Parse("//go:build " + tags)
+		if err != nil {
+			return nil, nil, fmt.Errorf(`invalid build constraint expression "%s": %w`, strings.TrimSpace(tags), err)
+		}
+		add(internal.Canonical(expr), severity)
+		return list, removeAll(warnings, errored), nil
+	}
+	for _, tag := range strings.Split(tags, "+") {
+		tag, severity, err := cutSeverity(tag, defaultSeverity)
+		if err != nil {
+			return nil, nil, err
+		}
+		tag = normalizeGroupRefs(tag)
+		if forbidden, ok := forbiddenTag(tag); ok && !isTag(forbidden) {
+			return nil, nil, errors.New(`forbidden tags must be of the form "!tag"`)
+		}
+		if isTagGroup(tag) {
+			group, err := parseTagGroup(tag)
+			if err != nil {
+				return nil, nil, err
+			}
+			tag = group
+		}
+		if tag == "" {
+			return nil, nil, ErrEmptyTag
+		}
+		if strings.Contains(tag, "#") {
+			// Comments must be separated from the tags by a space in YAML, otherwise they are part of the tag.
+			return nil, nil, fmt.Errorf(`invalid build tag "%s": build tags cannot contain "#"`, tag)
+		}
+		if !isTagGroup(tag) {
+			name, _ := strings.CutPrefix(tag, "!")
+			validate := validateTag
+			if hasGroupRefs(name) {
+				validate = validateGroupRefs
+			}
+			if err := validate(name); err != nil {
+				return nil, nil, err
+			}
+		}
+		add(tag, severity)
+	}
+	// The tags listed beforehand are consistent, so that only the added ones can conflict.
+	for _, tag := range list[len(list)-len(added):] {
+		if name, ok := forbiddenTag(tag); ok && has(name) {
+			tag = name
+		} else if !has("!" + tag) {
+			continue
+		}
+		return nil, nil, fmt.Errorf(`build tag "%s" is both expected and forbidden`, tag)
+	}
+	return list, removeAll(warnings, errored), nil
+}
+
+// cutSeverity returns the tag without its "@severity" suffix, trimmed, along with its severity, which is the default
+// one when there is no suffix.
+func cutSeverity(tag string, defaultSeverity Severity) (string, Severity, error) {
+	tag, suffix, ok := strings.Cut(tag, "@")
+	if !ok {
+		return strings.TrimSpace(tag), defaultSeverity, nil
+	}
+	switch severity := Severity(strings.TrimSpace(suffix)); severity {
+	case SeverityError, SeverityWarning:
+		return strings.TrimSpace(tag), severity, nil
+	default:
+		return "", "", fmt.Errorf(`unknown severity "%s", must be "%s" or "%s"`, severity, SeverityError, SeverityWarning)
+	}
+}
+
+// override returns the rules overridden by the rules of a directory config: the tags of a pattern of the directory
+// config replace the tags of the same pattern, at the position of its first rule, while the other patterns are kept.
+// The new patterns of the directory config come last. The excludes of both apply.
+func (r rules) override(dir rules) rules {
+	merged := rules{
+		filetags:        make(map[string][]string, len(r.filetags)+len(dir.filetags)),
+		defaultSeverity: r.defaultSeverity,
+	}
+	for pattern, tags := range r.filetags {
+		merged.filetags[pattern] = tags
+	}
+	for pattern, tags := range dir.filetags {
+		merged.filetags[pattern] = tags
+	}
+	overridden := make(map[string]bool)
+	for _, rl := range r.list {
+		if _, ok := dir.filetags[rl.pattern]; !ok {
+			merged.list = append(merged.list, rl)
+			continue
+		}
+		if !overridden[rl.pattern] {
+			overridden[rl.pattern] = true
+			merged.list = append(merged.list, dir.rulesOf(rl.pattern)...)
+		}
+	}
+	for _, rl := range dir.list {
+		if _, ok := r.filetags[rl.pattern]; !ok {
+			merged.list = append(merged.list, rl)
+		}
+	}
+	merged.excludes = append(merged.excludes, r.excludes...)
+	merged.defaults = r.defaults
+	merged.ignores = r.ignores
+	merged.constrained = r.constrained
+	merged.groups = r.groups
+	merged.groupCaptures = r.groupCaptures
+	merged.only = r.only
+	for pattern, capture := range r.captures {
+		if _, ok := dir.filetags[pattern]; !ok {
+			merged.setCapture(pattern, capture)
+		}
+	}
+	for pattern, capture := range dir.captures {
+		merged.setCapture(pattern, capture)
+	}
+	for pattern, warnings := range r.warnings {
+		if _, ok := dir.filetags[pattern]; !ok {
+			merged.setWarnings(pattern, warnings)
+		}
+	}
+	for pattern, warnings := range dir.warnings {
+		merged.setWarnings(pattern, warnings)
+	}
+	for _, exclude := range dir.excludes {
+		if !contains(merged.excludes, exclude) {
+			merged.excludes = append(merged.excludes, exclude)
+		}
+	}
+	return merged
+}
+
+// The tag groups bind a pattern to a group of tags using the "group(tag1,tag2)" form, of which files must have
+// exactly one, at least one or all of them.
+const (
+	oneOf = "oneof"
+	anyOf = "anyof"
+	allOf = "allof"
+)
+
+var errMalformedTagGroup = errors.New(`tag groups must be of the form "oneof(tag1,tag2)", "anyof(tag1,tag2)" or "allof(tag1,tag2)"`)
+
+// isTagGroup reports whether the tag is a tag group, such as "oneof(tag1,tag2)".
+func isTagGroup(tag string) bool {
+	for _, group := range []string{oneOf, anyOf, allOf} {
+		if strings.HasPrefix(tag, group+"(") {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTagGroup validates the tag group and returns it without spaces nor duplicated tags.
+func parseTagGroup(tag string) (string, error) {
+	group, members, _ := strings.Cut(tag, "(")
+	members, ok := strings.CutSuffix(members, ")")
+	if !ok {
+		return "", errMalformedTagGroup
+	}
+	var tags []string
+	for _, member := range strings.Split(members, ",") {
+		member = strings.TrimSpace(member)
+		if !isTag(member) {
+			return "", errMalformedTagGroup
+		}
+		if err := validateTag(member); err != nil {
+			return "", err
+		}
+		if !contains(tags, member) {
+			tags = append(tags, member)
+		}
+	}
+	return group + "(" + strings.Join(tags, ",") + ")", nil
+}
+
+// tagGroup returns the group and the tags of a tag group and true, or false if the tag is not a tag group. The tag
+// must have been validated by parseTagGroup.
+func tagGroup(tag string) (string, []string, bool) {
+	if !isTagGroup(tag) {
+		return "", nil, false
+	}
+	group, members, _ := strings.Cut(strings.TrimSuffix(tag, ")"), "(")
+	return group, strings.Split(members, ","), true
+}
+
+// isTag reports whether the value is a plain build tag, neither forbidden nor a tag group.
+func isTag(value string) bool {
+	return value != "" && !strings.ContainsAny(value, "!(),")
+}
+
+// validateTag returns an error if the tag would not be a valid build tag for the go/build/constraint package, made of
+// letters, digits, "_" and "." only, as files could never have it. Tags can start with a digit, such as the "386"
+// GOARCH.
+func validateTag(tag string) error {
+	expr, err := constraint.Parse("//go:build " + tag)
+	if tagExpr, ok := expr.(*constraint.TagExpr); err != nil || !ok || tagExpr.Tag != tag {
+		return fmt.Errorf(`invalid build tag "%s": build tags can only contain letters, digits, "_" and "."`, tag)
+	}
+	return nil
+}
+
+// groupRef matches the references of the tags to the groups captured by the regular expressions of their patterns,
+// of the form "${1}", along with the malformed ones such as "${name}".
+var groupRef = regexp.MustCompile(`\$\{([^}]*)\}`)
+
+// shortGroupRef matches the short references to the groups, such as "$1", which are followed by a single digit like
+// the positional parameters of the shells, so that "$1_unit" is "${1}_unit".
+var shortGroupRef = regexp.MustCompile(`\$([0-9])`)
+
+// capture holds the regular expression of a pattern whose tags refer to the groups it captures, along with its
+// case-insensitive form.
+type capture struct {
+	re     *regexp.Regexp
+	folded *regexp.Regexp
+	// path is whether the regular expression matches the path of the files rather than their name.
+	path bool
+}
+
+// hasGroupRefs reports whether the tag refers to the groups captured by the regular expression of its pattern, such
+// as "${1}" in "re:service_(.+)_integration_test\.go:${1}", the tag being computed for each file. The short
+// references must have been normalized beforehand.
+func hasGroupRefs(tag string) bool {
+	return strings.Contains(tag, "${")
+}
+
+// normalizeGroupRefs returns the tag with its short references to groups, such as "$1", replaced by their "${1}" form.
+func normalizeGroupRefs(tag string) string {
+	return shortGroupRef.ReplaceAllString(tag, "$${$1}")
+}
+
+// isGroupNumber reports whether the name of a reference is the number of a group, such as "1" in "$1".
+func isGroupNumber(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// validateGroupRefs returns an error if the tag does not refer to the groups by number, or if it would not be a valid
+// build tag once its references are replaced by valid values.
+func validateGroupRefs(tag string) error {
+	for _, m := range groupRef.FindAllStringSubmatch(tag, -1) {
+		if !isGroupNumber(m[1]) {
+			return fmt.Errorf(`invalid build tag "%s": groups must be referenced by number, such as "$1" or "${1}"`, tag)
+		}
+	}
+	if err := validateTag(groupRef.ReplaceAllString(tag, "x")); err != nil {
+		return fmt.Errorf(`invalid build tag "%s": apart from the references to groups, build tags can only contain `+
+			`letters, digits, "_" and "."`, tag)
+	}
+	return nil
+}
+
+// addCapture compiles the regular expression of the pattern of the tag referring to its groups. The pattern must be
+// of the form "re:expr", optionally qualified by "test:" or "nontest:", and have the groups the tag refers to.
+func (r *rules) addCapture(pattern, tag string) error {
+	prefix := patternPrefix(pattern)
+	qualified := strings.TrimPrefix(strings.TrimPrefix(prefix, testPrefix), nonTestPrefix)
+	if qualified != regexPrefix || len(patternParts(pattern)) > 1 {
+		return fmt.Errorf(`build tag "%s" refers to groups, which only the patterns of the form "re:expr" capture`, tag)
+	}
+	expr := pattern[len(prefix):]
+	re, err := regexp.Compile("^(?:" + expr + ")$")
+	if err != nil {
+		return fmt.Errorf(`invalid regular expression "%s": %w`, expr, err)
+	}
+	for _, m := range groupRef.FindAllStringSubmatch(tag, -1) {
+		n, _ := strconv.Atoi(m[1])
+		if n < 1 || n > re.NumSubexp() {
+			return fmt.Errorf(`build tag "%s" refers to the group %s, while the pattern has %d groups`,
+				tag, m[0], re.NumSubexp())
+		}
+	}
+	r.setCapture(pattern, newCapture(re, expr))
+	return nil
+}
+
+// newCapture returns the capture of the regular expression compiled from the expression of a pattern.
+func newCapture(re *regexp.Regexp, expr string) capture {
+	return capture{
+		re:     re,
+		folded: regexp.MustCompile("(?i)" + re.String()),
+		path:   isPathPattern(expr),
+	}
+}
+
+// setCapture sets the regular expression capturing the groups the tags of the pattern refer to.
+func (r *rules) setCapture(pattern string, c capture) {
+	if r.captures == nil {
+		r.captures = make(map[string]capture)
+	}
+	r.captures[pattern] = c
+}
+
+// expand returns the tag for the file, its references being replaced by the groups captured by the regular
+// expression of the pattern. The groups which did not match are replaced by empty values, like regexp.Regexp.Expand
+// does.
+func (c capture) expand(tag string, f file, caseInsensitive bool) string {
+	re, subject := c.subject(f, caseInsensitive)
+	m := re.FindStringSubmatchIndex(subject)
+	if m == nil {
+		return ""
+	}
+	return string(re.ExpandString(nil, tag, subject, m))
+}
+
+// values returns the values of the groups captured by the regular expression of the pattern for the file, nil when
+// it does not match.
+func (c capture) values(f file, caseInsensitive bool) []string {
+	re, subject := c.subject(f, caseInsensitive)
+	m := re.FindStringSubmatch(subject)
+	if m == nil {
+		return nil
+	}
+	return m[1:]
+}
+
+// subject returns the regular expression matching the file, along with the name or the path of the file it matches.
+func (c capture) subject(f file, caseInsensitive bool) (*regexp.Regexp, string) {
+	re, subject := c.re, f.name
+	if caseInsensitive {
+		re = c.folded
+	}
+	if c.path {
+		subject = f.path
+	}
+	return re, subject
+}
+
+// isExpression reports whether the tags are a build constraint expression, such as "linux && amd64", which files
+// must have as is rather than a list of tags.
+func isExpression(tags string) bool {
+	return strings.Contains(tags, "&&") || strings.Contains(tags, "||")
+}
+
+// addConstrained requires the files matching the pattern to have a build constraint.
+func (r *rules) addConstrained(pattern string) error {
+	if pattern == patternPrefix(pattern) {
+		return errors.New("patterns cannot be empty")
+	}
+	if _, err := newMatcher(pattern, options{}); err != nil {
+		return err
+	}
+	if !contains(r.constrained, pattern) {
+		r.constrained = append(r.constrained, pattern)
+	}
+	return nil
+}
+
+// addGroup makes the files matching the pattern a platform group.
+func (r *rules) addGroup(pattern string) error {
+	if pattern == patternPrefix(pattern) {
+		return errors.New("patterns cannot be empty")
+	}
+	if _, err := newMatcher(pattern, options{}); err != nil {
+		return err
+	}
+	if !contains(r.groups, pattern) {
+		r.groups = append(r.groups, pattern)
+		r.addGroupCapture(pattern)
+	}
+	return nil
+}
+
+// addGroupCapture compiles the regular expression of the pattern of a platform group when it is of the form
+// "re:expr", optionally qualified by "test:" or "nontest:", and captures groups, such as "re:(.+)_[^_]+\.go" splitting
+// the files by the name before their last "_". The pattern must have been validated beforehand.
+func (r *rules) addGroupCapture(pattern string) {
+	prefix := patternPrefix(pattern)
+	qualified := strings.TrimPrefix(strings.TrimPrefix(prefix, testPrefix), nonTestPrefix)
+	if qualified != regexPrefix || len(patternParts(pattern)) > 1 {
+		return
+	}
+	expr := pattern[len(prefix):]
+	re, err := regexp.Compile("^(?:" + expr + ")$")
+	if err != nil || re.NumSubexp() == 0 {
+		return
+	}
+	if r.groupCaptures == nil {
+		r.groupCaptures = make(map[string]capture)
+	}
+	r.groupCaptures[pattern] = newCapture(re, expr)
+}
+
+// addExclude excludes the files matching the pattern from every rule.
+func (r *rules) addExclude(pattern string) error {
+	if pattern == patternPrefix(pattern) {
+		return errors.New(`excludes must be of the form "!pattern"`)
+	}
+	if _, err := newMatcher(pattern, options{}); err != nil {
+		return err
+	}
+	if !contains(r.excludes, pattern) {
+		r.excludes = append(r.excludes, pattern)
+	}
+	return nil
+}
+
+// configFile is the content of the file provided using the filetags-config flag.
+type configFile struct {
+	// Filetags binds file patterns to their build tags, using the same forms as the filetags flag.
+	Filetags filetagsMap `yaml:"filetags"`
+	// Exclude are the patterns of the files to skip, whatever the filetags they match.
+	Exclude []string `yaml:"exclude"`
+	// Templates are named rule shapes binding patterns to build tags, like Filetags, whose patterns and tags can
+	// reference the "${param}" parameters given when using the template.
+	Templates map[string]filetagsMap `yaml:"templates"`
+	// Use instantiates the templates, in order.
+	Use []templateUse `yaml:"use"`
+	// Profiles are named sets of rules, the ones of the profile selected using the profile flag applying along with
+	// the other rules of the file.
+	Profiles map[string]configProfile `yaml:"profiles"`
+}
+
+// configProfile is a named set of rules of the config file, which can use its templates.
+type configProfile struct {
+	// Filetags binds file patterns to their build tags, like the Filetags of the config file.
+	Filetags filetagsMap `yaml:"filetags"`
+	// Exclude are the patterns of the files to skip, whatever the filetags they match.
+	Exclude []string `yaml:"exclude"`
+	// Use instantiates the templates of the config file, in order.
+	Use []templateUse `yaml:"use"`
+}
+
+// templateUse instantiates a template of the config file with its parameters.
+type templateUse struct {
+	// Template is the name of the template.
+	Template string `yaml:"template"`
+	// With are the values of the parameters of the template.
+	With map[string]string `yaml:"with"`
+}
+
+// rules returns the rules of the config file, binding patterns to tags: its filetags along with the rules of the
+// instantiated templates, followed by the ones of the profile, if any, in order of appearance. A pattern found in
+// several of them must have the tags of each.
+func (cfg configFile) rules(profile string) (filetagsMap, error) {
+	var filetags filetagsMap
+	for _, pattern := range cfg.Filetags.patterns {
+		for _, tag := range cfg.Filetags.tags[pattern] {
+			filetags.add(pattern, tag)
+		}
+	}
+	if err := cfg.use(&filetags, cfg.Use); err != nil {
+		return filetagsMap{}, err
+	}
+	if profile == "" {
+		return filetags, nil
+	}
+	p := cfg.Profiles[profile]
+	for _, pattern := range p.Filetags.patterns {
+		for _, tag := range p.Filetags.tags[pattern] {
+			filetags.add(pattern, tag)
+		}
+	}
+	if err := cfg.use(&filetags, p.Use); err != nil {
+		return filetagsMap{}, err
+	}
+	return filetags, nil
+}
+
+// use adds the rules of the templates instantiated by the uses to the filetags.
+func (cfg configFile) use(filetags *filetagsMap, uses []templateUse) error {
+	for _, use := range uses {
+		template, ok := cfg.Templates[use.Template]
+		if !ok {
+			return fmt.Errorf(`unknown template "%s"`, use.Template)
+		}
+		var err error
+		expand := func(value string) string {
+			return os.Expand(value, func(param string) string {
+				if isGroupNumber(param) {
+					// The references to the groups captured by the patterns are not parameters.
+					return "${" + param + "}"
+				}
+				v, ok := use.With[param]
+				if !ok && err == nil {
+					err = fmt.Errorf(`undefined parameter "%s" of template "%s"`, param, use.Template)
+				}
+				return v
+			})
+		}
+		for _, pattern := range template.patterns {
+			for _, tag := range template.tags[pattern] {
+				filetags.add(expand(pattern), expand(tag))
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// filetagsMap binds file patterns to their build tags, like a map keeping the patterns in order of appearance.
+type filetagsMap struct {
+	patterns []string
+	tags     map[string]tagList
+}
+
+// add binds the pattern to the tags too.
+func (m *filetagsMap) add(pattern string, tags ...string) {
+	if m.tags == nil {
+		m.tags = make(map[string]tagList)
+	}
+	if _, ok := m.tags[pattern]; !ok {
+		m.patterns = append(m.patterns, pattern)
+	}
+	m.tags[pattern] = append(m.tags[pattern], tags...)
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (m *filetagsMap) UnmarshalYAML(value *yaml.Node) error {
+	var tags map[string]tagList
+	if err := value.Decode(&tags); err != nil {
+		return err
+	}
+	if value.Kind != yaml.MappingNode {
+		return nil
+	}
+	// The keys of a mapping node are its even children, each followed by its value.
+	for i := 0; i < len(value.Content); i += 2 {
+		pattern := value.Content[i].Value
+		if t, ok := tags[pattern]; ok {
+			m.add(pattern, t...)
+			delete(tags, pattern)
+		}
+	}
+	// The patterns of the merged mappings, if any, are not keys of the node, so they are added sorted.
+	remaining := make([]string, 0, len(tags))
+	for pattern := range tags {
+		remaining = append(remaining, pattern)
+	}
+	sort.Strings(remaining)
+	for _, pattern := range remaining {
+		m.add(pattern, tags[pattern]...)
+	}
+	return nil
+}
+
+// tagList is a list of build tags, which can be written either as a single tag or as a list of tags.
+type tagList []string
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (l *tagList) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*l = tagList{value.Value}
+		return nil
+	}
+	var tags []string
+	if err := value.Decode(&tags); err != nil {
+		return err
+	}
+	*l = tags
+	return nil
+}
+
+// loadConfigFile reads the YAML or JSON config file and adds its rules, along with the ones of the profile when not
+// empty, which the file must define.
+func loadConfigFile(path, profile string, r *rules) error {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return fmt.Errorf("cannot read config file: %w", err)
+	}
+
+	var config configFile
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&config); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf(`cannot parse config file "%s": %w`, path, err)
+	}
+
+	if _, ok := config.Profiles[profile]; profile != "" && !ok {
+		return fmt.Errorf(`unknown profile "%s" in config file "%s", %s`, profile, path, profileNames(config.Profiles))
+	}
+	filetags, err := config.rules(profile)
+	if err != nil {
+		return fmt.Errorf(`malformed templates in config file "%s": %w`, path, err)
+	}
+	for _, pattern := range filetags.patterns {
+		for _, tags := range filetags.tags[pattern] {
+			if err := r.addFiletag(strings.TrimSpace(pattern), tags); err != nil {
+				return &RuleError{
+					Rule:    pattern + ": " + tags,
+					Err:     err,
+					context: fmt.Sprintf(`malformed rule in config file "%s"`, path),
+				}
+			}
+		}
+	}
+	excludes := config.Exclude
+	if profile != "" {
+		excludes = append(excludes, config.Profiles[profile].Exclude...)
+	}
+	for _, exclude := range excludes {
+		if err := r.addExclude(strings.TrimSpace(exclude)); err != nil {
+			return fmt.Errorf(`malformed exclude in config file "%s": "%s", %w`, path, exclude, err)
+		}
+	}
+	return nil
+}
+
+// profileNames describes the profiles a config file defines, to help fixing the profile flag.
+func profileNames(profiles map[string]configProfile) string {
+	if len(profiles) == 0 {
+		return "which defines no profiles"
+	}
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return "must be one of " + quoteTags(names)
+}
+
+// dirConfigName is the name of the directory configs, which apply to the files of their directory and subdirectories.
+const dirConfigName = ".filebuildtag.yml"
+
+// findDirConfigs returns the paths of the directory configs applying to the files of the directory, in order of
+// precedence: the module config, adjacent to the go.mod file of the module, followed by the nearest directory config,
+// looking from the directory up to the root of the module, which is the first directory containing a go.mod file.
+// The module config is not repeated when it is the nearest directory config.
+func findDirConfigs(dir string) []string {
+	var nearest string
+	for {
+		configPath := filepath.Join(dir, dirConfigName)
+		_, err := os.Stat(configPath)
+		hasConfig := err == nil
+		if hasConfig && nearest == "" {
+			nearest = configPath
+		}
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			if hasConfig && configPath != nearest {
+				return []string{configPath, nearest}
+			}
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	if nearest == "" {
+		return nil
+	}
+	return []string{nearest}
+}
+
+// options are the flags tuning the analysis.
+type options struct {
+	reverse         bool
+	caseInsensitive bool
+	unusedPatterns  bool
+	matchPlusBuild  bool
+	redundant       bool
+	// constraintOrder is whether the order of the build constraint lines is checked.
+	constraintOrder bool
+	// sortedOperands is whether the operands of the build constraints must be sorted.
+	sortedOperands bool
+	// constraintStyle is the style of the build constraint lines files must use, any style when empty.
+	constraintStyle string
+	// pathFormat is the format of the file names in the messages, their base name when empty.
+	pathFormat string
+	// severityPrefix is whether the messages of the warnings are prefixed with their severity.
+	severityPrefix bool
+	// unixTag is whether the "unix" build tag satisfies the expected GOOS tags it covers.
+	unixTag bool
+	// aliases are the "tag=alias" entries of the tag-alias flag, the alias satisfying the expected tag.
+	aliases []string
+	// ignoredTags are the build tags considered absent from the files.
+	ignoredTags []string
+	// aggregateMissing is whether the missing tags of a file are reported as a single violation.
+	aggregateMissing bool
+	// firstOnly is whether at most one missing tag is reported per file.
+	firstOnly bool
+	// maxReports is the maximum number of diagnostics reported per package, all of them being reported when 0.
+	maxReports int
+	// duplicateTags is whether the tags referenced more than once by the build constraints of a file are reported.
+	duplicateTags bool
+	// requireRationale is whether the build constraint lines of the files matching a pattern must have an adjoining
+	// comment explaining them.
+	requireRationale bool
+	// platforms are the platforms each platform group must build for, of the form "goos" or "goos/goarch".
+	platforms []string
+	// requireConfig is whether the analysis fails when no filetags are configured.
+	requireConfig bool
+	// includeGenerated is whether generated files are checked too.
+	includeGenerated bool
+	// generatedTag is the build tag the generated files must have, if any.
+	generatedTag string
+	// includeIgnored is whether the files having the ignoreTag are checked too.
+	includeIgnored bool
+	// includeTestdata is whether the Go files of the testdata directories of the packages are checked too.
+	includeTestdata bool
+	// checkedTestdata are the testdata directories already checked by the analyzer, set by the analyzers rather than
+	// by the flags or the config.
+	checkedTestdata *testdataCache
+	// buildContext is whether only the files included in build.Default are checked.
+	buildContext bool
+	// debugTiming is whether the time spent checking each package is printed.
+	debugTiming bool
+	// resolver returns the tags expected on a file in addition to the ones of the rules, if any.
+	resolver func(filename string) []string
+}
+
+// parseOptions parses the flags tuning the analysis.
+func parseOptions(flags flag.FlagSet) options {
+	return options{
+		reverse:          boolFlag(flags, FlagReverseName),
+		caseInsensitive:  boolFlag(flags, FlagCaseInsensitiveName),
+		unusedPatterns:   boolFlag(flags, FlagUnusedPatternsName),
+		matchPlusBuild:   boolFlag(flags, FlagMatchPlusBuildName),
+		redundant:        boolFlag(flags, FlagRedundantName),
+		constraintOrder:  boolFlag(flags, FlagConstraintOrderName),
+		sortedOperands:   boolFlag(flags, FlagSortedOperandsName),
+		constraintStyle:  stringFlag(flags, FlagConstraintStyleName),
+		pathFormat:       stringFlag(flags, FlagPathFormatName),
+		severityPrefix:   boolFlag(flags, FlagSeverityPrefixName),
+		unixTag:          boolFlag(flags, FlagUnixTagName),
+		aliases:          trimAliases(strings.Split(stringFlag(flags, FlagTagAliasName), ",")),
+		ignoredTags:      trimValues(strings.Split(stringFlag(flags, FlagIgnoreTagsName), ",")),
+		aggregateMissing: boolFlag(flags, FlagAggregateMissingName),
+		firstOnly:        boolFlag(flags, FlagFirstOnlyName),
+		maxReports:       intFlag(flags, FlagMaxReportsName),
+		duplicateTags:    boolFlag(flags, FlagDuplicateTagsName),
+		requireRationale: boolFlag(flags, FlagRequireRationaleName),
+		platforms:        trimValues(strings.Split(stringFlag(flags, FlagPlatformsName), ",")),
+		requireConfig:    boolFlag(flags, FlagRequireConfigName),
+		includeGenerated: boolFlag(flags, FlagIncludeGeneratedName),
+		generatedTag:     stringFlag(flags, FlagGeneratedTagName),
+		includeIgnored:   boolFlag(flags, FlagIncludeIgnoredName),
+		includeTestdata:  boolFlag(flags, FlagIncludeTestdataName),
+		buildContext:     boolFlag(flags, FlagBuildContextName),
+		debugTiming:      boolFlag(flags, FlagDebugTimingName),
+	}
+}
+
+// fold returns the value lowercased when the analysis is case-insensitive, as is otherwise.
+func (o options) fold(value string) string {
+	if o.caseInsensitive {
+		return strings.ToLower(value)
+	}
+	return value
+}
+
+// has reports whether the tag is present in the constraints.
+func (o options) has(constraints internal.Constraints, tag string) bool {
+	if o.caseInsensitive {
+		return constraints.HasFold(tag)
+	}
+	return constraints.Has(tag)
+}
+
+// satisfies reports whether the constraints satisfy the expected tag: when it is present, when one of its aliases is,
+// or when the tag is a GOOS covered by the "unix" build tag of the constraints and the unix-tag flag is set.
+func (o options) satisfies(constraints internal.Constraints, tag string) bool {
+	if o.has(constraints, tag) {
+		return true
+	}
+	for _, entry := range o.aliases {
+		if aliased, alias, _ := strings.Cut(entry, "="); o.fold(aliased) == o.fold(tag) && o.has(constraints, alias) {
+			return true
+		}
+	}
+	return o.unixTag && internal.IsUnixOS(o.fold(tag)) && o.has(constraints, internal.UnixTag)
+}
+
+// ignoring returns the constraints considering the tags of the ignore-tags flag absent, whatever their case when the
+// analysis is case-insensitive.
+func (o options) ignoring(constraints internal.Constraints) internal.Constraints {
+	for _, tag := range append(constraints.Tags(), constraints.NegatedTags()...) {
+		for _, ignored := range o.ignoredTags {
+			if o.fold(tag) == o.fold(ignored) {
+				constraints.Ignored = append(constraints.Ignored, tag)
+			}
+		}
+	}
+	return constraints
+}
+
+// validate returns an error if an option has an unknown value.
+func (o options) validate() error {
+	switch o.constraintStyle {
+	case "", styleAny, styleNewOnly, styleBoth:
+	default:
+		return fmt.Errorf(`unknown constraint style "%s", must be "%s", "%s" or "%s"`,
+			o.constraintStyle, styleAny, styleNewOnly, styleBoth)
+	}
+	if o.maxReports < 0 {
+		return fmt.Errorf("negative max reports %d, must be 0 to report every diagnostic or more", o.maxReports)
+	}
+	switch o.pathFormat {
+	case "", pathFormatBase, pathFormatModule, pathFormatAbsolute:
+	default:
+		return fmt.Errorf(`unknown path format "%s", must be "%s", "%s" or "%s"`,
+			o.pathFormat, pathFormatBase, pathFormatModule, pathFormatAbsolute)
+	}
+	for _, platform := range o.platforms {
+		if err := validatePlatform(platform); err != nil {
+			return err
+		}
+	}
+	for _, entry := range o.aliases {
+		if err := validateAlias(entry); err != nil {
+			return err
+		}
+	}
+	for _, tag := range o.ignoredTags {
+		if err := validateTag(tag); err != nil {
+			return err
+		}
+	}
+	if o.generatedTag != "" {
+		return validateTag(o.generatedTag)
+	}
+	return nil
+}
+
+// validateAlias returns an error if the entry of the tag-alias flag is not of the form "tag=alias", both being
+// plain build tags.
+func validateAlias(entry string) error {
+	tag, alias, ok := strings.Cut(entry, "=")
+	if !ok || !isTag(tag) || !isTag(alias) {
+		return fmt.Errorf(`malformed tag alias "%s", must be of the form "tag=alias"`, entry)
+	}
+	if err := validateTag(tag); err != nil {
+		return err
+	}
+	return validateTag(alias)
+}
+
+// aliasEntries returns the tag aliases of the config as "tag=alias" entries, sorted by tag.
+func aliasEntries(aliases map[string][]string) []string {
+	tags := make([]string, 0, len(aliases))
+	for tag := range aliases {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	var entries []string
+	for _, tag := range tags {
+		for _, alias := range aliases[tag] {
+			entries = append(entries, tag+"="+alias)
+		}
+	}
+	return trimAliases(entries)
+}
+
+// trimAliases returns the "tag=alias" entries with the spaces around their tags trimmed, leaving the empty ones out.
+func trimAliases(entries []string) []string {
+	var trimmed []string
+	for _, entry := range trimValues(entries) {
+		if tag, alias, ok := strings.Cut(entry, "="); ok {
+			entry = strings.TrimSpace(tag) + "=" + strings.TrimSpace(alias)
+		}
+		trimmed = append(trimmed, entry)
+	}
+	return trimmed
+}
+
+// trimValues returns the values with their spaces trimmed, leaving the empty ones out.
+func trimValues(values []string) []string {
+	var trimmed []string
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			trimmed = append(trimmed, value)
+		}
+	}
+	return trimmed
+}
+
+// stringFlag returns the trimmed value of a flag, or "" when the flag is not defined.
+func stringFlag(flags flag.FlagSet, name string) string {
+	f := flags.Lookup(name)
+	if f == nil {
+		return ""
+	}
+	return strings.TrimSpace(f.Value.String())
+}
+
+// boolFlag returns the value of a boolean flag, or false when the flag is not defined.
+func boolFlag(flags flag.FlagSet, name string) bool {
+	f := flags.Lookup(name)
+	if f == nil {
+		return false
+	}
+	v, _ := strconv.ParseBool(f.Value.String())
+	return v
+}
+
+// intFlag returns the value of an integer flag, or 0 when the flag is not defined.
+func intFlag(flags flag.FlagSet, name string) int {
+	f := flags.Lookup(name)
+	if f == nil {
+		return 0
+	}
+	v, _ := strconv.Atoi(f.Value.String())
+	return v
+}
+
+// forbiddenTag returns the tag without its "!" prefix and true if the tag is forbidden.
+func forbiddenTag(tag string) (string, bool) {
+	if !strings.HasPrefix(tag, "!") {
+		return tag, false
+	}
+	return tag[1:], true
+}
+
+func contains(values []string, value string) bool {
+	for i := range values {
+		if values[i] == value {
+			return true
+		}
+	}
+	return false
+}
+
+// removeAll returns the values without the removed ones.
+func removeAll(values []string, removed map[string]bool) []string {
+	if len(removed) == 0 {
+		return values
+	}
+	var kept []string
+	for _, v := range values {
+		if !removed[v] {
+			kept = append(kept, v)
+		}
+	}
+	return kept
+}
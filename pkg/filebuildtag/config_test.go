@@ -0,0 +1,936 @@
+package filebuildtag
+
+import (
+	"errors"
+	"flag"
+	"go/build/constraint"
+	"strings"
+	"testing"
+
+	"github.com/aziule/filebuildtag/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseFlags(t *testing.T) {
+	emptyFiletags := map[string][]string{}
+	t.Setenv("FILEBUILDTAG_TEAM", "payments")
+	testCases := map[string]struct {
+		flags               flag.FlagSet
+		expected            map[string][]string
+		expectedExcludes    []string
+		expectedDefaults    []string
+		expectedIgnores     []string
+		expectedConstrained []string
+		expectedWarnings    map[string][]string
+		expectedErr         error
+	}{
+		"no flags": {
+			flags:       flag.FlagSet{},
+			expected:    emptyFiletags,
+			expectedErr: nil,
+		},
+		"empty tag": {
+			flags:       newFlagSet(t, "  "),
+			expected:    emptyFiletags,
+			expectedErr: nil,
+		},
+		"malformed flag": {
+			flags:       newFlagSet(t, "foo"),
+			expectedErr: errors.New(`malformed argument: "foo", must be of the form "pattern:tag"`),
+		},
+		"empty file pattern": {
+			flags:       newFlagSet(t, ":foo"),
+			expectedErr: errors.New(`malformed argument: ":foo", must be of the form "pattern:tag": empty pattern`),
+		},
+		"empty build tag": {
+			flags:       newFlagSet(t, "foo:"),
+			expectedErr: errors.New(`malformed argument: "foo:", must be of the form "pattern:tag": empty tag`),
+		},
+		"single file pattern": {
+			flags: newFlagSet(t, "*:foo"),
+			expected: map[string][]string{
+				"*": {"foo"},
+			},
+		},
+		"several file patterns": {
+			flags: newFlagSet(t, "foo:bar,bar:baz"),
+			expected: map[string][]string{
+				"foo": {"bar"},
+				"bar": {"baz"},
+			},
+		},
+		"several build tags": {
+			flags: newFlagSet(t, "foo:bar+ baz"),
+			expected: map[string][]string{
+				"foo": {"bar", "baz"},
+			},
+		},
+		"repeated file pattern": {
+			flags: newFlagSet(t, "foo:bar,foo:baz+bar"),
+			expected: map[string][]string{
+				"foo": {"bar", "baz"},
+			},
+		},
+		"duplicate file patterns require the tags of each": {
+			flags: newFlagSet(t, "*foo.go:a,*foo.go:b, *foo.go :a"),
+			expected: map[string][]string{
+				"*foo.go": {"a", "b"},
+			},
+		},
+		"forbidden build tag": {
+			flags: newFlagSet(t, "foo:!bar+baz"),
+			expected: map[string][]string{
+				"foo": {"!bar", "baz"},
+			},
+		},
+		"build tag both expected and forbidden": {
+			flags:       newFlagSet(t, "foo:bar,foo:!bar"),
+			expectedErr: errors.New(`malformed argument: "foo:!bar", build tag "bar" is both expected and forbidden`),
+		},
+		"build tag both expected and forbidden by the same argument": {
+			flags:       newFlagSet(t, "foo:!bar+bar"),
+			expectedErr: errors.New(`malformed argument: "foo:!bar+bar", build tag "bar" is both expected and forbidden`),
+		},
+		"empty forbidden build tag": {
+			flags:       newFlagSet(t, "foo:!"),
+			expectedErr: errors.New(`malformed argument: "foo:!", forbidden tags must be of the form "!tag"`),
+		},
+		"double negative forbidden build tag": {
+			flags:       newFlagSet(t, "foo:!!bar"),
+			expectedErr: errors.New(`malformed argument: "foo:!!bar", forbidden tags must be of the form "!tag"`),
+		},
+		"one of a set of build tags": {
+			flags: newFlagSet(t, "*_env.go:oneof(dev, staging,prod,dev)+app,foo:bar"),
+			expected: map[string][]string{
+				"*_env.go": {"oneof(dev,staging,prod)", "app"},
+				"foo":      {"bar"},
+			},
+		},
+		"empty one of a set of build tags": {
+			flags:       newFlagSet(t, "foo:oneof()"),
+			expectedErr: errors.New(`malformed argument: "foo:oneof()", tag groups must be of the form "oneof(tag1,tag2)", "anyof(tag1,tag2)" or "allof(tag1,tag2)"`),
+		},
+		"unclosed one of a set of build tags": {
+			flags:       newFlagSet(t, "foo:oneof(bar,baz"),
+			expectedErr: errors.New(`malformed argument: "foo:oneof(bar,baz", tag groups must be of the form "oneof(tag1,tag2)", "anyof(tag1,tag2)" or "allof(tag1,tag2)"`),
+		},
+		"forbidden tag in one of a set of build tags": {
+			flags:       newFlagSet(t, "foo:oneof(bar,!baz)"),
+			expectedErr: errors.New(`malformed argument: "foo:oneof(bar,!baz)", tag groups must be of the form "oneof(tag1,tag2)", "anyof(tag1,tag2)" or "allof(tag1,tag2)"`),
+		},
+		"escaped colon in a tag": {
+			flags:       newFlagSet(t, `*_vendor.go:vendor\:legacy+foo`),
+			expectedErr: errors.New(`malformed argument: "*_vendor.go:vendor\:legacy+foo", invalid build tag "vendor:legacy": build tags can only contain letters, digits, "_" and "."`),
+		},
+		"space in a tag": {
+			flags:       newFlagSet(t, "*.go:my tag"),
+			expectedErr: errors.New(`malformed argument: "*.go:my tag", invalid build tag "my tag": build tags can only contain letters, digits, "_" and "."`),
+		},
+		"invalid symbol in a tag": {
+			flags:       newFlagSet(t, "*.go:foo+bar-baz"),
+			expectedErr: errors.New(`malformed argument: "*.go:foo+bar-baz", invalid build tag "bar-baz": build tags can only contain letters, digits, "_" and "."`),
+		},
+		"invalid symbol in a forbidden tag": {
+			flags:       newFlagSet(t, "*.go:!foo$"),
+			expectedErr: errors.New(`malformed argument: "*.go:!foo$", invalid build tag "foo$": build tags can only contain letters, digits, "_" and "."`),
+		},
+		"invalid tag in a tag group": {
+			flags:       newFlagSet(t, "*.go:oneof(dev,my prod)"),
+			expectedErr: errors.New(`malformed argument: "*.go:oneof(dev,my prod)", invalid build tag "my prod": build tags can only contain letters, digits, "_" and "."`),
+		},
+		"leading digits, dots and underscores in a tag": {
+			flags: newFlagSet(t, "*_386.go:386+go1.22+_private+été"),
+			expected: map[string][]string{
+				"*_386.go": {"386", "go1.22", "_private", "été"},
+			},
+		},
+		"escaped colon in a pattern": {
+			flags: newFlagSet(t, `foo\:bar.go:baz,re:foo\:[0-9]\.go:baz`),
+			expected: map[string][]string{
+				"foo:bar.go":       {"baz"},
+				`re:foo:[0-9]\.go`: {"baz"},
+			},
+		},
+		"escaped comma": {
+			flags: newFlagSet(t, `foo\,bar.go:baz,!foo\,baz.go`),
+			expected: map[string][]string{
+				"foo,bar.go": {"baz"},
+			},
+			expectedExcludes: []string{"foo,baz.go"},
+		},
+		"unescaped colon in a tag": {
+			flags:       newFlagSet(t, "foo:vendor:legacy"),
+			expectedErr: errors.New(`malformed argument: "foo:vendor:legacy", must be of the form "pattern:tag"`),
+		},
+		"exclude flag": {
+			flags:            withFlag(t, newFlagSet(t, "foo:bar,!*_mock.go"), FlagExcludeName, "*.pb.go, *_mock.go,re:gen_.*"),
+			expected:         map[string][]string{"foo": {"bar"}},
+			expectedExcludes: []string{"*.pb.go", "*_mock.go", "re:gen_.*"},
+		},
+		"invalid exclude flag": {
+			flags:       withFlag(t, newFlagSet(t, ""), FlagExcludeName, "re:foo("),
+			expectedErr: errors.New("malformed exclude: \"re:foo(\", invalid regular expression \"foo(\": error parsing regexp: missing closing ): `^(?:foo()$`"),
+		},
+		"test and non-test qualifiers": {
+			flags: newFlagSet(t, `test:*foo.go:integration,nontest:re:.*foo\.go:prod,!test:*_mock_test.go`),
+			expected: map[string][]string{
+				"test:*foo.go":         {"integration"},
+				`nontest:re:.*foo\.go`: {"prod"},
+			},
+			expectedExcludes: []string{"test:*_mock_test.go"},
+		},
+		"empty qualified pattern": {
+			flags:       newFlagSet(t, "test::foo"),
+			expectedErr: errors.New(`malformed argument: "test::foo", must be of the form "pattern:tag": empty pattern`),
+		},
+		"any and all of a group of build tags": {
+			flags: newFlagSet(t, "*_platform.go:anyof(linux,darwin),*_bundle.go:allof(foo, bar)"),
+			expected: map[string][]string{
+				"*_platform.go": {"anyof(linux,darwin)"},
+				"*_bundle.go":   {"allof(foo,bar)"},
+			},
+		},
+		"package pattern": {
+			flags: newFlagSet(t, `pkg:integrationtest:integration,test:pkg:re:.*_test:unit`),
+			expected: map[string][]string{
+				"pkg:integrationtest": {"integration"},
+				"test:pkg:re:.*_test": {"unit"},
+			},
+		},
+		"ignore file": {
+			flags:           withFlag(t, newFlagSet(t, "*_test.go:unit"), FlagIgnoreFileName, "testdata/ignorefile"),
+			expected:        map[string][]string{"*_test.go": {"unit"}},
+			expectedIgnores: []string{"zz_*.go", "fixtures/"},
+		},
+		"malformed ignore file": {
+			flags:       withFlag(t, newFlagSet(t, "*_test.go:unit"), FlagIgnoreFileName, "testdata/ignorefile_malformed"),
+			expectedErr: errors.New(`malformed pattern in ignore file "testdata/ignorefile_malformed" at line 1: "zz_[*.go", syntax error in pattern`),
+		},
+		"required constraint": {
+			flags:               withFlag(t, newFlagSet(t, ""), FlagRequireConstraintName, "internal/crypto/*.go, *_secure.go # audited"),
+			expected:            emptyFiletags,
+			expectedConstrained: []string{"internal/crypto/*.go", "*_secure.go"},
+		},
+		"malformed required constraint": {
+			flags:       withFlag(t, newFlagSet(t, ""), FlagRequireConstraintName, "re:foo("),
+			expectedErr: errors.New("malformed required constraint pattern: \"re:foo(\", invalid regular expression \"foo(\": error parsing regexp: missing closing ): `^(?:foo()$`"),
+		},
+		"missing ignore file": {
+			flags:       withFlag(t, newFlagSet(t, "*_test.go:unit"), FlagIgnoreFileName, "testdata/missing"),
+			expectedErr: errors.New(`cannot read ignore file: open testdata/missing: no such file or directory`),
+		},
+		"severities": {
+			flags: withFlag(t,
+				newFlagSet(t, "*_test.go:unit@warning+!e2e @ error,*_env.go:oneof(dev,prod)@warning,*_linux_amd64.go:linux && amd64@warning,foo.go:foo@warning,foo.go:foo"),
+				FlagDefaultTagName, "internal@warning"),
+			expected: map[string][]string{
+				"*_test.go":        {"unit", "!e2e"},
+				"*_env.go":         {"oneof(dev,prod)"},
+				"*_linux_amd64.go": {"amd64 && linux"},
+				"foo.go":           {"foo"},
+			},
+			expectedDefaults: []string{"internal"},
+			expectedWarnings: map[string][]string{
+				"*_test.go":        {"unit"},
+				"*_env.go":         {"oneof(dev,prod)"},
+				"*_linux_amd64.go": {"amd64 && linux"},
+				defaultPattern:     {"internal"},
+			},
+		},
+		"unknown severity": {
+			flags:       newFlagSet(t, "*_test.go:unit@info"),
+			expectedErr: errors.New(`malformed argument: "*_test.go:unit@info", unknown severity "info", must be "error" or "warning"`),
+		},
+		"default severity": {
+			flags: withFlag(t,
+				withFlag(t, newFlagSet(t, "*_test.go:unit+!e2e@error,*_env.go:oneof(dev,prod)"), FlagDefaultTagName, "internal"),
+				FlagDefaultSeverityName, "warning"),
+			expected: map[string][]string{
+				"*_test.go": {"unit", "!e2e"},
+				"*_env.go":  {"oneof(dev,prod)"},
+			},
+			expectedDefaults: []string{"internal"},
+			expectedWarnings: map[string][]string{
+				"*_test.go":    {"unit"},
+				"*_env.go":     {"oneof(dev,prod)"},
+				defaultPattern: {"internal"},
+			},
+		},
+		"unknown default severity": {
+			flags:       withFlag(t, newFlagSet(t, "*_test.go:unit"), FlagDefaultSeverityName, "info"),
+			expectedErr: errors.New(`unknown default severity "info", must be "error" or "warning"`),
+		},
+		"comments": {
+			flags: newFlagSet(t, "*_test.go:unit # run by the CI,# no rule,re:.*\\#v[0-9]\\.go:legacy#versioned, !mock_*.go # generated"),
+			expected: map[string][]string{
+				"*_test.go":         {"unit"},
+				"re:.*#v[0-9]\\.go": {"legacy"},
+			},
+			expectedExcludes: []string{"mock_*.go"},
+		},
+		"comment within a tag": {
+			flags:       withFlag(t, newFlagSet(t, ""), FlagFiletagsConfigName, "testdata/config/comment.yml"),
+			expectedErr: errors.New(`malformed rule in config file "testdata/config/comment.yml": "*_test.go: unit# run by the CI", invalid build tag "unit# run by the CI": build tags cannot contain "#"`),
+		},
+		"environment variables": {
+			flags: newFlagSet(t, "${FILEBUILDTAG_TEAM}/*_test.go:$FILEBUILDTAG_TEAM+${FILEBUILDTAG_TEAM}_unit,!${FILEBUILDTAG_TEAM}/mock_*.go,re:.*_v1\\.go$:legacy"),
+			expected: map[string][]string{
+				"payments/*_test.go": {"payments", "payments_unit"},
+				"re:.*_v1\\.go$":     {"legacy"},
+			},
+			expectedExcludes: []string{"payments/mock_*.go"},
+		},
+		"undefined environment variable": {
+			flags:       newFlagSet(t, "*_test.go:${FILEBUILDTAG_UNDEFINED}_unit"),
+			expectedErr: errors.New(`malformed argument: "*_test.go:${FILEBUILDTAG_UNDEFINED}_unit", undefined environment variable "FILEBUILDTAG_UNDEFINED"`),
+		},
+		"tags referring to groups": {
+			flags: newFlagSet(t, `re:service_(.+)_integration_test\.go:$1+integration,test:re:(\w+)/(\w+)_test\.go:!${2}_$1`),
+			expected: map[string][]string{
+				`re:service_(.+)_integration_test\.go`: {"${1}", "integration"},
+				`test:re:(\w+)/(\w+)_test\.go`:       {"!${2}_${1}"},
+			},
+		},
+		"tag referring to the groups of a pattern which is not a regular expression": {
+			flags:       newFlagSet(t, "service_*_test.go:$1"),
+			expectedErr: errors.New(`malformed argument: "service_*_test.go:$1", build tag "${1}" refers to groups, which only the patterns of the form "re:expr" capture`),
+		},
+		"tag referring to a missing group": {
+			flags:       newFlagSet(t, `re:service_(.+)_test\.go:${2}`),
+			expectedErr: errors.New(`malformed argument: "re:service_(.+)_test\.go:${2}", build tag "${2}" refers to the group ${2}, while the pattern has 1 groups`),
+		},
+		"tag referring to a group by name": {
+			flags:       withFlag(t, newFlagSet(t, ""), FlagFiletagsConfigName, "testdata/config/named_group.yml"),
+			expectedErr: errors.New(`malformed rule in config file "testdata/config/named_group.yml": "re:service_(?P<name>.+)_test\.go: ${name}", invalid build tag "${name}": groups must be referenced by number, such as "$1" or "${1}"`),
+		},
+		"tag referring to a group followed by letters": {
+			flags:    withFlag(t, newFlagSet(t, ""), FlagFiletagsConfigName, "testdata/config/short_group.yml"),
+			expected: map[string][]string{`re:service_(.+)_test\.go`: {"${1}_integration"}},
+		},
+		"invalid tag referring to a group": {
+			flags:       newFlagSet(t, `re:service_(.+)_test\.go:svc-$1`),
+			expectedErr: errors.New(`malformed argument: "re:service_(.+)_test\.go:svc-$1", invalid build tag "svc-${1}": apart from the references to groups, build tags can only contain letters, digits, "_" and "."`),
+		},
+		"default tags": {
+			flags:            withFlag(t, newFlagSet(t, "*_test.go:unit"), FlagDefaultTagName, " internal + !legacy"),
+			expected:         map[string][]string{"*_test.go": {"unit"}},
+			expectedDefaults: []string{"internal", "!legacy"},
+		},
+		"malformed default tags": {
+			flags:       withFlag(t, newFlagSet(t, "*_test.go:unit"), FlagDefaultTagName, "internal+!internal"),
+			expectedErr: errors.New(`malformed default tag: "internal+!internal", build tag "internal" is both expected and forbidden`),
+		},
+		"conditional rules": {
+			flags: newFlagSet(t, "tag:integration=>slow+!unit,test:tag:e2e => oneof(dev,prod),tag:db:slow"),
+			expected: map[string][]string{
+				"tag:integration": {"slow", "!unit"},
+				"test:tag:e2e":    {"oneof(dev,prod)"},
+				"tag:db":          {"slow"},
+			},
+		},
+		"conditional rule with an invalid tag": {
+			flags:       newFlagSet(t, "tag:!integration=>slow"),
+			expectedErr: errors.New(`malformed argument: "tag:!integration=>slow", invalid build tag "!integration" in pattern "tag:!integration"`),
+		},
+		"pattern alternatives": {
+			flags: newFlagSet(t, "{*_a.go|*_b.go | *_c.go}:tag,*_d.go:tag"),
+			expected: map[string][]string{
+				"{*_a.go|*_b.go | *_c.go}": {"tag"},
+				"*_d.go":                   {"tag"},
+			},
+		},
+		"empty pattern alternative": {
+			flags:       newFlagSet(t, "{*_a.go||*_c.go}:tag"),
+			expectedErr: errors.New(`malformed argument: "{*_a.go||*_c.go}:tag", empty alternative in pattern "{*_a.go||*_c.go}"`),
+		},
+		"negated pattern parts": {
+			flags: newFlagSet(t, "*.go&!*_generated.go&!{re:mock_.*}:prod"),
+			expected: map[string][]string{
+				"*.go&!*_generated.go&!{re:mock_.*}": {"prod"},
+			},
+		},
+		"negated first pattern part": {
+			flags:       newFlagSet(t, "!!*_a.go&*.go"),
+			expectedErr: errors.New(`malformed argument: "!!*_a.go&*.go", the first part of pattern "!*_a.go&*.go" cannot be negated`),
+		},
+		"empty pattern part": {
+			flags:       newFlagSet(t, "*.go&!:prod"),
+			expectedErr: errors.New(`malformed argument: "*.go&!:prod", empty part in pattern "*.go&!"`),
+		},
+		"regular expression": {
+			flags: newFlagSet(t, `re:.*_v[0-9]+\.go:legacy`),
+			expected: map[string][]string{
+				`re:.*_v[0-9]+\.go`: {"legacy"},
+			},
+		},
+		"directory name pattern": {
+			flags: newFlagSet(t, "dirname:*_integration:integration"),
+			expected: map[string][]string{
+				"dirname:*_integration": {"integration"},
+			},
+		},
+		"pattern nested too deeply": {
+			flags: newFlagSet(t, strings.Repeat("{", 20)+"*.go"+strings.Repeat("}", 20)+":tag"),
+			expectedErr: errors.New(`malformed argument: "` + strings.Repeat("{", 20) + "*.go" + strings.Repeat("}", 20) +
+				`:tag", pattern "` + strings.Repeat("{", 3) + "*.go" + strings.Repeat("}", 3) +
+				`" is nested too deeply, at most 16 levels are supported`),
+		},
+		"directory name pattern matching a path": {
+			flags:       newFlagSet(t, "dirname:internal/*_integration:integration"),
+			expectedErr: errors.New(`malformed argument: "dirname:internal/*_integration:integration", pattern "dirname:internal/*_integration" must match the name of the directory, "dir:" matching its path`),
+		},
+		"invalid regular expression": {
+			flags:       newFlagSet(t, "re:foo(:bar"),
+			expectedErr: errors.New("malformed argument: \"re:foo(:bar\", invalid regular expression \"foo(\": error parsing regexp: missing closing ): `^(?:foo()$`"),
+		},
+		"empty regular expression": {
+			flags:       newFlagSet(t, "re::bar"),
+			expectedErr: errors.New(`malformed argument: "re::bar", must be of the form "pattern:tag": empty pattern`),
+		},
+		"config file": {
+			flags: withFlag(t, newFlagSet(t, ""), FlagFiletagsConfigName, "testdata/config/filetags.yml"),
+			expected: map[string][]string{
+				"*_integration_test.go": {"integration"},
+				"*_e2e_test.go":         {"integration", "docker", "!unit"},
+				`re:.*_v[0-9]+\.go`:     {"legacy"},
+			},
+		},
+		"JSON config file": {
+			flags: withFlag(t, newFlagSet(t, ""), FlagFiletagsConfigName, "testdata/config/filetags.json"),
+			expected: map[string][]string{
+				"*_integration_test.go": {"integration"},
+				"*_e2e_test.go":         {"integration", "docker"},
+			},
+		},
+		"config file merged with the filetags flag": {
+			flags: withFlag(t, newFlagSet(t, "*_e2e_test.go:e2e,foo:bar"), FlagFiletagsConfigName, "testdata/config/filetags.json"),
+			expected: map[string][]string{
+				"*_integration_test.go": {"integration"},
+				"*_e2e_test.go":         {"integration", "docker", "e2e"},
+				"foo":                   {"bar"},
+			},
+		},
+		"missing config file": {
+			flags:       withFlag(t, newFlagSet(t, ""), FlagFiletagsConfigName, "testdata/config/missing.yml"),
+			expectedErr: errors.New("cannot read config file: open testdata/config/missing.yml: no such file or directory"),
+		},
+		"config file with unknown fields": {
+			flags:       withFlag(t, newFlagSet(t, ""), FlagFiletagsConfigName, "testdata/config/unknown.yml"),
+			expectedErr: errors.New("cannot parse config file \"testdata/config/unknown.yml\": yaml: unmarshal errors:\n  line 1: field tags not found in type filebuildtag.configFile"),
+		},
+		"config file with templates": {
+			flags: withFlag(t, newFlagSet(t, ""), FlagFiletagsConfigName, "testdata/config/templates.yml"),
+			expected: map[string][]string{
+				"*_payments_test.go":             {"fast", "unit", "payments"},
+				"*_payments_integration_test.go": {"integration", "payments"},
+				"*_billing_test.go":              {"unit", "billing"},
+				"*_billing_integration_test.go":  {"integration", "billing"},
+				"*_util_test.go":                 {"unit", "fast"},
+				"*_helper_test.go":               {"unit", "fast"},
+			},
+		},
+		"config file with an unknown template": {
+			flags:       withFlag(t, newFlagSet(t, ""), FlagFiletagsConfigName, "testdata/config/template_unknown.yml"),
+			expectedErr: errors.New(`malformed templates in config file "testdata/config/template_unknown.yml": unknown template "service"`),
+		},
+		"config file with an undefined template parameter": {
+			flags:       withFlag(t, newFlagSet(t, ""), FlagFiletagsConfigName, "testdata/config/template_parameter.yml"),
+			expectedErr: errors.New(`malformed templates in config file "testdata/config/template_parameter.yml": undefined parameter "team" of template "service"`),
+		},
+		"config file without profile": {
+			flags:            withFlag(t, newFlagSet(t, ""), FlagFiletagsConfigName, "testdata/config/profiles.yml"),
+			expected:         map[string][]string{"*_test.go": {"unit"}},
+			expectedExcludes: []string{"*_mock_test.go"},
+		},
+		"config file with the relaxed profile": {
+			flags: withFlag(t, withFlag(t, newFlagSet(t, ""), FlagFiletagsConfigName, "testdata/config/profiles.yml"),
+				FlagProfileName, "relaxed"),
+			expected: map[string][]string{
+				"*_test.go":             {"unit"},
+				"*_integration_test.go": {"integration"},
+			},
+			expectedExcludes: []string{"*_mock_test.go"},
+			expectedWarnings: map[string][]string{"*_integration_test.go": {"integration"}},
+		},
+		"config file with the strict profile": {
+			flags: withFlag(t, withFlag(t, newFlagSet(t, ""), FlagFiletagsConfigName, "testdata/config/profiles.yml"),
+				FlagProfileName, "strict"),
+			expected: map[string][]string{
+				"*_test.go":                      {"unit", "!debug"},
+				"*_integration_test.go":          {"integration"},
+				"*_payments_integration_test.go": {"integration", "payments"},
+			},
+			expectedExcludes: []string{"*_mock_test.go", "*_gen_test.go"},
+		},
+		"config file with an unknown profile": {
+			flags: withFlag(t, withFlag(t, newFlagSet(t, ""), FlagFiletagsConfigName, "testdata/config/profiles.yml"),
+				FlagProfileName, "nightly"),
+			expectedErr: errors.New(`unknown profile "nightly" in config file "testdata/config/profiles.yml", must be one of "relaxed", "strict"`),
+		},
+		"config file without profiles": {
+			flags: withFlag(t, withFlag(t, newFlagSet(t, ""), FlagFiletagsConfigName, "testdata/config/filetags.yml"),
+				FlagProfileName, "strict"),
+			expectedErr: errors.New(`unknown profile "strict" in config file "testdata/config/filetags.yml", which defines no profiles`),
+		},
+		"config file with excludes and without profiles": {
+			flags: withFlag(t, withFlag(t, newFlagSet(t, ""), FlagFiletagsConfigName, "testdata/config/exclude.yml"),
+				FlagProfileName, "strict"),
+			expectedErr: errors.New(`unknown profile "strict" in config file "testdata/config/exclude.yml", which defines no profiles`),
+		},
+		"profile without config file": {
+			flags:       withFlag(t, newFlagSet(t, "*_test.go:unit"), FlagProfileName, "strict"),
+			expectedErr: errors.New(`profile "strict" requires the filetags-config flag`),
+		},
+		"config file with a malformed rule": {
+			flags:       withFlag(t, newFlagSet(t, ""), FlagFiletagsConfigName, "testdata/config/malformed.yml"),
+			expectedErr: errors.New(`malformed rule in config file "testdata/config/malformed.yml": "foo: !!bar", forbidden tags must be of the form "!tag"`),
+		},
+		"excluded files": {
+			flags: newFlagSet(t, "*_test.go:unit,!*_mock_test.go, ! re:.*_gen_test\\.go"),
+			expected: map[string][]string{
+				"*_test.go": {"unit"},
+			},
+			expectedExcludes: []string{"*_mock_test.go", `re:.*_gen_test\.go`},
+		},
+		"empty exclude": {
+			flags:       newFlagSet(t, "*_test.go:unit,!"),
+			expectedErr: errors.New(`malformed argument: "!", excludes must be of the form "!pattern"`),
+		},
+		"config file with excluded files": {
+			flags: withFlag(t, newFlagSet(t, "!*_gen.go"), FlagFiletagsConfigName, "testdata/config/exclude.yml"),
+			expected: map[string][]string{
+				"*.go": {"prod"},
+			},
+			expectedExcludes: []string{"*_mock.go", "*_gen.go"},
+		},
+		"build constraint expression": {
+			flags: newFlagSet(t, "*_linux_amd64.go:linux && amd64 && linux,*_unix.go:(linux || darwin) && !cgo"),
+			expected: map[string][]string{
+				"*_linux_amd64.go": {"amd64 && linux"},
+				"*_unix.go":        {"!cgo && (darwin || linux)"},
+			},
+		},
+		"invalid build constraint expression": {
+			flags:       newFlagSet(t, "*_linux_amd64.go:linux && (amd64"),
+			expectedErr: errors.New(`malformed argument: "*_linux_amd64.go:linux && (amd64", invalid build constraint expression "linux && (amd64": missing close paren`),
+		},
+		"empty build tag among several": {
+			flags:       newFlagSet(t, "foo:bar+"),
+			expectedErr: errors.New(`malformed argument: "foo:bar+", must be of the form "pattern:tag": empty tag`),
+		},
+	}
+	for name, tt := range testCases {
+		t.Run(name, func(t *testing.T) {
+			found, err := parseFlags(tt.flags)
+			require.Equal(t, tt.expected, found.filetags)
+			require.Equal(t, tt.expectedExcludes, found.excludes)
+			require.Equal(t, tt.expectedDefaults, found.defaults)
+			require.Equal(t, tt.expectedIgnores, found.ignores)
+			require.Equal(t, tt.expectedConstrained, found.constrained)
+			require.Equal(t, tt.expectedWarnings, found.warnings)
+			if tt.expectedErr != nil {
+				assert.EqualError(t, err, tt.expectedErr.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func Test_parseFlags_RuleError(t *testing.T) {
+	testCases := map[string]struct {
+		flags        flag.FlagSet
+		expectedRule    string
+		expectedErr     error
+		expectedMessage string
+		unexpected      []error
+	}{
+		"malformed rule": {
+			flags:           newFlagSet(t, "foo:bar,baz"),
+			expectedRule:    "baz",
+			expectedErr:     ErrMalformedRule,
+			expectedMessage: `malformed argument: "baz", must be of the form "pattern:tag"`,
+			unexpected:      []error{ErrEmptyPattern, ErrEmptyTag},
+		},
+		"empty pattern": {
+			flags:           newFlagSet(t, ":foo"),
+			expectedRule:    ":foo",
+			expectedErr:     ErrEmptyPattern,
+			expectedMessage: `malformed argument: ":foo", must be of the form "pattern:tag": empty pattern`,
+			unexpected:      []error{ErrEmptyTag},
+		},
+		"empty tag": {
+			flags:           newFlagSet(t, "foo:bar+"),
+			expectedRule:    "foo:bar+",
+			expectedErr:     ErrEmptyTag,
+			expectedMessage: `malformed argument: "foo:bar+", must be of the form "pattern:tag": empty tag`,
+			unexpected:      []error{ErrEmptyPattern},
+		},
+		"rule of a config file": {
+			flags:           withFlag(t, newFlagSet(t, ""), FlagFiletagsConfigName, "testdata/config/empty_tag.yml"),
+			expectedRule:    "*_test.go: unit+",
+			expectedErr:     ErrEmptyTag,
+			expectedMessage: `malformed rule in config file "testdata/config/empty_tag.yml": "*_test.go: unit+", must be of the form "pattern:tag": empty tag`,
+		},
+	}
+	for name, tt := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := parseFlags(tt.flags)
+			var ruleErr *RuleError
+			require.True(t, errors.As(err, &ruleErr))
+			assert.Equal(t, tt.expectedRule, ruleErr.Rule)
+			assert.EqualError(t, err, tt.expectedMessage)
+			assert.True(t, errors.Is(err, tt.expectedErr))
+			assert.True(t, errors.Is(err, ErrMalformedRule))
+			for _, unexpected := range tt.unexpected {
+				assert.False(t, errors.Is(err, unexpected))
+			}
+		})
+	}
+	_, err := Config{Filetags: map[string][]string{"*_test.go": {""}}}.rules()
+	var ruleErr *RuleError
+	require.True(t, errors.As(err, &ruleErr))
+	assert.Equal(t, "*_test.go: ", ruleErr.Rule)
+	assert.EqualError(t, err, `malformed rule: "*_test.go: ", must be of the form "pattern:tag": empty tag`)
+	assert.True(t, errors.Is(err, ErrEmptyTag))
+}
+
+func Test_parseFlags_order(t *testing.T) {
+	testCases := map[string]struct {
+		flags    flag.FlagSet
+		expected []string
+	}{
+		"filetags flag": {
+			flags:    newFlagSet(t, "*_z.go:tag1,*_a.go:tag2,*_z.go:tag3,*_m.go:tag4"),
+			expected: []string{"*_z.go", "*_a.go", "*_m.go"},
+		},
+		"config file and its templates, then the filetags flag": {
+			flags: withFlag(t, newFlagSet(t, "*_other.go:tag1,*_payments_test.go:tag2"),
+				FlagFiletagsConfigName, "testdata/config/templates.yml"),
+			expected: []string{
+				"*_payments_test.go", "*_util_test.go", "*_helper_test.go", "*_payments_integration_test.go",
+				"*_billing_test.go", "*_billing_integration_test.go", "*_other.go",
+			},
+		},
+	}
+	for name, tt := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r, err := parseFlags(tt.flags)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, r.patterns())
+		})
+	}
+}
+
+func Fuzz_parseFlags(f *testing.F) {
+	seeds := []string{
+		"",
+		"*_test.go:unit",
+		"foo.go:bar,*_integration_test.go:integration+docker",
+		"*_env.go:oneof(dev,prod),*_os.go:anyof(linux,darwin),*_all.go:allof(tag1,tag2)",
+		`re:.*_v[0-9]+\.go:legacy`,
+		"{*_a.go|*_b.go}:tag1,*.go&!*_generated.go&!{re:mock_.*}:prod",
+		"test:pkg:re:.*_test:unit,nontest:dir:internal/legacy:!legacy",
+		"tag:integration=>slow,lines>500:large,imports:net/**:net",
+		"*_linux_amd64.go:linux && amd64,!*_mock_test.go",
+		`foo\:bar.go:legacy # comment, *.go:tag1:tag2`,
+		"*_test.go:unit@warning+!debug@error,${FILEBUILDTAG_UNDEFINED}:tag",
+		"::::,,,,((((}}}}\\",
+		"{{{{{{{{{{{{{{{{{{{{*.go}}}}}}}}}}}}}}}}}}}}:tag",
+		"**/**/**/**/**/**/**/**/**/**/**/**/**/**/**/**/foo.go:tag",
+		"*.go:tag1,*.go:tag2@warning,*.go:!tag1",
+	}
+	for _, seed := range seeds {
+		f.Add(seed, "")
+	}
+	f.Add("*.go:tag1", "*_test.go,re:mock_.*")
+	f.Fuzz(func(t *testing.T, filetags, exclude string) {
+		fs := flags()
+		for _, name := range []string{FlagFiletagsName, FlagExcludeName, FlagRequireConstraintName,
+			FlagPlatformGroupName} {
+			value := filetags
+			if name != FlagFiletagsName {
+				value = exclude
+			}
+			require.NoError(t, fs.Set(name, value))
+		}
+		r, err := parseFlags(fs)
+		if err != nil {
+			assert.NotEmpty(t, err.Error())
+			return
+		}
+		// The patterns of the rules parsed successfully are valid, and can be matched against files.
+		for _, pattern := range append(r.patterns(), r.excludes...) {
+			match, err := newMatcher(pattern, options{})
+			require.NoError(t, err, pattern)
+			match(file{name: "foo_test.go", path: "pkg/foo_test.go", pkg: "foo", dirname: "pkg", lines: 1})
+		}
+		r.describe()
+	})
+}
+
+func Test_rules_list(t *testing.T) {
+	r, err := parseFlags(newFlagSet(t, "*_z.go:tag1+tag2,*_a.go:tag3,*_z.go:tag4+tag1,*_m.go:tag5"))
+	require.NoError(t, err)
+	assert.Equal(t, []rule{
+		{pattern: "*_z.go", tag: "tag1"},
+		{pattern: "*_z.go", tag: "tag2"},
+		{pattern: "*_a.go", tag: "tag3"},
+		{pattern: "*_z.go", tag: "tag4"},
+		{pattern: "*_m.go", tag: "tag5"},
+	}, r.list)
+
+	dir, err := parseFlags(newFlagSet(t, "*_new.go:tag6,*_a.go:tag7+tag8"))
+	require.NoError(t, err)
+	merged := r.override(dir)
+	assert.Equal(t, []rule{
+		{pattern: "*_z.go", tag: "tag1"},
+		{pattern: "*_z.go", tag: "tag2"},
+		{pattern: "*_a.go", tag: "tag7"},
+		{pattern: "*_a.go", tag: "tag8"},
+		{pattern: "*_z.go", tag: "tag4"},
+		{pattern: "*_m.go", tag: "tag5"},
+		{pattern: "*_new.go", tag: "tag6"},
+	}, merged.list)
+	assert.Equal(t, []string{"tag7", "tag8"}, merged.filetags["*_a.go"])
+}
+
+func withFlag(t *testing.T, fs flag.FlagSet, name, value string) flag.FlagSet {
+	err := fs.Set(name, value)
+	require.NoError(t, err)
+	return fs
+}
+
+func Test_Config_order(t *testing.T) {
+	r, err := Config{
+		Filetags: map[string][]string{"*_z.go": {"tag1"}, "*_a.go": {"tag2"}},
+		Rules: []Rule{
+			{Pattern: "*_m.go", Tags: []string{"tag3"}},
+			{Pattern: "*_b.go", Tags: []string{"tag4", "tag5"}},
+			{Pattern: "*_z.go", Tags: []string{"tag6"}},
+		},
+	}.rules()
+	require.NoError(t, err)
+	// The Filetags come first in sorted pattern order, followed by the Rules in order.
+	assert.Equal(t, []string{"*_a.go", "*_z.go", "*_m.go", "*_b.go"}, r.patterns())
+	assert.Equal(t, []string{"tag1", "tag6"}, r.filetags["*_z.go"])
+	assert.Equal(t, []string{"tag4", "tag5"}, r.filetags["*_b.go"])
+}
+
+func Test_Config(t *testing.T) {
+	testCases := map[string]struct {
+		config           Config
+		expected         map[string][]string
+		expectedExcludes []string
+		expectedErr      error
+	}{
+		"empty config": {
+			expected: map[string][]string{},
+		},
+		"rules and excludes": {
+			config: Config{
+				Filetags: map[string][]string{
+					"*_test.go":  {"unit", "!integration"},
+					"*_env.go":   {"oneof(dev, prod)"},
+					"foo:bar.go": {"legacy"},
+				},
+				Exclude: []string{"*_mock_test.go"},
+			},
+			expected: map[string][]string{
+				"*_test.go":  {"unit", "!integration"},
+				"*_env.go":   {"oneof(dev,prod)"},
+				"foo:bar.go": {"legacy"},
+			},
+			expectedExcludes: []string{"*_mock_test.go"},
+		},
+		"malformed rule": {
+			config: Config{
+				Filetags: map[string][]string{"foo": {"!!bar"}},
+			},
+			expectedErr: errors.New(`malformed rule: "foo: !!bar", forbidden tags must be of the form "!tag"`),
+		},
+		"malformed exclude": {
+			config: Config{
+				Exclude: []string{"re:foo("},
+			},
+			expectedErr: errors.New("malformed exclude: \"re:foo(\", invalid regular expression \"foo(\": error parsing regexp: missing closing ): `^(?:foo()$`"),
+		},
+	}
+	for name, tt := range testCases {
+		t.Run(name, func(t *testing.T) {
+			found, err := tt.config.rules()
+			require.Equal(t, tt.expected, found.filetags)
+			require.Equal(t, tt.expectedExcludes, found.excludes)
+			if tt.expectedErr != nil {
+				assert.EqualError(t, err, tt.expectedErr.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func Test_rules_describe(t *testing.T) {
+	testCases := map[string]struct {
+		flags    flag.FlagSet
+		expected string
+	}{
+		"no rules": {
+			flags:    flag.FlagSet{},
+			expected: "filetags:\n  (none)\nexclude:\n  (none)\n",
+		},
+		"rules and excludes": {
+			flags: withFlag(t, newFlagSet(t, "*_test.go:unit+!integration, *_a .go:tag1,!mock_*.go,*_env.go:oneof(dev, prod)"),
+				FlagDefaultTagName, "internal"),
+			expected: `filetags:
+  "*_test.go": "unit", "!integration"
+  "*_a .go": "tag1"
+  "*_env.go": "oneof(dev,prod)"
+exclude:
+  "mock_*.go"
+default tags: "internal"
+`,
+		},
+	}
+	for name, tt := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r, err := parseFlags(tt.flags)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, r.describe())
+		})
+	}
+}
+
+func Test_options_satisfies(t *testing.T) {
+	testCases := map[string]struct {
+		opts       options
+		constraint string
+		tag        string
+		expected   bool
+	}{
+		"present tag": {
+			constraint: "//go:build linux",
+			tag:        "linux",
+			expected:   true,
+		},
+		"unix without the unix-tag flag": {
+			constraint: "//go:build unix",
+			tag:        "linux",
+			expected:   false,
+		},
+		"unix covering linux": {
+			opts:       options{unixTag: true},
+			constraint: "//go:build unix",
+			tag:        "linux",
+			expected:   true,
+		},
+		"unix covering darwin": {
+			opts:       options{unixTag: true},
+			constraint: "//go:build unix && amd64",
+			tag:        "darwin",
+			expected:   true,
+		},
+		"unix not covering windows": {
+			opts:       options{unixTag: true},
+			constraint: "//go:build unix",
+			tag:        "windows",
+			expected:   false,
+		},
+		"linux not covering darwin": {
+			opts:       options{unixTag: true},
+			constraint: "//go:build linux",
+			tag:        "darwin",
+			expected:   false,
+		},
+		"negated unix": {
+			opts:       options{unixTag: true},
+			constraint: "//go:build !unix",
+			tag:        "linux",
+			expected:   false,
+		},
+		"case-insensitive unix": {
+			opts:       options{unixTag: true, caseInsensitive: true},
+			constraint: "//go:build UNIX",
+			tag:        "Linux",
+			expected:   true,
+		},
+		"alias": {
+			opts:       options{aliases: []string{"e2e=end_to_end"}},
+			constraint: "//go:build end_to_end",
+			tag:        "e2e",
+			expected:   true,
+		},
+		"alias not satisfying the reverse": {
+			opts:       options{aliases: []string{"e2e=end_to_end"}},
+			constraint: "//go:build e2e",
+			tag:        "end_to_end",
+			expected:   false,
+		},
+		"aliases not chained": {
+			opts:       options{aliases: []string{"e2e=end_to_end", "end_to_end=endtoend"}},
+			constraint: "//go:build endtoend",
+			tag:        "e2e",
+			expected:   false,
+		},
+		"negated alias": {
+			opts:       options{aliases: []string{"e2e=end_to_end"}},
+			constraint: "//go:build !end_to_end",
+			tag:        "e2e",
+			expected:   false,
+		},
+		"case-insensitive alias": {
+			opts:       options{aliases: []string{"e2e=end_to_end"}, caseInsensitive: true},
+			constraint: "//go:build END_TO_END",
+			tag:        "E2E",
+			expected:   true,
+		},
+	}
+	for name, tt := range testCases {
+		t.Run(name, func(t *testing.T) {
+			expr, err := constraint.Parse(tt.constraint)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, tt.opts.satisfies(internal.Constraints{Expr: expr}, tt.tag))
+		})
+	}
+}
+
+func Test_options_validate(t *testing.T) {
+	for _, style := range []string{"", styleAny, styleNewOnly, styleBoth} {
+		assert.NoError(t, options{constraintStyle: style}.validate())
+	}
+	assert.EqualError(t, options{constraintStyle: "old-only"}.validate(),
+		`unknown constraint style "old-only", must be "any", "new-only" or "both"`)
+	assert.NoError(t, options{maxReports: 10}.validate())
+	assert.EqualError(t, options{maxReports: -1}.validate(),
+		"negative max reports -1, must be 0 to report every diagnostic or more")
+	for _, format := range []string{"", pathFormatBase, pathFormatModule, pathFormatAbsolute} {
+		assert.NoError(t, options{pathFormat: format}.validate())
+	}
+	assert.EqualError(t, options{pathFormat: "relative"}.validate(),
+		`unknown path format "relative", must be "base", "module" or "absolute"`)
+	assert.NoError(t, options{platforms: []string{"linux", "windows/amd64"}}.validate())
+	for _, platform := range []string{"/amd64", "linux/", "linux/amd64/v2"} {
+		assert.EqualError(t, options{platforms: []string{platform}}.validate(),
+			`malformed platform "`+platform+`", must be of the form "goos" or "goos/goarch"`)
+	}
+	assert.NoError(t, options{aliases: []string{"e2e=end_to_end", "e2e=e2e"}}.validate())
+	for _, entry := range []string{"e2e", "e2e=", "=end_to_end", "!e2e=end_to_end", "e2e=oneof(a,b)"} {
+		assert.EqualError(t, options{aliases: []string{entry}}.validate(),
+			`malformed tag alias "`+entry+`", must be of the form "tag=alias"`)
+	}
+	assert.EqualError(t, options{aliases: []string{"e2e=end-to-end"}}.validate(),
+		`invalid build tag "end-to-end": build tags can only contain letters, digits, "_" and "."`)
+	assert.NoError(t, options{ignoredTags: []string{"legacy", "gen.v2"}}.validate())
+	assert.EqualError(t, options{ignoredTags: []string{"legacy", "!legacy"}}.validate(),
+		`invalid build tag "!legacy": build tags can only contain letters, digits, "_" and "."`)
+	assert.NoError(t, options{generatedTag: "generated"}.validate())
+	assert.EqualError(t, options{generatedTag: "!generated"}.validate(),
+		`invalid build tag "!generated": build tags can only contain letters, digits, "_" and "."`)
+}
+
+func Test_aliasEntries(t *testing.T) {
+	entries := aliasEntries(map[string][]string{"e2e": {" end_to_end", "endtoend"}, " db ": {"database"}})
+	assert.Equal(t, []string{"db=database", "e2e=end_to_end", "e2e=endtoend"}, entries)
+	assert.Equal(t, []string{"e2e=end_to_end", "unit"}, trimAliases([]string{" e2e = end_to_end ", "", "unit"}))
+}
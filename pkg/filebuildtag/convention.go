@@ -0,0 +1,68 @@
+package filebuildtag
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aziule/filebuildtag/internal"
+	"golang.org/x/tools/go/analysis"
+)
+
+const (
+	// ConventionName is the name of the ConventionAnalyzer, which differs from the one of the Analyzer so that both
+	// can run together.
+	ConventionName = "filebuildtagconvention"
+	// ConventionDoc is the doc of the ConventionAnalyzer.
+	ConventionDoc = `ensure Go files have the build tags of the built-in filebuildtag conventions
+
+The files must have the following build tags, without any config:
+	Files named "*_integration_test.go" must have the "integration" build tag
+	Files named "*_unit_test.go" must have the "unit" build tag
+	Files named after a GOOS, such as "foo_linux.go", must have the GOOS build tag, or the "unix" one`
+)
+
+// ConventionAnalyzer is the filebuildtag linter checking the built-in conventions of ConventionConfig, so that
+// projects can adopt it without writing any config. It has no flags: projects customizing the rules use the Analyzer
+// or NewAnalyzer instead. Its result is a *Result.
+var ConventionAnalyzer = newConventionAnalyzer()
+
+// newConventionAnalyzer returns the ConventionAnalyzer.
+func newConventionAnalyzer() *analysis.Analyzer {
+	analyzer := NewAnalyzer(ConventionConfig())
+	analyzer.Name = ConventionName
+	analyzer.Doc = ConventionDoc
+	return analyzer
+}
+
+// ConventionConfig returns the config of the built-in conventions of the ConventionAnalyzer, which can be the
+// starting point of the config of NewAnalyzer:
+//   - the files named "*_integration_test.go" must have the "integration" build tag,
+//   - the files named "*_unit_test.go" must have the "unit" build tag,
+//   - the Go files named after a GOOS the Go toolchain recognizes must have its build tag, whether the GOOS is
+//     followed by a GOARCH, "_test" or other extensions, such as "foo_linux.go", "foo_linux_amd64_test.go" or
+//     "foo_linux.pb.go", the "unix" build tag satisfying the GOOS it covers.
+//
+// The GOOS rules make the constraints implied by the file names explicit, for the readers and the tools ignoring
+// them.
+func ConventionConfig() Config {
+	filetags := map[string][]string{
+		"*_integration_test.go": {"integration"},
+		"*_unit_test.go":        {"unit"},
+	}
+	for pattern, goos := range goosPatterns() {
+		filetags[pattern] = []string{goos}
+	}
+	return Config{Filetags: filetags, UnixTag: true}
+}
+
+// goosPatterns returns the patterns of the Go files named after each GOOS, bound to the GOOS. Like
+// internal.ImplicitConstraint, the GOOS is the last element of the name before its first ".", optionally followed by
+// a GOARCH and "_test".
+func goosPatterns() map[string]string {
+	arch := strings.Join(internal.KnownArch(), "|")
+	patterns := make(map[string]string)
+	for _, goos := range internal.KnownOS() {
+		patterns[fmt.Sprintf(`re:[^.]*_%s(_(%s))?(_test)?(\..*)?\.go`, goos, arch)] = goos
+	}
+	return patterns
+}
@@ -0,0 +1,40 @@
+package filebuildtag_test
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+
+	"github.com/aziule/filebuildtag/pkg/filebuildtag"
+)
+
+func ExampleBuildTags() {
+	src := `//go:build (linux && amd64) || !cgo
+
+package foo
+`
+	f, err := parser.ParseFile(token.NewFileSet(), "foo.go", src, parser.ParseComments)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(filebuildtag.BuildTags(f))
+	// Output: [linux amd64]
+}
+
+func ExampleBuildTags_plusBuild() {
+	src := `// +build integration,!unit docker
+
+package foo
+`
+	f, err := parser.ParseFile(token.NewFileSet(), "foo_test.go", src, parser.ParseComments)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(filebuildtag.BuildTags(f))
+	// Output: [integration docker]
+}
+
+func ExampleImplicitTags() {
+	fmt.Println(filebuildtag.ImplicitTags("foo_linux_amd64_test.go"))
+	// Output: [linux amd64]
+}
@@ -0,0 +1,190 @@
+package filebuildtag
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build/constraint"
+	"go/token"
+	"strings"
+
+	"github.com/aziule/filebuildtag/internal"
+	"golang.org/x/tools/go/analysis"
+)
+
+// addTagFix returns a fix adding the missing tags to the build constraints of the file.
+//
+// When the file already has build constraints, they are rewritten as a single "//go:build" line requiring both
+// the existing constraints and the tags, followed by the matching "// +build" lines if the file already had some.
+// Otherwise, both lines are inserted at the top of the file, separated from the rest of it by a blank line.
+func addTagFix(pass *analysis.Pass, f *ast.File, constraints internal.Constraints, tags ...string) analysis.SuggestedFix {
+	expr := constraints.Expr
+	for _, tag := range tags {
+		if expr == nil {
+			expr = &constraint.TagExpr{Tag: tag}
+			continue
+		}
+		expr = &constraint.AndExpr{X: expr, Y: &constraint.TagExpr{Tag: tag}}
+	}
+	message := fmt.Sprintf(`add missing build tag "%s"`, tags[0])
+	if len(tags) > 1 {
+		message = fmt.Sprintf(`add missing build tags %s`, quoteAll(tags))
+	}
+	return analysis.SuggestedFix{
+		Message:   message,
+		TextEdits: rewriteConstraints(pass, f, constraints, expr),
+	}
+}
+
+// removeTagFix returns a fix removing the forbidden tag from the build constraints of the file, keeping the rest of the
+// expression: "cgo && linux" becomes "linux" when "cgo" is forbidden. The negated occurrences of the tag are kept, as
+// they do not make the file have it, unlike the ones negated twice such as in "!(!cgo)". Like for the Go toolchain, the
+// "//go:build" line prevails over the "// +build" lines when the file has both. When nothing is left, the build
+// constraint lines are removed along with the blank line following them.
+func removeTagFix(pass *analysis.Pass, f *ast.File, constraints internal.Constraints, tag string, match func(string) bool) analysis.SuggestedFix {
+	fix := analysis.SuggestedFix{Message: fmt.Sprintf(`remove forbidden build tag "%s"`, tag)}
+	expr := constraints.Expr
+	if constraints.GoBuild != nil {
+		expr = constraints.GoBuild
+	}
+	if expr = removeTag(expr, match, false); expr != nil {
+		fix.TextEdits = rewriteConstraints(pass, f, constraints, expr)
+		return fix
+	}
+	for i, c := range constraints.Comments {
+		end := lineEnd(pass.Fset, c)
+		if i == len(constraints.Comments)-1 {
+			end = blankLineEnd(pass.Fset, end)
+		}
+		fix.TextEdits = append(fix.TextEdits, analysis.TextEdit{Pos: c.Pos(), End: end})
+	}
+	return fix
+}
+
+// removeTag returns the expression without the tags matching which are not negated, or nil when nothing is left. The
+// expression is itself negated when negated is true. The operands of the "&&" and "||" operators left alone replace
+// them.
+func removeTag(expr constraint.Expr, match func(string) bool, negated bool) constraint.Expr {
+	switch e := expr.(type) {
+	case *constraint.TagExpr:
+		if !negated && match(e.Tag) {
+			return nil
+		}
+	case *constraint.NotExpr:
+		x := removeTag(e.X, match, !negated)
+		if x == nil {
+			return nil
+		}
+		return &constraint.NotExpr{X: x}
+	case *constraint.AndExpr:
+		x, y := removeTag(e.X, match, negated), removeTag(e.Y, match, negated)
+		if x == nil || y == nil {
+			return orNil(x, y)
+		}
+		return &constraint.AndExpr{X: x, Y: y}
+	case *constraint.OrExpr:
+		x, y := removeTag(e.X, match, negated), removeTag(e.Y, match, negated)
+		if x == nil || y == nil {
+			return orNil(x, y)
+		}
+		return &constraint.OrExpr{X: x, Y: y}
+	}
+	return expr
+}
+
+// orNil returns x, or y when x is nil.
+func orNil(x, y constraint.Expr) constraint.Expr {
+	if x == nil {
+		return y
+	}
+	return x
+}
+
+// replaceConstraintsFix returns a fix replacing the build constraints of the file with the expression.
+func replaceConstraintsFix(pass *analysis.Pass, f *ast.File, constraints internal.Constraints, expr constraint.Expr) analysis.SuggestedFix {
+	return analysis.SuggestedFix{
+		Message:   fmt.Sprintf(`replace build constraints with "%s"`, expr),
+		TextEdits: rewriteConstraints(pass, f, constraints, expr),
+	}
+}
+
+// styleFix returns a fix rewriting the build constraints of the file as a "//go:build" line, followed by the
+// matching "// +build" lines or not.
+func styleFix(pass *analysis.Pass, f *ast.File, constraints internal.Constraints, expr constraint.Expr, withPlusBuild bool) analysis.SuggestedFix {
+	message := "remove the // +build lines"
+	if withPlusBuild {
+		message = "rewrite both the //go:build and // +build lines"
+	}
+	return analysis.SuggestedFix{
+		Message:   message,
+		TextEdits: rewriteConstraintLines(pass, f, constraints, expr, withPlusBuild),
+	}
+}
+
+// rewriteConstraints returns the edits replacing the build constraints of the file with the expression.
+func rewriteConstraints(pass *analysis.Pass, f *ast.File, constraints internal.Constraints, expr constraint.Expr) []analysis.TextEdit {
+	withPlusBuild := len(constraints.Comments) == 0 || constraints.HasPlusBuild()
+	return rewriteConstraintLines(pass, f, constraints, expr, withPlusBuild)
+}
+
+// rewriteConstraintLines returns the edits replacing the build constraints of the file with the expression, written
+// as a "//go:build" line optionally followed by its "// +build" lines.
+func rewriteConstraintLines(
+	pass *analysis.Pass, f *ast.File, constraints internal.Constraints, expr constraint.Expr, withPlusBuild bool,
+) []analysis.TextEdit {
+	text := constraintLines(expr, withPlusBuild)
+	if len(constraints.Comments) == 0 {
+		return []analysis.TextEdit{{
+			Pos:     f.FileStart,
+			End:     f.FileStart,
+			NewText: []byte(text + "\n\n"),
+		}}
+	}
+
+	first := constraints.Comments[0]
+	edits := []analysis.TextEdit{{
+		Pos:     first.Pos(),
+		End:     first.End(),
+		NewText: []byte(text),
+	}}
+	for _, c := range constraints.Comments[1:] {
+		edits = append(edits, analysis.TextEdit{
+			Pos: c.Pos(),
+			End: lineEnd(pass.Fset, c),
+		})
+	}
+	return edits
+}
+
+// constraintLines renders the expression as a "//go:build" line, optionally followed by its "// +build" lines.
+func constraintLines(expr constraint.Expr, withPlusBuild bool) string {
+	lines := []string{"//go:build " + expr.String()}
+	if withPlusBuild {
+		plusBuild, err := constraint.PlusBuildLines(expr)
+		if err == nil {
+			lines = append(lines, plusBuild...)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// lineEnd returns the position of the start of the line following the comment, so that removing the comment
+// does not leave an empty line behind.
+func lineEnd(fset *token.FileSet, c *ast.Comment) token.Pos {
+	file := fset.File(c.Pos())
+	line := file.Line(c.Pos())
+	if line < file.LineCount() {
+		return file.LineStart(line + 1)
+	}
+	return c.End()
+}
+
+// blankLineEnd returns the position of the start of the line following the line starting at pos when that line is
+// blank, so that removing the build constraints does not leave a blank line at the top of the file, or pos otherwise.
+func blankLineEnd(fset *token.FileSet, pos token.Pos) token.Pos {
+	file := fset.File(pos)
+	line := file.Line(pos)
+	if line < file.LineCount() && file.Offset(file.LineStart(line+1))-file.Offset(pos) == 1 {
+		return file.LineStart(line + 1)
+	}
+	return pos
+}
@@ -0,0 +1,113 @@
+package filebuildtag
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreDirective is the directive skipping a whole file, which must be placed before its package clause.
+const ignoreDirective = "//filebuildtag:ignore"
+
+// ignoreTag is the build tag conventionally excluding files from the builds, such as the standalone scripts run using
+// "go run". It is not a special tag for the Go toolchain, but no build defines it.
+const ignoreTag = "ignore"
+
+// isIgnored reports whether the file has the ignoreDirective before its package clause, or a "//nolint" directive
+// applying to the linter on its package clause, such as "//nolint:filebuildtag // legacy file".
+func isIgnored(fset *token.FileSet, f *ast.File) bool {
+	packageLine := fset.Position(f.Package).Line
+	for _, group := range f.Comments {
+		if fset.Position(group.Pos()).Line > packageLine {
+			break
+		}
+		for _, c := range group.List {
+			if c.Pos() < f.Package && isDirective(c.Text, ignoreDirective) {
+				return true
+			}
+			if fset.Position(c.Pos()).Line == packageLine && isNolint(c.Text) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isDirective reports whether the comment is the directive, optionally followed by an explanation.
+func isDirective(comment, directive string) bool {
+	rest, ok := strings.CutPrefix(comment, directive)
+	return ok && (rest == "" || rest[0] == ' ' || rest[0] == '\t')
+}
+
+// isNolint reports whether the comment is a "//nolint" directive applying to all the linters or to this one, using
+// the syntax of golangci-lint.
+func isNolint(comment string) bool {
+	text := strings.TrimSpace(strings.TrimPrefix(comment, "//"))
+	if !strings.HasPrefix(comment, "//") || !strings.HasPrefix(text, "nolint") {
+		return false
+	}
+	rest := strings.TrimPrefix(text, "nolint")
+	if rest == "" || rest[0] == ' ' || rest[0] == '\t' {
+		return true
+	}
+	linters, ok := strings.CutPrefix(rest, ":")
+	if !ok {
+		return false
+	}
+	linters, _, _ = strings.Cut(linters, " ")
+	for _, linter := range strings.Split(linters, ",") {
+		if linter == Name || linter == "all" {
+			return true
+		}
+	}
+	return false
+}
+
+// loadIgnoreFile reads the ignore file and returns its patterns. The file lists one pattern per line, blank lines and
+// lines starting with "#" being skipped.
+func loadIgnoreFile(filename string) ([]string, error) {
+	data, err := os.ReadFile(filepath.Clean(filename))
+	if err != nil {
+		return nil, fmt.Errorf("cannot read ignore file: %w", err)
+	}
+	var patterns []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		pattern := strings.TrimSpace(scanner.Text())
+		if pattern == "" || strings.HasPrefix(pattern, "#") {
+			continue
+		}
+		if _, err := path.Match(strings.TrimSuffix(pattern, "/"), ""); err != nil {
+			return nil, fmt.Errorf(`malformed pattern in ignore file "%s" at line %d: "%s", %w`, filename, line, pattern, err)
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, scanner.Err()
+}
+
+// ignoredBy reports whether the file matches a pattern of the ignore file. Patterns are resolved relative to the
+// directory of the package of the file, so they are matched against its name, except patterns ending with "/" which
+// match the directories the file is located in, such as "testdata/", at any depth within its module.
+func ignoredBy(patterns []string, f file) bool {
+	dirs := strings.Split(path.Dir(f.path), "/")
+	for _, pattern := range patterns {
+		if dirPattern, ok := strings.CutSuffix(pattern, "/"); ok {
+			for _, dir := range dirs {
+				if ok, _ := path.Match(dirPattern, dir); ok {
+					return true
+				}
+			}
+			continue
+		}
+		if ok, _ := path.Match(pattern, f.name); ok {
+			return true
+		}
+	}
+	return false
+}
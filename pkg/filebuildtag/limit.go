@@ -0,0 +1,44 @@
+package filebuildtag
+
+import (
+	"fmt"
+	"go/token"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// limitedPass returns a copy of the pass reporting at most max diagnostics, for the max-reports flag, along with the
+// function reporting the number of the suppressed ones, once the package is checked. As the files and the rules are
+// checked in a stable order, the same diagnostics are reported on each run. The note is reported at the package
+// clause of the first file of the package, like the unused patterns, or at the first suppressed diagnostic when the
+// package has no Go files.
+func limitedPass(pass *analysis.Pass, max int) (*analysis.Pass, func()) {
+	var reported, suppressed int
+	var firstSuppressed token.Pos
+	limited := *pass
+	limited.Report = func(d analysis.Diagnostic) {
+		if reported < max {
+			reported++
+			pass.Report(d)
+			return
+		}
+		if suppressed == 0 {
+			firstSuppressed = d.Pos
+		}
+		suppressed++
+	}
+	reportSuppressed := func() {
+		if suppressed == 0 {
+			return
+		}
+		pos := firstSuppressed
+		if len(pass.Files) > 0 {
+			pos = pass.Files[0].Package
+		}
+		pass.Report(analysis.Diagnostic{
+			Pos:     pos,
+			Message: fmt.Sprintf("%d more findings suppressed by the max-reports flag", suppressed),
+		})
+	}
+	return &limited, reportSuppressed
+}
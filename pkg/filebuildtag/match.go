@@ -0,0 +1,495 @@
+package filebuildtag
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// regexPrefix is the prefix of the patterns using regular expressions instead of path.Match patterns.
+const regexPrefix = "re:"
+
+// pkgPrefix is the prefix of the patterns matched against the name of the package of the files rather than their
+// name, such as "pkg:integrationtest". It comes after the qualifiers and before the regexPrefix.
+const pkgPrefix = "pkg:"
+
+// dirPrefix is the prefix of the patterns matched against the directory of the files relative to the root of their
+// module, such as "dir:internal/legacy", which also match the directories nested in the matching ones. It comes after
+// the qualifiers and before the regexPrefix.
+const dirPrefix = "dir:"
+
+// dirnamePrefix is the prefix of the patterns matched against the name of the directory of the files on disk, such
+// as "dirname:*_integration", rather than against their name. It comes after the qualifiers and before the
+// regexPrefix.
+const dirnamePrefix = "dirname:"
+
+// tagPrefix is the prefix of the patterns matching the files having a build tag rather than their name, such as
+// "tag:integration", to write conditional rules. It comes after the qualifiers and cannot be followed by other
+// prefixes.
+const tagPrefix = "tag:"
+
+// importsPrefix is the prefix of the patterns matching the files importing a package rather than their name, such as
+// "imports:database/sql". It comes after the qualifiers and cannot be followed by other prefixes.
+const importsPrefix = "imports:"
+
+// testPrefix and nonTestPrefix are the qualifiers restricting a pattern to the test files, named "*_test.go", and
+// to the other files. They come before the regexPrefix, such as "test:re:.*_db_test\.go".
+const (
+	testPrefix    = "test:"
+	nonTestPrefix = "nontest:"
+)
+
+// linesPattern matches the patterns comparing the line count of the files to a threshold, such as "lines>500".
+var linesPattern = regexp.MustCompile(`^lines(>=|<=|>|<)([0-9]+)$`)
+
+// patternPrefix returns the qualifier, the tagPrefix, the importsPrefix or the pkgPrefix, dirPrefix or dirnamePrefix
+// and the regexPrefix the pattern starts with, if any.
+func patternPrefix(pattern string) string {
+	prefix := ""
+	for _, qualifier := range []string{testPrefix, nonTestPrefix} {
+		if strings.HasPrefix(pattern, qualifier) {
+			prefix = qualifier
+			break
+		}
+	}
+	if strings.HasPrefix(pattern[len(prefix):], tagPrefix) {
+		return prefix + tagPrefix
+	}
+	if strings.HasPrefix(pattern[len(prefix):], importsPrefix) {
+		return prefix + importsPrefix
+	}
+	if strings.HasPrefix(pattern[len(prefix):], pkgPrefix) {
+		prefix += pkgPrefix
+	} else if strings.HasPrefix(pattern[len(prefix):], dirPrefix) {
+		prefix += dirPrefix
+	} else if strings.HasPrefix(pattern[len(prefix):], dirnamePrefix) {
+		prefix += dirnamePrefix
+	}
+	if strings.HasPrefix(pattern[len(prefix):], regexPrefix) {
+		prefix += regexPrefix
+	}
+	return prefix
+}
+
+// conditionTag returns the build tag a pattern prefixed with "tag:" matches and true, or false for the other patterns.
+func conditionTag(pattern string) (string, bool) {
+	prefix := patternPrefix(pattern)
+	if !strings.HasSuffix(prefix, tagPrefix) {
+		return "", false
+	}
+	return pattern[len(prefix):], true
+}
+
+// isTestFile reports whether the file is a test file, using the same rule as the Go toolchain.
+func isTestFile(f file) bool {
+	return strings.HasSuffix(f.name, "_test.go")
+}
+
+// file holds the names a file can be matched against.
+type file struct {
+	// name is the base name of the file.
+	name string
+	// path is the path of the file relative to the root of its module, using forward slashes.
+	path string
+	// pkg is the name of the package of the file.
+	pkg string
+	// tags are the build tags of the file, as returned by internal.Constraints.Tags.
+	tags []string
+	// lines is the number of lines of the file, which is the line of its end: a final newline does not start an
+	// extra line.
+	lines int
+	// imports are the import paths of the file.
+	imports []string
+	// dirname is the name of the directory of the file on disk.
+	dirname string
+	// generated is whether the file has the "// Code generated ... DO NOT EDIT." comment of the generated files.
+	generated bool
+}
+
+// matcher reports whether a file matches a pattern.
+type matcher func(f file) bool
+
+// newMatcher returns the matcher of the pattern. Patterns prefixed with "re:" are regular expressions which must
+// match the whole file name, any other pattern is matched using path.Match rather than filepath.Match, so that "\"
+// escapes the special characters on every OS. When the analysis is case-insensitive, both the pattern and the file
+// names are lowercased before being matched.
+//
+// Patterns containing a "/" are matched against the path of the file relative to the root of its module rather
+// than against its base name, the "**" elements matching any number of directories, such as
+// "internal/**/legacy_*.go". Both the pattern and the path are normalized to forward slashes beforehand, so that the
+// rules behave the same on Windows.
+//
+// Patterns prefixed with "test:" only match test files, and the ones prefixed with "nontest:" only match the other
+// files. Patterns prefixed with "pkg:" are matched against the name of the package of the file, and the ones prefixed
+// with "dir:" against its directory and the parent directories of the latter, while the ones prefixed with "dirname:"
+// are only matched against the name of its directory. Patterns of the form "{pattern1|pattern2}" match the files
+// matching any of the alternatives. Patterns prefixed with "tag:" match the files having the build tag, and the ones
+// prefixed with "imports:" the files importing a package whose path matches the rest of the pattern, like the path
+// patterns. Patterns of the form "pattern1&!pattern2" match the files matching every part of the pattern except its
+// negated ones, such as "*.go&!*_generated.go". Patterns of the form "lines>500" match the files whose line count is
+// above the threshold, using one of the ">", ">=", "<" and "<=" operators.
+//
+// The prefixes, alternatives and parts can be nested up to maxPatternDepth levels, so that the patterns coming from
+// untrusted configs cannot take quadratic time to compile.
+func newMatcher(pattern string, opts options) (matcher, error) {
+	return newNestedMatcher(pattern, opts, 0)
+}
+
+// maxPatternDepth is the maximum nesting level of the prefixes, alternatives and parts of a pattern, far above the
+// one of the patterns found in practice, such as "test:{pkg:foo|dir:bar}&!*_mock_test.go".
+const maxPatternDepth = 16
+
+// newNestedMatcher returns the matcher of a pattern nested at the depth of another one, the depth of the top-level
+// patterns being 0.
+func newNestedMatcher(pattern string, opts options, depth int) (matcher, error) {
+	if depth > maxPatternDepth {
+		return nil, fmt.Errorf(`pattern "%s" is nested too deeply, at most %d levels are supported`, pattern,
+			maxPatternDepth)
+	}
+	if parts := patternParts(pattern); len(parts) > 1 {
+		return newPartsMatcher(pattern, parts, opts, depth)
+	}
+	for qualifier, wantTest := range map[string]bool{testPrefix: true, nonTestPrefix: false} {
+		if rest, ok := strings.CutPrefix(pattern, qualifier); ok {
+			match, err := newNestedMatcher(rest, opts, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			return func(f file) bool { return isTestFile(f) == wantTest && match(f) }, nil
+		}
+	}
+	if tag, ok := strings.CutPrefix(pattern, tagPrefix); ok {
+		if !isTag(tag) || validateTag(tag) != nil {
+			return nil, fmt.Errorf(`invalid build tag "%s" in pattern "%s"`, tag, pattern)
+		}
+		return func(f file) bool {
+			for _, t := range f.tags {
+				if opts.fold(t) == opts.fold(tag) {
+					return true
+				}
+			}
+			return false
+		}, nil
+	}
+	if imported, ok := strings.CutPrefix(pattern, importsPrefix); ok {
+		imported = opts.fold(imported)
+		return func(f file) bool {
+			for _, importPath := range f.imports {
+				if matchPath(imported, opts.fold(importPath)) {
+					return true
+				}
+			}
+			return false
+		}, nil
+	}
+	if m := linesPattern.FindStringSubmatch(pattern); m != nil {
+		threshold, err := strconv.Atoi(m[2])
+		if err != nil {
+			return nil, fmt.Errorf(`invalid line count in pattern "%s": %w`, pattern, err)
+		}
+		return func(f file) bool {
+			switch m[1] {
+			case ">":
+				return f.lines > threshold
+			case ">=":
+				return f.lines >= threshold
+			case "<":
+				return f.lines < threshold
+			default:
+				return f.lines <= threshold
+			}
+		}, nil
+	}
+	if rest, ok := strings.CutPrefix(pattern, pkgPrefix); ok {
+		match, err := newNestedMatcher(rest, opts, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		// Package names are matched like file names.
+		return func(f file) bool { return match(file{name: f.pkg}) }, nil
+	}
+	if rest, ok := strings.CutPrefix(pattern, dirnamePrefix); ok {
+		if isPathPattern(strings.TrimPrefix(rest, regexPrefix)) {
+			return nil, fmt.Errorf(`pattern "%s" must match the name of the directory, "%s" matching its path`,
+				pattern, dirPrefix)
+		}
+		match, err := newNestedMatcher(rest, opts, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		// Directory names are matched like file names.
+		return func(f file) bool { return match(file{name: f.dirname}) }, nil
+	}
+	if rest, ok := strings.CutPrefix(pattern, dirPrefix); ok {
+		match, err := newNestedMatcher(rest, opts, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		return func(f file) bool {
+			// The files of the nested directories are within the matching directory too.
+			for dir := path.Dir(f.path); dir != "." && dir != "/"; dir = path.Dir(dir) {
+				if match(file{name: path.Base(dir), path: dir}) {
+					return true
+				}
+			}
+			return false
+		}, nil
+	}
+	if alternatives, ok := patternAlternatives(pattern); ok {
+		matchers := make([]matcher, 0, len(alternatives))
+		for _, alternative := range alternatives {
+			if alternative == "" || alternative == regexPrefix {
+				return nil, fmt.Errorf(`empty alternative in pattern "%s"`, pattern)
+			}
+			match, err := newNestedMatcher(alternative, opts, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			matchers = append(matchers, match)
+		}
+		return func(f file) bool {
+			for _, match := range matchers {
+				if match(f) {
+					return true
+				}
+			}
+			return false
+		}, nil
+	}
+	if expr, ok := strings.CutPrefix(pattern, regexPrefix); ok {
+		flags := ""
+		if opts.caseInsensitive {
+			flags = "(?i)"
+		}
+		re, err := regexp.Compile(flags + "^(?:" + expr + ")$")
+		if err != nil {
+			return nil, fmt.Errorf(`invalid regular expression "%s": %w`, expr, err)
+		}
+		if isPathPattern(expr) {
+			return func(f file) bool { return re.MatchString(f.path) }, nil
+		}
+		return func(f file) bool { return re.MatchString(f.name) }, nil
+	}
+	pattern = opts.fold(toSlash(pattern, filepath.Separator))
+	if isPathPattern(pattern) {
+		return func(f file) bool {
+			return matchPath(pattern, opts.fold(toSlash(f.path, filepath.Separator)))
+		}, nil
+	}
+	return func(f file) bool {
+		ok, _ := path.Match(pattern, opts.fold(f.name))
+		return ok
+	}, nil
+}
+
+// toSlash returns the pattern or path using forward slashes, separator being the path separator of the OS. On the
+// OS using "/", the backslashes are kept, as they escape the special characters of the patterns.
+func toSlash(value string, separator rune) string {
+	if separator == '/' {
+		return value
+	}
+	return strings.ReplaceAll(value, string(separator), "/")
+}
+
+// matchPath reports whether the path matches the pattern using path.Match for each of their elements, except for the
+// "**" elements of the pattern which match zero or more elements of the path. A "*" never crosses a "/".
+func matchPath(pattern, name string) bool {
+	return matchElements(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+// matchElements reports whether the elements of a path match the elements of a pattern. Like for the "*" of the
+// wildcard patterns, a mismatch only backtracks to the last "**" element, so that the patterns made of many "**"
+// elements are matched in linear time in the number of elements of both rather than in exponential time.
+func matchElements(patterns, names []string) bool {
+	p, n := 0, 0
+	// star is the index of the last "**" element, matching the names from next onwards when backtracking.
+	star, next := -1, 0
+	for n < len(names) {
+		switch {
+		case p < len(patterns) && patterns[p] == "**":
+			star, next = p, n
+			p++
+		case p < len(patterns) && matchElement(patterns[p], names[n]):
+			p++
+			n++
+		case star >= 0:
+			next++
+			p, n = star+1, next
+		default:
+			return false
+		}
+	}
+	for p < len(patterns) && patterns[p] == "**" {
+		p++
+	}
+	return p == len(patterns)
+}
+
+// matchElement reports whether the element of a path matches the element of a pattern.
+func matchElement(pattern, name string) bool {
+	ok, _ := path.Match(pattern, name)
+	return ok
+}
+
+// patternParts returns the parts of a pattern separated by the "&" found outside of brackets, so that regular
+// expressions such as "re:(a&b)" are kept whole.
+func patternParts(pattern string) []string {
+	return splitUnescaped(pattern, '&', "(){}[]")
+}
+
+// newPartsMatcher returns the matcher of a pattern made of several parts, such as "*.go&!*_generated.go", matching
+// the files which match every part except the negated parts. The first part cannot be negated, so that the patterns
+// always include files before excluding some of them.
+func newPartsMatcher(pattern string, parts []string, opts options, depth int) (matcher, error) {
+	var includes, excludes []matcher
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+		negated := strings.HasPrefix(part, "!")
+		if negated && i == 0 {
+			return nil, fmt.Errorf(`the first part of pattern "%s" cannot be negated`, pattern)
+		}
+		part = strings.TrimSpace(strings.TrimPrefix(part, "!"))
+		if part == patternPrefix(part) {
+			return nil, fmt.Errorf(`empty part in pattern "%s"`, pattern)
+		}
+		match, err := newNestedMatcher(part, opts, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		if negated {
+			excludes = append(excludes, match)
+		} else {
+			includes = append(includes, match)
+		}
+	}
+	return func(f file) bool {
+		for _, match := range includes {
+			if !match(f) {
+				return false
+			}
+		}
+		for _, match := range excludes {
+			if match(f) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+// patternAlternatives returns the alternatives of a pattern of the form "{pattern1|pattern2}" and true, or false if
+// the pattern is not of this form. Neither "{" nor "|" have a meaning in filepath.Match patterns, and the "|" found
+// within the parentheses of regular expressions do not separate alternatives.
+func patternAlternatives(pattern string) ([]string, bool) {
+	if !strings.HasPrefix(pattern, "{") || !strings.HasSuffix(pattern, "}") {
+		return nil, false
+	}
+	alternatives := splitUnescaped(pattern[1:len(pattern)-1], '|', "()")
+	for i := range alternatives {
+		alternatives[i] = strings.TrimSpace(alternatives[i])
+	}
+	return alternatives, true
+}
+
+// isPathPattern reports whether the pattern is matched against the path of the file rather than its name.
+func isPathPattern(pattern string) bool {
+	return strings.Contains(pattern, "/")
+}
+
+// newMatchers returns the matchers of the patterns, which must have been validated beforehand.
+func newMatchers(patterns []string, opts options) map[string]matcher {
+	matchers := make(map[string]matcher, len(patterns))
+	for _, pattern := range patterns {
+		m, err := newMatcher(pattern, opts)
+		if err != nil {
+			continue
+		}
+		matchers[pattern] = m
+	}
+	return matchers
+}
+
+// patternIndex finds the patterns matching a file without running the matcher of every pattern. The literal
+// patterns are indexed by the name they match and the patterns of the form "*suffix", the most common ones, by their
+// suffix, so that they are found with a lookup per suffix of the file name. The other patterns are matched one by
+// one.
+type patternIndex struct {
+	opts     options
+	names    map[string][]string
+	suffixes map[string][]string
+	others   map[string]matcher
+	// ranks are the positions of the patterns in the order they were given.
+	ranks map[string]int
+}
+
+// newPatternIndex returns the index of the patterns, which must have been validated beforehand.
+func newPatternIndex(patterns []string, opts options) *patternIndex {
+	idx := &patternIndex{
+		opts:     opts,
+		names:    make(map[string][]string),
+		suffixes: make(map[string][]string),
+		ranks:    make(map[string]int, len(patterns)),
+	}
+	var others []string
+	for i, pattern := range patterns {
+		idx.ranks[pattern] = i
+		key, isSuffix, ok := indexKey(opts.fold(pattern))
+		switch {
+		case !ok:
+			others = append(others, pattern)
+		case isSuffix:
+			idx.suffixes[key] = append(idx.suffixes[key], pattern)
+		default:
+			idx.names[key] = append(idx.names[key], pattern)
+		}
+	}
+	idx.others = newMatchers(others, opts)
+	return idx
+}
+
+// indexKey returns the name matched by a literal pattern, or the suffix matched by a pattern of the form "*suffix"
+// along with true, and false if the pattern can only be matched by its matcher.
+func indexKey(pattern string) (key string, isSuffix, ok bool) {
+	if patternPrefix(pattern) != "" {
+		return "", false, false
+	}
+	key, isSuffix = strings.CutPrefix(pattern, "*")
+	if key == "" || strings.ContainsAny(key, `*?[\/{&<>`) {
+		return "", false, false
+	}
+	return key, isSuffix, true
+}
+
+// match returns the patterns matching the file, in the order they were given to the index.
+func (idx *patternIndex) match(f file) []string {
+	name := idx.opts.fold(f.name)
+	patterns := append([]string(nil), idx.names[name]...)
+	if len(idx.suffixes) > 0 {
+		for i := range name {
+			patterns = append(patterns, idx.suffixes[name[i:]]...)
+		}
+	}
+	for pattern, match := range idx.others {
+		if match(f) {
+			patterns = append(patterns, pattern)
+		}
+	}
+	sort.Slice(patterns, func(i, j int) bool { return idx.ranks[patterns[i]] < idx.ranks[patterns[j]] })
+	return patterns
+}
+
+// matchAny returns a matcher reporting whether a file matches any of the matchers.
+func matchAny(matchers map[string]matcher) matcher {
+	return func(f file) bool {
+		for _, match := range matchers {
+			if match(f) {
+				return true
+			}
+		}
+		return false
+	}
+}
@@ -0,0 +1,427 @@
+package filebuildtag
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_newMatcher(t *testing.T) {
+	testCases := map[string]struct {
+		pattern  string
+		opts     options
+		file     file
+		expected bool
+	}{
+		"glob pattern": {
+			pattern:  "*_test.go",
+			file:     file{name: "foo_test.go", path: "pkg/foo_test.go"},
+			expected: true,
+		},
+		"glob pattern is case-sensitive by default": {
+			pattern:  "*_test.go",
+			file:     file{name: "foo_TEST.go", path: "pkg/foo_TEST.go"},
+			expected: false,
+		},
+		"case-insensitive glob pattern": {
+			pattern:  "*_Test.go",
+			opts:     options{caseInsensitive: true},
+			file:     file{name: "foo_TEST.go", path: "pkg/foo_TEST.go"},
+			expected: true,
+		},
+		"case-insensitive glob pattern with unicode characters": {
+			pattern:  "ÉTÉ_*.go",
+			opts:     options{caseInsensitive: true},
+			file:     file{name: "été_foo.go", path: "pkg/été_foo.go"},
+			expected: true,
+		},
+		"regular expression is case-sensitive by default": {
+			pattern:  "re:foo_[a-z]+\\.go",
+			file:     file{name: "foo_BAR.go", path: "pkg/foo_BAR.go"},
+			expected: false,
+		},
+		"case-insensitive regular expression": {
+			pattern:  "re:foo_[a-z]+\\.go",
+			opts:     options{caseInsensitive: true},
+			file:     file{name: "foo_BAR.go", path: "pkg/foo_BAR.go"},
+			expected: true,
+		},
+		"regular expression must match the whole name": {
+			pattern:  "re:foo",
+			file:     file{name: "foo.go", path: "pkg/foo.go"},
+			expected: false,
+		},
+		"path pattern": {
+			pattern:  "pkg/*/foo.go",
+			file:     file{name: "foo.go", path: "pkg/bar/foo.go"},
+			expected: true,
+		},
+		"path pattern does not match the base name": {
+			pattern:  "pkg/*.go",
+			file:     file{name: "foo.go", path: "internal/foo.go"},
+			expected: false,
+		},
+		"double star path pattern crosses several directories": {
+			pattern:  "internal/**/legacy_*.go",
+			file:     file{name: "legacy_foo.go", path: "internal/a/b/c/legacy_foo.go"},
+			expected: true,
+		},
+		"double star path pattern matches no directory": {
+			pattern:  "internal/**/legacy_*.go",
+			file:     file{name: "legacy_foo.go", path: "internal/legacy_foo.go"},
+			expected: true,
+		},
+		"double star path pattern still matches the other elements": {
+			pattern:  "internal/**/legacy_*.go",
+			file:     file{name: "legacy_foo.go", path: "pkg/a/legacy_foo.go"},
+			expected: false,
+		},
+		"leading double star path pattern": {
+			pattern:  "**/testutil/*.go",
+			file:     file{name: "foo.go", path: "a/b/testutil/foo.go"},
+			expected: true,
+		},
+		"several double star path pattern elements": {
+			pattern:  "internal/**/legacy/**/foo_*.go",
+			file:     file{name: "foo_a.go", path: "internal/a/legacy/b/legacy/c/foo_a.go"},
+			expected: true,
+		},
+		"trailing double star path pattern": {
+			pattern:  "internal/**",
+			file:     file{name: "foo.go", path: "internal/a/foo.go"},
+			expected: true,
+		},
+		"many double star path pattern elements": {
+			pattern:  strings.Repeat("**/", 40) + "foo.go",
+			file:     file{name: "bar.go", path: strings.Repeat("a/", 40) + "bar.go"},
+			expected: false,
+		},
+		"single star path pattern does not cross directories": {
+			pattern:  "internal/*/legacy_*.go",
+			file:     file{name: "legacy_foo.go", path: "internal/a/b/legacy_foo.go"},
+			expected: false,
+		},
+		"double star base name pattern is a single star": {
+			pattern:  "**_test.go",
+			file:     file{name: "foo_test.go", path: "internal/a/foo_test.go"},
+			expected: true,
+		},
+		"test qualifier matches test files": {
+			pattern:  "test:*foo*.go",
+			file:     file{name: "foo_test.go", path: "pkg/foo_test.go"},
+			expected: true,
+		},
+		"test qualifier does not match other files": {
+			pattern:  "test:*foo*.go",
+			file:     file{name: "foo.go", path: "pkg/foo.go"},
+			expected: false,
+		},
+		"non-test qualifier matches other files": {
+			pattern:  "nontest:re:foo.*",
+			file:     file{name: "foo.go", path: "pkg/foo.go"},
+			expected: true,
+		},
+		"non-test qualifier does not match test files": {
+			pattern:  "nontest:re:foo.*",
+			file:     file{name: "foo_test.go", path: "pkg/foo_test.go"},
+			expected: false,
+		},
+		"package pattern": {
+			pattern:  "pkg:integration*",
+			file:     file{name: "foo.go", path: "pkg/foo.go", pkg: "integrationtest"},
+			expected: true,
+		},
+		"package pattern does not match the file name": {
+			pattern:  "pkg:foo*",
+			file:     file{name: "foo.go", path: "pkg/foo.go", pkg: "bar"},
+			expected: false,
+		},
+		"package regular expression": {
+			pattern:  "test:pkg:re:.*_test",
+			file:     file{name: "foo_test.go", path: "pkg/foo_test.go", pkg: "foo_test"},
+			expected: true,
+		},
+		"directory pattern": {
+			pattern:  "dir:internal/legacy",
+			file:     file{name: "foo.go", path: "internal/legacy/foo.go"},
+			expected: true,
+		},
+		"directory pattern matching a parent directory": {
+			pattern:  "dir:internal/legacy",
+			file:     file{name: "foo.go", path: "internal/legacy/sub/foo.go"},
+			expected: true,
+		},
+		"directory pattern not matching a sibling directory": {
+			pattern:  "dir:internal/legacy",
+			file:     file{name: "foo.go", path: "internal/legacyv2/foo.go"},
+			expected: false,
+		},
+		"directory pattern not matching the file name": {
+			pattern:  "dir:foo.go",
+			file:     file{name: "foo.go", path: "foo.go"},
+			expected: false,
+		},
+		"directory name pattern": {
+			pattern:  "dir:legacy",
+			file:     file{name: "foo.go", path: "internal/legacy/sub/foo.go"},
+			expected: true,
+		},
+		"directory name": {
+			pattern:  "dirname:*_integration",
+			file:     file{name: "foo.go", path: "pkg/foo_integration/foo.go", dirname: "foo_integration"},
+			expected: true,
+		},
+		"directory name does not match the parent directories": {
+			pattern:  "dirname:pkg",
+			file:     file{name: "foo.go", path: "pkg/foo_integration/foo.go", dirname: "foo_integration"},
+			expected: false,
+		},
+		"directory name regular expression": {
+			pattern:  "dirname:re:v[0-9]+",
+			file:     file{name: "foo.go", path: "internal/v2/foo.go", dirname: "v2"},
+			expected: true,
+		},
+		"directory regular expression": {
+			pattern:  "dir:re:internal/v[0-9]+",
+			file:     file{name: "foo.go", path: "internal/v2/foo.go"},
+			expected: true,
+		},
+		"imports pattern": {
+			pattern:  "imports:database/sql",
+			file:     file{name: "foo.go", path: "pkg/foo.go", imports: []string{"fmt", "database/sql"}},
+			expected: true,
+		},
+		"imports pattern does not match a nested package": {
+			pattern:  "imports:database/sql",
+			file:     file{name: "foo.go", path: "pkg/foo.go", imports: []string{"database/sql/driver"}},
+			expected: false,
+		},
+		"imports pattern does not match the file name": {
+			pattern:  "imports:foo.go",
+			file:     file{name: "foo.go", path: "foo.go"},
+			expected: false,
+		},
+		"tag pattern": {
+			pattern:  "tag:integration",
+			file:     file{name: "foo.go", path: "pkg/foo.go", tags: []string{"linux", "integration"}},
+			expected: true,
+		},
+		"tag pattern does not match other tags": {
+			pattern:  "tag:integration",
+			file:     file{name: "integration.go", path: "pkg/integration.go", tags: []string{"unit"}},
+			expected: false,
+		},
+		"parts match the included files": {
+			pattern:  "*.go&!*_generated.go&!{re:mock_.*}",
+			file:     file{name: "foo.go", path: "pkg/foo.go"},
+			expected: true,
+		},
+		"parts do not match the excluded files": {
+			pattern:  "*.go&!*_generated.go&!{re:mock_.*}",
+			file:     file{name: "foo_generated.go", path: "pkg/foo_generated.go"},
+			expected: false,
+		},
+		"parts do not match the files excluded by a later part": {
+			pattern:  "*.go&!*_generated.go&!{re:mock_.*}",
+			file:     file{name: "mock_foo.go", path: "pkg/mock_foo.go"},
+			expected: false,
+		},
+		"parts do not match the files not included": {
+			pattern:  "*.go&!*_generated.go",
+			file:     file{name: "foo.s", path: "pkg/foo.s"},
+			expected: false,
+		},
+		"parts must all match": {
+			pattern:  "test:*.go&pkg/*",
+			file:     file{name: "foo_test.go", path: "internal/foo_test.go"},
+			expected: false,
+		},
+		"regular expressions are not split within parentheses": {
+			pattern:  "re:foo(&|_)bar\\.go",
+			file:     file{name: "foo&bar.go", path: "pkg/foo&bar.go"},
+			expected: true,
+		},
+		"line count above the threshold": {
+			pattern:  "lines>500",
+			file:     file{name: "foo.go", lines: 501},
+			expected: true,
+		},
+		"line count at the threshold": {
+			pattern:  "lines>500",
+			file:     file{name: "foo.go", lines: 500},
+			expected: false,
+		},
+		"line count at the inclusive threshold": {
+			pattern:  "lines>=500",
+			file:     file{name: "foo.go", lines: 500},
+			expected: true,
+		},
+		"line count below the threshold": {
+			pattern:  "lines<10",
+			file:     file{name: "foo.go", lines: 9},
+			expected: true,
+		},
+		"line count above the upper threshold": {
+			pattern:  "lines<=10",
+			file:     file{name: "foo.go", lines: 11},
+			expected: false,
+		},
+		"line count combined with a file pattern": {
+			pattern:  "*.go&!*_test.go&lines>500",
+			file:     file{name: "foo_test.go", lines: 1000},
+			expected: false,
+		},
+		"alternatives match any of the patterns": {
+			pattern:  "{*_a.go|re:.*_(b|c)\\.go|pkg/*.go}",
+			file:     file{name: "foo_c.go", path: "internal/foo_c.go"},
+			expected: true,
+		},
+		"alternatives match none of the patterns": {
+			pattern:  "{*_a.go|re:.*_(b|c)\\.go|pkg/*.go}",
+			file:     file{name: "foo_d.go", path: "internal/foo_d.go"},
+			expected: false,
+		},
+		"alternatives match paths": {
+			pattern:  "test:{*_a.go|pkg/*.go}",
+			file:     file{name: "foo_test.go", path: "pkg/foo_test.go"},
+			expected: true,
+		},
+		"path pattern using the separator of the OS": {
+			pattern:  filepath.Join("pkg", "*", "foo.go"),
+			file:     file{name: "foo.go", path: "pkg/bar/foo.go"},
+			expected: true,
+		},
+	}
+	for name, tt := range testCases {
+		t.Run(name, func(t *testing.T) {
+			match, err := newMatcher(tt.pattern, tt.opts)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, match(tt.file))
+		})
+	}
+}
+
+func Test_toSlash(t *testing.T) {
+	testCases := map[string]struct {
+		value     string
+		separator rune
+		expected  string
+	}{
+		"backslashes on windows":            {value: `internal\legacy\*.go`, separator: '\\', expected: "internal/legacy/*.go"},
+		"forward slashes on windows":        {value: "internal/legacy/*.go", separator: '\\', expected: "internal/legacy/*.go"},
+		"mixed separators on windows":       {value: `internal/legacy\*.go`, separator: '\\', expected: "internal/legacy/*.go"},
+		"forward slashes on unix":           {value: "internal/legacy/*.go", separator: '/', expected: "internal/legacy/*.go"},
+		"escaping backslashes kept on unix": {value: `internal/\*.go`, separator: '/', expected: `internal/\*.go`},
+	}
+	for name, tt := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, toSlash(tt.value, tt.separator))
+		})
+	}
+}
+
+func Test_patternIndex(t *testing.T) {
+	patterns := []string{
+		"foo.go", "*_test.go", "*_a.go", "*.go", "*", "ba?.go", "FOO*.go", "[ab]_a.go", "test:*_a.go", "nontest:*.go",
+		"pkg:foo", "tag:integration", "lines>2", "re:.*_v[0-9]+\\.go", "internal/*.go", "{*_a.go|*_b.go}", "*.go&!*_a.go", "*Foo.go",
+	}
+	files := []file{
+		{name: "foo.go", path: "foo.go", pkg: "foo", lines: 3},
+		{name: "Foo.go", path: "internal/Foo.go", pkg: "bar"},
+		{name: "bar.go", path: "pkg/bar.go", pkg: "bar"},
+		{name: "a_a.go", path: "a_a.go", pkg: "foo"},
+		{name: "foo_a_test.go", path: "foo_a_test.go", pkg: "foo", tags: []string{"integration"}},
+		{name: "api_v1.go", path: "api/api_v1.go", pkg: "api"},
+		{name: "x_b.go", path: "x_b.go", pkg: "x"},
+		{name: "asm.s", path: "asm.s", pkg: "x"},
+	}
+	for _, opts := range []options{{}, {caseInsensitive: true}} {
+		idx := newPatternIndex(patterns, opts)
+		matchers := newMatchers(patterns, opts)
+		for _, f := range files {
+			t.Run(fmt.Sprintf("%s/case-insensitive=%t", f.name, opts.caseInsensitive), func(t *testing.T) {
+				// The patterns are expected in the order they were given.
+				var expected []string
+				for _, pattern := range patterns {
+					if matchers[pattern](f) {
+						expected = append(expected, pattern)
+					}
+				}
+				assert.Equal(t, expected, idx.match(f))
+			})
+		}
+	}
+}
+
+func Benchmark_patternIndex(b *testing.B) {
+	patterns := []string{"re:.*_v[0-9]+\\.go", "internal/legacy/*.go", "test:*_db_test.go", "pkg:integrationtest"}
+	for i := 0; i < 200; i++ {
+		patterns = append(patterns, fmt.Sprintf("*_svc%d_test.go", i), fmt.Sprintf("*_svc%d.go", i))
+	}
+	files := make([]file, 0, 1000)
+	for i := 0; i < cap(files); i++ {
+		name := fmt.Sprintf("handler_svc%d.go", i%300)
+		files = append(files, file{name: name, path: "internal/svc/" + name, pkg: "svc"})
+	}
+	b.Run("naive", func(b *testing.B) {
+		matchers := newMatchers(patterns, options{})
+		for i := 0; i < b.N; i++ {
+			for _, f := range files {
+				var matched []string
+				for pattern, match := range matchers {
+					if match(f) {
+						matched = append(matched, pattern)
+					}
+				}
+				sort.Strings(matched)
+			}
+		}
+	})
+	b.Run("indexed", func(b *testing.B) {
+		idx := newPatternIndex(patterns, options{})
+		for i := 0; i < b.N; i++ {
+			for _, f := range files {
+				idx.match(f)
+			}
+		}
+	})
+}
+
+func Test_goosPatterns(t *testing.T) {
+	testCases := map[string]string{
+		"foo.go":                    "",
+		"linux.go":                  "",
+		"foo_linux.go":              "linux",
+		"foo_linux_test.go":         "linux",
+		"foo_linux_amd64.go":        "linux",
+		"foo_windows_arm64_test.go": "windows",
+		"foo_amd64_linux.go":        "linux",
+		"foo_linux.pb.go":           "linux",
+		"foo_linux_helper.go":       "",
+		"foo_amd64.go":              "",
+		"foo_linux.s":               "",
+	}
+	patterns := goosPatterns()
+	for filename, expected := range testCases {
+		t.Run(filename, func(t *testing.T) {
+			var matched []string
+			for pattern, goos := range patterns {
+				match, err := newMatcher(pattern, options{})
+				require.NoError(t, err)
+				if match(file{name: filename, path: filename}) {
+					matched = append(matched, goos)
+				}
+			}
+			if expected == "" {
+				assert.Empty(t, matched)
+				return
+			}
+			assert.Equal(t, []string{expected}, matched)
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package filebuildtag
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// parseOnly returns the files of the only flag, given as a comma-separated list of files. Arguments prefixed with "@"
+// are files listing the files one per line, such as the output of "git diff --name-only", blank lines and lines
+// starting with "#" being skipped.
+func parseOnly(value string) ([]string, error) {
+	// The list is not nil even when empty, as nothing is reported then.
+	only := []string{}
+	for _, arg := range strings.Split(value, ",") {
+		arg = strings.TrimSpace(arg)
+		listFile, ok := strings.CutPrefix(arg, "@")
+		if !ok {
+			if arg != "" {
+				only = append(only, cleanOnly(arg))
+			}
+			continue
+		}
+		data, err := os.ReadFile(filepath.Clean(listFile))
+		if err != nil {
+			return nil, fmt.Errorf("cannot read the list of files: %w", err)
+		}
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" && !strings.HasPrefix(line, "#") {
+				only = append(only, cleanOnly(line))
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("cannot read the list of files: %w", err)
+		}
+	}
+	return only, nil
+}
+
+// cleanOnly returns the file of the only flag using forward slashes, without its leading "./".
+func cleanOnly(name string) string {
+	return path.Clean(filepath.ToSlash(name))
+}
+
+// listed reports whether the file is one of the files of the only flag. The files containing a "/" are matched
+// against the path of the file relative to the root of its module, and the other ones against its name.
+func listed(only []string, f file) bool {
+	for _, name := range only {
+		if name == f.path || (!isPathPattern(name) && name == f.name) {
+			return true
+		}
+	}
+	return false
+}
+
+// onlyPass returns a copy of the pass only reporting the diagnostics of the files of the only flag, along with
+// the function reporting whether a position is in one of them. The files of the testdata directory, checked by the
+// include-testdata flag, are found under the directory of the package, like checkTestdataFile does, and are only
+// listed by their path, so that the name of a file of the package does not list the samples having the same name.
+func onlyPass(pass *analysis.Pass, only []string) (*analysis.Pass, func(token.Pos) bool) {
+	var dir string
+	if len(pass.Files) > 0 {
+		dir = filepath.Dir(pass.Fset.Position(pass.Files[0].Pos()).Filename)
+	}
+	isListed := func(pos token.Pos) bool {
+		filename := pass.Fset.Position(pos).Filename
+		rel, err := filepath.Rel(dir, filename)
+		if dir == "" || err != nil || !strings.HasPrefix(rel, testdataDir+string(filepath.Separator)) {
+			name := filepath.Base(filename)
+			return listed(only, file{name: name, path: path.Join(pkgDir(pass), name)})
+		}
+		// Leaving the name out of the file restricts the match to its path.
+		return listed(only, file{path: path.Join(pkgDir(pass), filepath.ToSlash(rel))})
+	}
+	filtered := *pass
+	filtered.Report = func(d analysis.Diagnostic) {
+		if isListed(d.Pos) {
+			pass.Report(d)
+		}
+	}
+	return &filtered, isListed
+}
+
+// filter returns the result restricted to the files, checks and violations located at the positions.
+func (r *Result) filter(keep func(token.Pos) bool) *Result {
+	filtered := &Result{TagViolations: make(map[string]int)}
+	for pos, matched := range r.files {
+		if keep(pos) {
+			filtered.addFile(pos, matched)
+		}
+	}
+	for _, check := range r.Checks {
+		if keep(check.Pos) {
+			filtered.Checks = append(filtered.Checks, check)
+		}
+	}
+	for _, v := range r.Violations {
+		if keep(v.Pos) {
+			filtered.add(v)
+		}
+	}
+	return filtered
+}
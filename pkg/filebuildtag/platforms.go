@@ -0,0 +1,186 @@
+package filebuildtag
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/aziule/filebuildtag/internal"
+	"golang.org/x/tools/go/analysis"
+)
+
+// platformFile is a file of a platform group, along with its build constraints.
+type platformFile struct {
+	name string
+	// shown is the name of the file shown in the messages, according to the path-format flag.
+	shown       string
+	constraints internal.Constraints
+}
+
+// checkPlatformGroups checks that, for each platform group, exactly one file of the group builds for each platform.
+// The files excluded from the builds of the package are part of the groups too, as the files of the other platforms
+// are among them. Gaps and overlaps are reported at the package clause of the first file of the package, like the
+// unused patterns, as they are about a group of files rather than a single one.
+func (c *checker) checkPlatformGroups() {
+	if len(c.pass.Files) == 0 {
+		return
+	}
+	groups := c.platformGroups()
+	patterns := append([]string(nil), c.rules.rules.groups...)
+	sort.Strings(patterns)
+	for _, pattern := range patterns {
+		captured := make([]string, 0, len(groups[pattern]))
+		for values := range groups[pattern] {
+			captured = append(captured, values)
+		}
+		sort.Strings(captured)
+		for _, values := range captured {
+			c.checkPlatformGroup(pattern, values, groups[pattern][values])
+		}
+	}
+}
+
+// platformGroups returns the files of each platform group, sorted by name. The files of the groups whose pattern
+// captures groups are split by the values they capture, quoted, the files of the other groups being found at the
+// empty values.
+func (c *checker) platformGroups() map[string]map[string][]platformFile {
+	groups := make(map[string]map[string][]platformFile)
+	add := func(f *ast.File, constraints internal.Constraints) {
+		if !hasPackageClause(f) {
+			return
+		}
+		file := file{
+			name:    getFilename(c.pass, f),
+			path:    getPath(c.pass, f),
+			pkg:     f.Name.Name,
+			lines:   c.pass.Fset.Position(f.FileEnd).Line,
+			dirname: getDirname(c.pass, f),
+			tags:    constraints.Tags(),
+			imports: importPaths(f),
+		}
+		if c.rules.excluded(file) {
+			return
+		}
+		for pattern, match := range c.rules.groups {
+			if !match(file) {
+				continue
+			}
+			var values string
+			if capture, ok := c.rules.rules.groupCaptures[pattern]; ok {
+				values = quoteTags(capture.values(file, c.opts.caseInsensitive))
+			}
+			if groups[pattern] == nil {
+				groups[pattern] = make(map[string][]platformFile)
+			}
+			groups[pattern][values] = append(groups[pattern][values], platformFile{
+				name:        file.name,
+				shown:       formatPath(c.pass, f, file.path, c.opts.pathFormat),
+				constraints: constraints,
+			})
+		}
+	}
+	for _, f := range c.pass.Files {
+		constraints, _ := internal.ParseGoFile(f)
+		add(f, constraints)
+	}
+	for _, filename := range c.pass.IgnoredFiles {
+		if filepath.Ext(filename) != ".go" {
+			continue
+		}
+		content, err := c.pass.ReadFile(filename)
+		if err != nil {
+			c.fail(fmt.Errorf("cannot read file: %w", err))
+			return nil
+		}
+		f, err := parser.ParseFile(c.pass.Fset, filename, content, parser.ImportsOnly|parser.ParseComments)
+		if err != nil {
+			// The files whose header is partially written are skipped, as their build constraints are unreliable.
+			continue
+		}
+		// The lines after the imports are not scanned, while the line count patterns need all of them.
+		c.pass.Fset.File(f.Pos()).SetLinesForContent(content)
+		constraints, _ := internal.ParseGoFile(f)
+		add(f, constraints)
+	}
+	for _, split := range groups {
+		for _, files := range split {
+			sort.Slice(files, func(i, j int) bool { return files[i].name < files[j].name })
+		}
+	}
+	return groups
+}
+
+// checkPlatformGroup reports the platforms none of the files of the group builds for, and the files building for the
+// same platforms. The groups split by the values captured by their pattern are named after them.
+func (c *checker) checkPlatformGroup(pattern, values string, files []platformFile) {
+	group := fmt.Sprintf(`"%s"`, pattern)
+	if values != "" {
+		group += " (" + values + ")"
+	}
+	var gaps []string
+	var overlaps []string
+	overlapping := make(map[string][]string)
+	for _, platform := range c.opts.platforms {
+		ctxt := platformContext(platform)
+		var building []string
+		for _, file := range files {
+			if internal.MatchContext(ctxt, file.name, file.constraints) {
+				building = append(building, file.shown)
+			}
+		}
+		switch {
+		case len(building) == 0:
+			gaps = append(gaps, platform)
+		case len(building) > 1:
+			key := quoteTags(building)
+			if _, ok := overlapping[key]; !ok {
+				overlaps = append(overlaps, key)
+			}
+			overlapping[key] = append(overlapping[key], platform)
+		}
+	}
+	if len(gaps) > 0 {
+		c.reportPlatformGroup(pattern, KindPlatformGap,
+			fmt.Sprintf(`no file of the platform group %s builds for %s`, group, strings.Join(gaps, ", ")))
+	}
+	for _, key := range overlaps {
+		c.reportPlatformGroup(pattern, KindPlatformOverlap, fmt.Sprintf(
+			`files %s of the platform group %s all build for %s`, key, group, strings.Join(overlapping[key], ", ")))
+	}
+}
+
+// reportPlatformGroup reports a violation of the platform group at the package clause of the first file of the
+// package, and records it.
+func (c *checker) reportPlatformGroup(pattern string, kind Kind, message string) {
+	v := Violation{
+		Kind:     kind,
+		Severity: c.rules.rules.implicitSeverity(),
+		Pos:      c.pass.Files[0].Package,
+		Message:  message,
+		Patterns: []string{pattern},
+	}
+	v.Message = c.prefixed(v)
+	c.pass.Report(analysis.Diagnostic{Pos: v.Pos, Category: category(v), Message: v.Message})
+	c.result.add(v)
+}
+
+// platformContext returns the build context of a platform of the form "goos" or "goos/goarch". Apart from the
+// release tags of the Go version, no other tag is satisfied, such as "cgo" or the tags of another GOARCH when the
+// platform has none.
+func platformContext(platform string) *build.Context {
+	goos, goarch, _ := strings.Cut(platform, "/")
+	return &build.Context{GOOS: goos, GOARCH: goarch, Compiler: "gc", ReleaseTags: build.Default.ReleaseTags}
+}
+
+// validatePlatform returns an error if the platform is not of the form "goos" or "goos/goarch".
+func validatePlatform(platform string) error {
+	goos, goarch, hasArch := strings.Cut(platform, "/")
+	if goos == "" || (hasArch && (goarch == "" || strings.Contains(goarch, "/"))) {
+		return fmt.Errorf(`malformed platform "%s", must be of the form "goos" or "goos/goarch"`, platform)
+	}
+	return nil
+}
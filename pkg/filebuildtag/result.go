@@ -0,0 +1,159 @@
+package filebuildtag
+
+import "go/token"
+
+// Result is the result of the analyzer for a package, which dependent analyzers can access using pass.ResultOf and
+// type-assert to a *Result. Its fields are part of the API of the linter, hence they are kept stable.
+type Result struct {
+	// Checks are the rules checked on each file of the package, whether they passed or not, in the order they were
+	// checked.
+	Checks []Check
+	// Violations are the rules violated by the files of the package, in the order they were reported.
+	Violations []Violation
+	// TagViolations is the number of violations of each tag, such as the number of files missing it, so that
+	// the results of several packages can be aggregated. The tags of tag groups and expressions are counted as is,
+	// such as "oneof(dev,prod)", and the aggregated missing tags one by one.
+	TagViolations map[string]int
+	// FilesChecked is the number of files of the package checked against the rules, Go and non-Go ones. The excluded,
+	// ignored and generated files are left out, like the files skipped by the build-context flag. It helps tell a
+	// package whose files all pass the rules from one whose files are all skipped, such as by a misconfiguration.
+	FilesChecked int
+	// FilesMatched is the number of checked files matching at least one pattern of the filetags.
+	FilesMatched int
+
+	// files are the positions of the checked files, and whether they match a pattern, to filter the counts.
+	files map[token.Pos]bool
+}
+
+// addFile records the file checked at the position, and whether it matches a pattern of the filetags.
+func (r *Result) addFile(pos token.Pos, matched bool) {
+	if r.files == nil {
+		r.files = make(map[token.Pos]bool)
+	}
+	r.files[pos] = matched
+	r.FilesChecked++
+	if matched {
+		r.FilesMatched++
+	}
+}
+
+// add records the violation. The aggregated missing tags are counted one by one.
+func (r *Result) add(v Violation) {
+	r.Violations = append(r.Violations, v)
+	if len(v.Tags) > 0 {
+		for _, tag := range v.Tags {
+			r.TagViolations[tag]++
+		}
+		return
+	}
+	if v.Tag != "" {
+		r.TagViolations[v.Tag]++
+	}
+}
+
+// Check is a tag of a rule checked on a file, such as "*_test.go" expecting "unit", which editors can display whether
+// it passed or not. The violations of the check, if any, are listed among the violations of the result.
+type Check struct {
+	// Pos is the position of the build constraints of the file, or of its package clause when it has none, like
+	// violations.
+	Pos token.Pos
+	// File is the name of the file, as recorded in the file set of the pass.
+	File string
+	// Pattern is the pattern of the rule, or "(default)" for the default tags.
+	Pattern string
+	// Tag is the tag of the rule, as written in the filetags: an expected tag, a forbidden tag such as "!unit", a tag
+	// group such as "oneof(dev,prod)" or the canonical form of an expression.
+	Tag string
+	// Severity is the severity of the violations of the rule.
+	Severity Severity
+	// Found are the tags found in the file.
+	Found []string
+	// Passed is whether the file satisfies the rule.
+	Passed bool
+}
+
+// Kind is the kind of a violation.
+type Kind string
+
+const (
+	// KindMissingTag is the kind of the violations of files missing an expected tag, or the tags of a tag group.
+	KindMissingTag Kind = "missing-tag"
+	// KindMissingConstraint is the kind of the violations of files having no build constraint at all while matching
+	// the patterns of the require-constraint flag.
+	KindMissingConstraint Kind = "missing-constraint"
+	// KindForbiddenTag is the kind of the violations of files having a forbidden tag.
+	KindForbiddenTag Kind = "forbidden-tag"
+	// KindConflictingTags is the kind of the violations of files having several tags of a "oneof(tag1,tag2)" group.
+	KindConflictingTags Kind = "conflicting-tags"
+	// KindUnexpectedTag is the kind of the violations of files having a tag while not matching the patterns
+	// expecting it, reported by the reverse check.
+	KindUnexpectedTag Kind = "unexpected-tag"
+	// KindConstraintMismatch is the kind of the violations of files whose build constraints do not match the
+	// expected expression.
+	KindConstraintMismatch Kind = "constraint-mismatch"
+	// KindMismatchedPlusBuild is the kind of the violations of files whose "//go:build" and "// +build" constraints
+	// are not equivalent.
+	KindMismatchedPlusBuild Kind = "mismatched-plus-build"
+	// KindMisorderedConstraints is the kind of the violations of files whose build constraint lines are not in the
+	// canonical order, the "//go:build" line directly followed by the "// +build" lines.
+	KindMisorderedConstraints Kind = "misordered-constraints"
+	// KindUnsortedOperands is the kind of the violations of files whose build constraints have operands which are not
+	// in lexical order, reported by the sorted-operands flag.
+	KindUnsortedOperands Kind = "unsorted-operands"
+	// KindConstraintStyle is the kind of the violations of files whose build constraint lines are not of the style
+	// required by the constraint-style flag.
+	KindConstraintStyle Kind = "constraint-style"
+	// KindRedundantConstraint is the kind of the violations of files whose build constraints duplicate the constraint
+	// implied by their name.
+	KindRedundantConstraint Kind = "redundant-constraint"
+	// KindInvalidCapture is the kind of the violations of files for which a tag template expands to an empty or
+	// invalid build tag, such as "foo-bar".
+	KindInvalidCapture Kind = "invalid-capture"
+	// KindMissingRationale is the kind of the violations of files matching a pattern whose build constraint lines
+	// have no adjoining comment explaining them.
+	KindMissingRationale Kind = "missing-rationale"
+	// KindDuplicateTag is the kind of the violations of files whose build constraints reference a tag more than once.
+	KindDuplicateTag Kind = "duplicate-tag"
+	// KindConflictingRules is the kind of the violations of files matching rules which both expect and forbid a tag.
+	KindConflictingRules Kind = "conflicting-rules"
+	// KindUnusedPattern is the kind of the violations of patterns matching no file of the package.
+	KindUnusedPattern Kind = "unused-pattern"
+	// KindPlatformGap is the kind of the violations of platform groups having no file building for some of the
+	// platforms of the platforms flag.
+	KindPlatformGap Kind = "platform-gap"
+	// KindPlatformOverlap is the kind of the violations of platform groups having several files building for the
+	// same platforms.
+	KindPlatformOverlap Kind = "platform-overlap"
+)
+
+// Severity is the severity of a violation.
+type Severity string
+
+const (
+	// SeverityError is the default severity, of the violations which must fail the builds.
+	SeverityError Severity = "error"
+	// SeverityWarning is the severity of the violations of the tags given the "@warning" suffix, such as
+	// "*_test.go:unit@warning", which should not fail the builds.
+	SeverityWarning Severity = "warning"
+)
+
+// Violation is a rule violated by a file of the package, reported as a diagnostic.
+type Violation struct {
+	// Kind is the kind of the violation.
+	Kind Kind
+	// Severity is the severity of the violation.
+	Severity Severity
+	// Pos is the position the diagnostic is reported at.
+	Pos token.Pos
+	// Message is the message of the diagnostic.
+	Message string
+	// Patterns are the patterns of the violated rules.
+	Patterns []string
+	// Tag is the tag the violation is about, or the expected expression for constraint mismatches. When several
+	// missing tags are aggregated by the aggregate-missing flag, it is of the form "tag1+tag2".
+	Tag string
+	// Tags are the missing tags aggregated by the aggregate-missing flag, if any.
+	Tags []string
+	// Found are the tags found in the file.
+	Found []string
+}
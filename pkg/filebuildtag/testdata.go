@@ -0,0 +1,102 @@
+package filebuildtag
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// testdataDir is the name of the directories the Go toolchain ignores, holding the data of the tests.
+const testdataDir = "testdata"
+
+// analyzerTestdata are the testdata directories already checked by the Analyzer, the analyzers of NewAnalyzer having
+// their own.
+var analyzerTestdata = &testdataCache{}
+
+// testdataCache records the testdata directories already checked by an analyzer, so that the variants of a package
+// loaded together, such as the package and its test variant, do not check them twice. The variants share the file
+// set of the run loading them, so only the directories of the latest file set are kept, the ones of the previous
+// runs being dropped rather than kept alive for as long as the process runs, such as in the long-running drivers.
+type testdataCache struct {
+	mu   sync.Mutex
+	fset *token.FileSet
+	dirs map[string]bool
+}
+
+// claim reports whether the testdata directory was not checked yet for the file set, recording it as checked.
+func (t *testdataCache) claim(fset *token.FileSet, dir string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.fset != fset {
+		t.fset, t.dirs = fset, make(map[string]bool)
+	}
+	if t.dirs[dir] {
+		return false
+	}
+	t.dirs[dir] = true
+	return true
+}
+
+// checkTestdata checks the Go files of the testdata directory of the package and of its subdirectories, for the
+// include-testdata flag. Such files are not part of any package, so they are checked as files of the package whose
+// directory holds them, after the ones of the package. Like the go command, the directories and files starting with
+// "." or "_" are skipped. The files are only parsed up to their imports, like by the scan command, the files not
+// parsing being skipped, as they are often samples of invalid code.
+func (c *checker) checkTestdata() {
+	if len(c.pass.Files) == 0 {
+		return
+	}
+	root := filepath.Join(filepath.Dir(c.pass.Fset.Position(c.pass.Files[0].Pos()).Filename), testdataDir)
+	if !c.opts.checkedTestdata.claim(c.pass.Fset, root) {
+		return
+	}
+	err := filepath.WalkDir(root, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if name == root && os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		base := d.Name()
+		if d.IsDir() {
+			if name != root && (strings.HasPrefix(base, ".") || strings.HasPrefix(base, "_")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if filepath.Ext(base) != ".go" || strings.HasPrefix(base, ".") || strings.HasPrefix(base, "_") {
+			return nil
+		}
+		return c.checkTestdataFile(root, name)
+	})
+	if err != nil {
+		c.fail(fmt.Errorf("cannot check the testdata directory: %w", err))
+	}
+}
+
+// checkTestdataFile checks a Go file of the testdata directory, whose path relative to the root of the module is
+// found under the directory of the package.
+func (c *checker) checkTestdataFile(root, filename string) error {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	f, err := parser.ParseFile(c.pass.Fset, filename, content, parser.ImportsOnly|parser.ParseComments)
+	if err != nil {
+		return nil
+	}
+	// The lines after the imports are not scanned, while the line count patterns need all of them.
+	c.pass.Fset.File(f.Pos()).SetLinesForContent(content)
+	rel, err := filepath.Rel(root, filename)
+	if err != nil {
+		return err
+	}
+	c.checkFileAt(f, path.Join(pkgDir(c.pass), testdataDir, filepath.ToSlash(rel)))
+	return nil
+}
@@ -0,0 +1 @@
+package filebuildtag_aggregate // want `missing expected build tags: "tag1", "tag2" required by pattern "\*_suff.go" \(file has no build tags\)`
@@ -0,0 +1,4 @@
+// want +1 `missing expected build tag: "tag2" required by pattern "\*_suff.go"`
+//go:build tag1 || !testfix
+
+package filebuildtag_aggregate
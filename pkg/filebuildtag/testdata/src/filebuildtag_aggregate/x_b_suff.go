@@ -0,0 +1,4 @@
+// want +1 `missing expected build tags: "tag1", "tag3" required by patterns "\*_suff.go", "\*_b_suff.go" \(file has: \[tag2\]\)`
+//go:build tag2 || !testfix
+
+package filebuildtag_aggregate
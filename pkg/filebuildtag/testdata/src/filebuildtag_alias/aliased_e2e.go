@@ -0,0 +1,3 @@
+//go:build end_to_end || !testfix
+
+package filebuildtag_alias
@@ -0,0 +1,4 @@
+// want +1 `missing expected build tag: "e2e" required by pattern "\*_e2e.go"`
+//go:build endtoend || !testfix
+
+package filebuildtag_alias
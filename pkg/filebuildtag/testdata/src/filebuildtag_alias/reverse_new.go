@@ -0,0 +1,4 @@
+// want +1 `missing expected build tag: "end_to_end" required by pattern "\*_new.go"`
+//go:build e2e || !testfix
+
+package filebuildtag_alias
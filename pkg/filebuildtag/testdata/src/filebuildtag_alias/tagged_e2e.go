@@ -0,0 +1,3 @@
+//go:build e2e || !testfix
+
+package filebuildtag_alias
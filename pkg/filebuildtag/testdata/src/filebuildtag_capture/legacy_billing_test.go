@@ -0,0 +1,4 @@
+// want +1 `^forbidden build tag: "billing"$`
+//go:build billing || !testfix
+
+package filebuildtag_capture
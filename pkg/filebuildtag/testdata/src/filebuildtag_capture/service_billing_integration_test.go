@@ -0,0 +1,3 @@
+//go:build (integration && billing) || !testfix
+
+package filebuildtag_capture
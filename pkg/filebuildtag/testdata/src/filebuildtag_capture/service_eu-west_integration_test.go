@@ -0,0 +1,4 @@
+// want +1 `^build tag "\$\{1\}" of pattern "re:service_\(\.\*\)_integration_test\\\.go" captures the invalid build tag "eu-west"$`
+//go:build integration || !testfix
+
+package filebuildtag_capture
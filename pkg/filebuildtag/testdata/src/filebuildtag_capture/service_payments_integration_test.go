@@ -0,0 +1,4 @@
+// want +1 `^missing expected build tag: "payments" required by pattern "re:service_\(\.\*\)_integration_test\\\.go" \(file has: \[integration\]\)$`
+//go:build integration || !testfix
+
+package filebuildtag_capture
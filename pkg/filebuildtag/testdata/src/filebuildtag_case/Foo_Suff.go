@@ -0,0 +1,3 @@
+//go:build TAG1 || !testfix
+
+package filebuildtag_case
@@ -0,0 +1 @@
+package filebuildtag_case // want `missing expected build tag: "tag1"`
@@ -0,0 +1,4 @@
+// want +1 `missing expected build tag: "ÉTÉ"`
+//go:build tag1 || !testfix
+
+package filebuildtag_case
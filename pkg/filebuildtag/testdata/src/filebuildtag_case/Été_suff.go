@@ -0,0 +1,3 @@
+//go:build (Été && tag1) || !testfix
+
+package filebuildtag_case
@@ -0,0 +1,4 @@
+// want +1 `missing expected build tag: "slow" required by pattern "tag:integration" \(file has: \[integration\]\), as the file has the build tag "integration"`
+//go:build integration || !testfix
+
+package filebuildtag_condition
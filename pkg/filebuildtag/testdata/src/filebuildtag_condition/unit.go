@@ -0,0 +1,4 @@
+// want +1 `forbidden build tag: "unit", as the file has the build tag "integration"`
+//go:build (integration && slow && unit) || !testfix
+
+package filebuildtag_condition
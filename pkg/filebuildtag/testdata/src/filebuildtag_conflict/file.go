@@ -0,0 +1,3 @@
+//go:build cgo || !testfix
+
+package filebuildtag_conflict
@@ -0,0 +1 @@
+package filebuildtag_conflict // want `^conflicting rules: "\*.go" expects the build tag "cgo" while "\*_nocgo.go" forbids it$` `missing expected build tag: "tag1"`
@@ -0,0 +1 @@
+package filebuildtag_constrained // want `missing build constraint: a build constraint is required by "\*_secure.go", whatever its tags`
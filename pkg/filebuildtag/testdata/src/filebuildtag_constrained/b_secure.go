@@ -0,0 +1,3 @@
+//go:build !windows || !testfix
+
+package filebuildtag_constrained
@@ -0,0 +1 @@
+package filebuildtag_constraint_only // want `missing expected build tag: "integration" required by pattern "\*_gate.go" \(file has no build tags\)`
\ No newline at end of file
@@ -0,0 +1,4 @@
+// want +1 `missing expected build tag: "integration" required by pattern "\*_gate.go" \(file has: \[linux\]\)`
+//go:build linux || !testfix
+
+package filebuildtag_constraint_only
\ No newline at end of file
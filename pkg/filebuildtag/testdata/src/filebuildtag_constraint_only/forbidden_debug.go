@@ -0,0 +1,4 @@
+// want +1 `forbidden build tag: "debug"`
+//go:build debug || !testfix
+
+package filebuildtag_constraint_only
\ No newline at end of file
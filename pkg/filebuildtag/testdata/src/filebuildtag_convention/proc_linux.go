@@ -0,0 +1,2 @@
+// want +1 `^missing expected build tag: "linux" required by pattern "re:\[\^\.\]\*_linux\(_\(386\|amd64\|.*\)\)\?\(_test\)\?\(\\\.\.\*\)\?\\\.go" \(file has no build tags\)$`
+package filebuildtag_convention
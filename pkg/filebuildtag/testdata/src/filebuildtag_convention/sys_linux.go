@@ -0,0 +1,3 @@
+//go:build linux
+
+package filebuildtag_convention
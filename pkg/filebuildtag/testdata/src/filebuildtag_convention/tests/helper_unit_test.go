@@ -0,0 +1,2 @@
+// want +1 `^missing expected build tag: "unit" required by pattern "\*_unit_test.go" \(file has no build tags\)$`
+package tests
@@ -0,0 +1,2 @@
+// want +1 `^missing expected build tag: "integration" required by pattern "\*_integration_test.go" \(file has no build tags\)$`
+package tests
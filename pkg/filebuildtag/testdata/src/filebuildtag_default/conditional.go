@@ -0,0 +1,4 @@
+// want +1 `missing expected build tag: "slow" required by pattern "tag:integration"`
+//go:build (integration && internal) || !testfix
+
+package filebuildtag_default
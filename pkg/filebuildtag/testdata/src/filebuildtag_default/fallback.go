@@ -0,0 +1 @@
+package filebuildtag_default // want `^missing expected build tag: "internal" required by pattern "\(default\)" \(file has no build tags\)$`
@@ -0,0 +1,3 @@
+//go:build internal || !testfix
+
+package filebuildtag_default
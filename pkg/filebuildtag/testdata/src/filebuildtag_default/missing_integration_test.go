@@ -0,0 +1,4 @@
+// want +1 `missing expected build tag: "integration" required by pattern "\*_integration_test.go"`
+//go:build slow || !testfix
+
+package filebuildtag_default
@@ -0,0 +1,3 @@
+// Assembly files are not Go files, hence the default tag does not apply.
+
+#include "textflag.h"
@@ -0,0 +1,3 @@
+//go:build (integration && slow) || !testfix
+
+package filebuildtag_default
@@ -0,0 +1 @@
+package nested // want `missing expected build tag: "legacy" required by pattern "dir:filebuildtag_dir/legacy"`
@@ -0,0 +1,4 @@
+// want +1 `missing expected build tag: "legacy" required by pattern "dir:filebuildtag_dir/legacy"`
+//go:build tag1 || !testfix
+
+package legacy
@@ -0,0 +1,4 @@
+// want +1 `missing expected build tag: "tag1"`
+//go:build tag2 || !testfix
+
+package filebuildtag_dirconfig
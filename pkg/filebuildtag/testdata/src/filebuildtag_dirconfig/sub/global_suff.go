@@ -0,0 +1,4 @@
+// want +1 `missing expected build tag: "tag2"`
+//go:build tag1 || !testfix
+
+package sub
@@ -0,0 +1 @@
+package sub // want `missing expected build tag: "other"` `missing expected build tag: "tagged"`
@@ -0,0 +1,4 @@
+// want +1 `missing expected build tag: "slow" required by pattern "\*_slow.go"`
+//go:build integration || !testfix
+
+package db_integration
@@ -0,0 +1,3 @@
+//go:build integration || !testfix
+
+package db_integration
@@ -0,0 +1 @@
+package db_integration // want `missing expected build tag: "integration" required by pattern "dirname:\*_integration"`
@@ -0,0 +1,5 @@
+// want +1 `^duplicate build tag "testfix": it is referenced more than once by the build constraints$`
+// +build !testfix
+// +build !testfix
+
+package filebuildtag_duplicate
@@ -0,0 +1,4 @@
+// want +1 `^duplicate build tag "testfix": it is referenced more than once by the build constraints$`
+//go:build !testfix && (linux || !testfix)
+
+package filebuildtag_duplicate
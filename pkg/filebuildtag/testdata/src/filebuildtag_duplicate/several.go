@@ -0,0 +1,4 @@
+// want +1 `^duplicate build tag "cgo": it is referenced more than once by the build constraints$` `^duplicate build tag "testfix": it is referenced more than once by the build constraints$`
+//go:build (cgo || !testfix) && (!testfix || !cgo)
+
+package filebuildtag_duplicate
@@ -0,0 +1,4 @@
+//go:build linux || !testfix
+// +build linux !testfix
+
+package filebuildtag_duplicate
@@ -0,0 +1,2 @@
+// +buildfoo
+package filebuildtag_exclude
@@ -0,0 +1 @@
+package filebuildtag_exclude // want `missing expected build tag: "tag1"`
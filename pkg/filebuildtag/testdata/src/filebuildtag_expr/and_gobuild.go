@@ -0,0 +1,3 @@
+//go:build (linux && amd64) || !testfix
+
+package filebuildtag_expr
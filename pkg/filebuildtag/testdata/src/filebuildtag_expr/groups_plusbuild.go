@@ -0,0 +1,3 @@
+// +build linux,386 darwin,!cgo !testfix
+
+package filebuildtag_expr
@@ -0,0 +1,4 @@
+// +build linux !testfix
+// +build amd64 !testfix
+
+package filebuildtag_expr
@@ -0,0 +1,4 @@
+// want +1 `missing expected build tag: "linux"`
+//go:build !(linux && amd64) || !testfix
+
+package filebuildtag_expr
@@ -0,0 +1,4 @@
+// want +1 `missing expected build tag: "linux"`
+// +build darwin,!linux freebsd,!linux !testfix
+
+package filebuildtag_expr
@@ -0,0 +1,4 @@
+// want +1 `missing expected build tag: "linux"`
+// +build !linux,amd64 !testfix
+
+package filebuildtag_expr
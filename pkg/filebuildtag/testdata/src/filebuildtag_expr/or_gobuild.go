@@ -0,0 +1,3 @@
+//go:build darwin || linux || !testfix
+
+package filebuildtag_expr
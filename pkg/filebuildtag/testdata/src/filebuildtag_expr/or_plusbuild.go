@@ -0,0 +1,3 @@
+// +build darwin linux !testfix
+
+package filebuildtag_expr
@@ -0,0 +1,4 @@
+// want +1 `missing expected build tag: "linux"`
+// +build linuxamd64 !testfix
+
+package filebuildtag_expr
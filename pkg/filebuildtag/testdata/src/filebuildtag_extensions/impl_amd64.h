@@ -0,0 +1,3 @@
+//go:build amd64 || !testfix
+
+#define IMPL 1
@@ -0,0 +1,4 @@
+//go:build amd64 || !testfix
+
+TEXT ·impl(SB),0,$0
+	RET
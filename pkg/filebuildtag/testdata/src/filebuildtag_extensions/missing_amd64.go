@@ -0,0 +1 @@
+package filebuildtag_extensions // want `^missing expected build tag: "amd64" required by pattern "\*_amd64\.\*" \(file has no build tags\)$`
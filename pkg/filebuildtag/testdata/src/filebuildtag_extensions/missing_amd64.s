@@ -0,0 +1,4 @@
+// want `^missing expected build tag: "amd64" required by pattern "\*_amd64\.\*" \(file has no build tags\)$`
+
+TEXT ·missing(SB),0,$0
+	RET
@@ -0,0 +1,4 @@
+// want +1 `^missing expected build tag: "tag1" required by pattern "\*_suff.go"` `^forbidden build tag: "tag5"$`
+//go:build tag5 || !testfix
+
+package filebuildtag_first
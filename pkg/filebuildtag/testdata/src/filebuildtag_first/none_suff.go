@@ -0,0 +1 @@
+package filebuildtag_first // want `^missing expected build tag: "tag1" required by pattern "\*_suff.go"`
@@ -0,0 +1,2 @@
+// Package filebuildtag_fix tests the suggested fixes.
+package filebuildtag_fix // want `missing expected build tag: "tag1"`
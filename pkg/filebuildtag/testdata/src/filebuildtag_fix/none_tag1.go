@@ -0,0 +1 @@
+package filebuildtag_fix // want `missing expected build tag: "tag1"`
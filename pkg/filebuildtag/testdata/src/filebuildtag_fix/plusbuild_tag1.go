@@ -0,0 +1,7 @@
+// Copyright notice.
+
+// want +1 `missing expected build tag: "tag1"`
+// +build tag2 !testfix
+// +build tag3 !testfix
+
+package filebuildtag_fix
@@ -0,0 +1 @@
+package filebuildtag_fix_expr
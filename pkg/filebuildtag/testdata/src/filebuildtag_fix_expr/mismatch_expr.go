@@ -0,0 +1,4 @@
+// want +1 `build constraint "!nope" does not match the expected "!nope && !testfix"`
+//go:build !nope
+
+package filebuildtag_fix_expr
@@ -0,0 +1 @@
+package filebuildtag_fix_expr // want `missing expected build constraint: "!nope && !testfix"`
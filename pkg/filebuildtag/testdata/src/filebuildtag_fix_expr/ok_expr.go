@@ -0,0 +1,3 @@
+//go:build !testfix && !nope
+
+package filebuildtag_fix_expr
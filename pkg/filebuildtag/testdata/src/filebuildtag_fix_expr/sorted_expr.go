@@ -0,0 +1,4 @@
+//go:build !nope && (!testfix && !nope)
+// +build !nope,!testfix
+
+package filebuildtag_fix_expr
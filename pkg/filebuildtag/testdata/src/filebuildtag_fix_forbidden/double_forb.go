@@ -0,0 +1,4 @@
+// want +1 `forbidden build tag: "forb"`
+//go:build !(!forb && tag1) || !testfix
+
+package filebuildtag_fix_forbidden
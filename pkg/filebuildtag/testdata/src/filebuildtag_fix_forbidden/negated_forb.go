@@ -0,0 +1,3 @@
+//go:build !forb || !testfix
+
+package filebuildtag_fix_forbidden
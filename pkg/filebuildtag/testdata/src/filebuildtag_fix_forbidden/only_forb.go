@@ -0,0 +1,5 @@
+// want +1 `forbidden build tag: "forb"`
+//go:build forb
+// +build forb
+
+package filebuildtag_fix_forbidden
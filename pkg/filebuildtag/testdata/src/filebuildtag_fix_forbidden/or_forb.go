@@ -0,0 +1,5 @@
+// want +1 `forbidden build tag: "forb"`
+//go:build forb || !testfix
+// +build forb !testfix
+
+package filebuildtag_fix_forbidden
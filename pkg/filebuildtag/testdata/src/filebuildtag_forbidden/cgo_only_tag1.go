@@ -0,0 +1,4 @@
+// want +1 `missing expected build tag: "tag1"` `forbidden build tag: "cgo"`
+//go:build cgo || !testfix
+
+package filebuildtag_forbidden
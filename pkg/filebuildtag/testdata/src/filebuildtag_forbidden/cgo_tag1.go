@@ -0,0 +1,4 @@
+// want +1 `forbidden build tag: "cgo"`
+//go:build (tag1 && cgo) || !testfix
+
+package filebuildtag_forbidden
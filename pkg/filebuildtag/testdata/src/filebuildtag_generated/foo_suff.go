@@ -0,0 +1 @@
+package filebuildtag_generated // want `missing expected build tag: "tag1"`
@@ -0,0 +1,3 @@
+//go:build tag1 || !testfix
+
+package filebuildtag_generated_tag
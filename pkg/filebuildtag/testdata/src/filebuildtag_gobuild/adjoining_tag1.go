@@ -0,0 +1,3 @@
+// want +1 `misplaced //go:build comment`
+//go:build tag1 || !testfix
+package filebuildtag_gobuild // want `missing expected build tag: "tag1"`
@@ -0,0 +1,7 @@
+//go:build tag1 || !testfix
+
+package filebuildtag_gobuild
+
+func _() {
+	//go:build tag2 // want `misplaced //go:build comment`
+}
@@ -0,0 +1,4 @@
+//go:build tag1 || !testfix
+// +build tag2 !testfix
+
+package filebuildtag_gobuild
@@ -0,0 +1,5 @@
+package filebuildtag_gobuild // want `missing expected build tag: "tag1"`
+
+//go:build tag1 || !testfix // want `misplaced //go:build comment`
+
+var _ = 1
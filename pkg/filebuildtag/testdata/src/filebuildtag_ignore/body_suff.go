@@ -0,0 +1,4 @@
+package filebuildtag_ignore // want `missing expected build tag: "tag1"`
+
+//filebuildtag:ignore
+var _ = 0
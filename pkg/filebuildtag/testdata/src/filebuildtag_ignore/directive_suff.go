@@ -0,0 +1,3 @@
+//filebuildtag:ignore legacy file, tagged in a later release
+
+package filebuildtag_ignore
@@ -0,0 +1 @@
+package filebuildtag_ignore // want `missing expected build tag: "tag1"`
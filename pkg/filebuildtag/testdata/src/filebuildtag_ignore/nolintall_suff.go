@@ -0,0 +1 @@
+package filebuildtag_ignore //nolint
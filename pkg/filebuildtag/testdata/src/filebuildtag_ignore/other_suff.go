@@ -0,0 +1 @@
+package filebuildtag_ignore //nolint:gofmt // want `missing expected build tag: "tag1"`
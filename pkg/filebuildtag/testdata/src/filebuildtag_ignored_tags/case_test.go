@@ -0,0 +1,4 @@
+// want +1 `missing expected build tag: "unit" required by pattern "\*_test.go" \(file has: \[Unit, LEGACY\]\)`
+//go:build Unit || LEGACY || !testfix
+
+package filebuildtag_ignored_tags
@@ -0,0 +1,4 @@
+// want +1 `missing expected build tag: "unit" required by pattern "\*_test.go" \(file has no build tags\)`
+//go:build legacy || !testfix
+
+package filebuildtag_ignored_tags
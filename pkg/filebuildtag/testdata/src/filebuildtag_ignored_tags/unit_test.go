@@ -0,0 +1,3 @@
+//go:build (unit && legacy) || !testfix
+
+package filebuildtag_ignored_tags
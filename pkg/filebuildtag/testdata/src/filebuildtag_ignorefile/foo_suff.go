@@ -0,0 +1 @@
+package filebuildtag_ignorefile // want `missing expected build tag: "tag1"`
@@ -0,0 +1 @@
+package sub // want `missing expected build tag: "tag1"`
@@ -0,0 +1,3 @@
+//go:build ignore || !testfix
+
+package filebuildtag_ignoretag
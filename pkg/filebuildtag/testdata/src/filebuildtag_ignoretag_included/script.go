@@ -0,0 +1,4 @@
+// want +1 `missing expected build tag: "tag1"`
+//go:build ignore || !testfix
+
+package filebuildtag_ignoretag_included
@@ -0,0 +1,9 @@
+// want +1 `missing expected build tag: "net" required by pattern "imports:net/\*\*"`
+//go:build db || !testfix
+
+package filebuildtag_imports
+
+import (
+	_ "database/sql"
+	_ "net/http/httptest"
+)
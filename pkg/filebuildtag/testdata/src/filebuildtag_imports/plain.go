@@ -0,0 +1,5 @@
+package filebuildtag_imports
+
+import "fmt"
+
+var _ = fmt.Sprint
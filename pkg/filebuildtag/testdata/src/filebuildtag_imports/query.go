@@ -0,0 +1,5 @@
+package filebuildtag_imports // want `missing expected build tag: "db" required by pattern "imports:database/sql"`
+
+import "database/sql"
+
+var _ = sql.ErrNoRows
@@ -0,0 +1,9 @@
+//go:build db || !testfix
+
+package filebuildtag_imports
+
+import (
+	dbsql "database/sql"
+)
+
+var _ = dbsql.ErrTxDone
@@ -0,0 +1,5 @@
+package filebuildtag_lines // want `missing expected build tag: "large" required by pattern "lines>3"`
+
+var _ = 0
+
+var _ = 1
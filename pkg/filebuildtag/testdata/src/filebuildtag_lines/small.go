@@ -0,0 +1,3 @@
+package filebuildtag_lines
+
+var _ = 0
@@ -0,0 +1,5 @@
+//go:build large || !testfix
+
+package filebuildtag_lines
+
+var _ = 2
@@ -0,0 +1,4 @@
+// want +1 `^malformed //go:build comment: there must be no space between "//" and "go:build"$`
+// go:build foo
+
+package filebuildtag_malformed
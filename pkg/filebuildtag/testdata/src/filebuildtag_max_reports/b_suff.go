@@ -0,0 +1 @@
+package filebuildtag_max_reports // want `^missing expected build tag: "tag1" required by pattern "\*_suff.go" \(file has no build tags\)$`
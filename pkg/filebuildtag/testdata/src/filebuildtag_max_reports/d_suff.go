@@ -0,0 +1 @@
+package filebuildtag_max_reports
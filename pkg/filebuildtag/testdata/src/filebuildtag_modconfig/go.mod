@@ -0,0 +1,3 @@
+module filebuildtag_modconfig
+
+go 1.22
@@ -0,0 +1,3 @@
+//go:build modboth || !testfix
+
+package filebuildtag_modconfig
@@ -0,0 +1,4 @@
+// want +1 `missing expected build tag: "modtag"`
+//go:build mod || !testfix
+
+package filebuildtag_modconfig
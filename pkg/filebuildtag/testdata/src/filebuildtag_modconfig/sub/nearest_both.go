@@ -0,0 +1,4 @@
+// want +1 `missing expected build tag: "subboth"`
+//go:build modboth || !testfix
+
+package sub
@@ -0,0 +1,3 @@
+//go:build (tag1 && tag2 && tag3) || !testfix
+
+package filebuildtag_multiple
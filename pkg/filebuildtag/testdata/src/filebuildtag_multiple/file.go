@@ -0,0 +1 @@
+package filebuildtag_multiple
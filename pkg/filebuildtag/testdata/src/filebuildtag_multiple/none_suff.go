@@ -0,0 +1 @@
+package filebuildtag_multiple // want `missing expected build tag: "tag1"` `missing expected build tag: "tag2"` `missing expected build tag: "tag3"`
@@ -0,0 +1,4 @@
+// want +1 `missing expected build tag: "tag1" required by pattern "\*_suff.go&!\*_generated_suff.go"`
+//go:build tag2 || !testfix
+
+package filebuildtag_negated
@@ -0,0 +1,3 @@
+//go:build tag2 || !testfix
+
+package filebuildtag_negated
@@ -0,0 +1,4 @@
+// want +1 `forbidden build tag: "windows"`
+//go:build !(!windows && testfix)
+
+package filebuildtag_negated_tags
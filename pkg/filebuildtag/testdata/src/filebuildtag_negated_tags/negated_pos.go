@@ -0,0 +1,4 @@
+// want +1 `missing expected build tag: "windows" required by pattern "\*_pos.go"`
+//go:build !windows || !testfix
+
+package filebuildtag_negated_tags
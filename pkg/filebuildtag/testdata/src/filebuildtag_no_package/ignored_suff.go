@@ -0,0 +1,5 @@
+//go:build ignore
+
+package filebuildtag_no_package
+
+import (
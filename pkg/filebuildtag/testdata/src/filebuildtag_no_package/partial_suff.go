@@ -0,0 +1 @@
+//go:build linux
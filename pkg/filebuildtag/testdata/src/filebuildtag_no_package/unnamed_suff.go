@@ -0,0 +1,3 @@
+//go:build tag1
+
+package
@@ -0,0 +1 @@
+package filebuildtag_no_package // want `^missing expected build tag: "tag1" required by pattern "\*" \(file has no build tags\)$`
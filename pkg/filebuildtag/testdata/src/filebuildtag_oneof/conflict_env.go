@@ -0,0 +1,4 @@
+// want +1 `^conflicting build tags "dev", "prod" of "oneof\(dev,staging,prod\)", only one is expected$`
+//go:build (dev && prod) || !testfix
+
+package filebuildtag_oneof
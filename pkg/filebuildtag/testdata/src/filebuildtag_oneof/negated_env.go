@@ -0,0 +1,3 @@
+//go:build (dev && !prod) || !testfix
+
+package filebuildtag_oneof
@@ -0,0 +1,4 @@
+// want +1 `^missing one of the expected build tags of "oneof\(dev,staging,prod\)" required by pattern "\*_env\.go" \(file has: \[other\]\)$`
+//go:build other || !testfix
+
+package filebuildtag_oneof
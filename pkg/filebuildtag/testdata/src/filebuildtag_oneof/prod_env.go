@@ -0,0 +1,3 @@
+//go:build prod || !testfix
+
+package filebuildtag_oneof
@@ -0,0 +1 @@
+package filebuildtag_only // want `missing expected build tag: "tag1" required by pattern "\*_suff.go"`
@@ -0,0 +1,5 @@
+// +build tag1 !testfix
+
+// +build tag2 !testfix
+
+package filebuildtag_order
@@ -0,0 +1,5 @@
+// want +1 `^//go:build comment must precede the // .build comments$`
+// +build tag1 !testfix
+//go:build tag1 || !testfix
+
+package filebuildtag_order
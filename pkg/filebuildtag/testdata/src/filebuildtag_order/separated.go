@@ -0,0 +1,6 @@
+// want +1 `^// .build comments must directly follow the //go:build comment$`
+//go:build tag1 || !testfix
+
+// +build tag1 !testfix
+
+package filebuildtag_order
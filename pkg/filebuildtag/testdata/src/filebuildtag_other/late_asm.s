@@ -0,0 +1,3 @@
+#include "textflag.h" // want `missing expected build tag: "amd64" required by pattern "\*_asm.s" \(file has no build tags\)`
+
+//go:build amd64
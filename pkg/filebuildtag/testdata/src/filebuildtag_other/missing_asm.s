@@ -0,0 +1,4 @@
+// want +1 `missing expected build tag: "amd64" required by pattern "\*_asm.s" \(file has: \[arm64\]\)`
+//go:build arm64 || !testfix
+
+#include "textflag.h"
@@ -0,0 +1,4 @@
+// want +1 `forbidden build tag: "cgo"`
+//go:build cgo || !testfix
+
+int answer();
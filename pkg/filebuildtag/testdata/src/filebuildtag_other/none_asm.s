@@ -0,0 +1,3 @@
+// Assembly without build constraints. // want `missing expected build tag: "amd64" required by pattern "\*_asm.s" \(file has no build tags\)`
+
+#include "textflag.h"
@@ -0,0 +1,4 @@
+// want +1 `forbidden build tag: "bar"`
+//go:build (foo && bar) || !testfix
+
+package filebuildtag_overlap
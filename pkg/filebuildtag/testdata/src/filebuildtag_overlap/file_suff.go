@@ -0,0 +1 @@
+package filebuildtag_overlap // want `missing expected build tag: "foo"`
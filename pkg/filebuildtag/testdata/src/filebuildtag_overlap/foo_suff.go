@@ -0,0 +1,3 @@
+//go:build foo || !testfix
+
+package filebuildtag_overlap
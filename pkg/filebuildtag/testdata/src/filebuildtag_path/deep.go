@@ -0,0 +1 @@
+package filebuildtag_path // want `missing expected build tag: "all"`
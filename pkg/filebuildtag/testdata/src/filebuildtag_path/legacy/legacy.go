@@ -0,0 +1,3 @@
+//go:build (legacy && all) || !testfix
+
+package legacy
@@ -0,0 +1,3 @@
+//go:build all || !testfix
+
+package nested
@@ -0,0 +1,4 @@
+// want +1 `missing expected build tag: "legacy"`
+//go:build all || !testfix
+
+package legacy
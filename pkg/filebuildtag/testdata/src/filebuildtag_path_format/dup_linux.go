@@ -0,0 +1,4 @@
+// want +1 `^build constraint "linux" is redundant with the file name "filebuildtag_path_format/dup_linux.go"$`
+//go:build linux
+
+package filebuildtag_path_format
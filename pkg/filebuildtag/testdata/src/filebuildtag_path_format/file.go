@@ -0,0 +1 @@
+package filebuildtag_path_format
@@ -0,0 +1 @@
+package integrationtest // want `missing expected build tag: "integration"` `missing expected build tag: "tag1"`
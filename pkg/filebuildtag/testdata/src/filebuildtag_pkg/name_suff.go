@@ -0,0 +1,4 @@
+// want +1 `missing expected build tag: "tag1"`
+//go:build integration || !testfix
+
+package integrationtest
@@ -0,0 +1 @@
+package filebuildtag_platform_split // want `no file of the platform group "re:\(\.\+\)_\.\+\\\.go" \("bar"\) builds for windows` `files "bar_linux.go", "bar_unix.go" of the platform group "re:\(\.\+\)_\.\+\\\.go" \("bar"\) all build for linux`
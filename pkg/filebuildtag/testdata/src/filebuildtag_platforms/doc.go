@@ -0,0 +1 @@
+package filebuildtag_platforms // want `no file of the platform group "proc_\*.go" builds for windows` `files "sys.go", "sys_windows.go" of the platform group "sys\*.go" all build for windows`
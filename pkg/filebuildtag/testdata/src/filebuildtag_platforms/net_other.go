@@ -0,0 +1,3 @@
+//go:build !unix
+
+package filebuildtag_platforms
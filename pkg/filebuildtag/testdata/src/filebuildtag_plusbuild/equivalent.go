@@ -0,0 +1,4 @@
+//go:build (tag2 && tag1) || !testfix
+// +build tag1,tag2 !testfix
+
+package filebuildtag_plusbuild
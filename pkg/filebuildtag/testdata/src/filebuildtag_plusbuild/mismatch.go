@@ -0,0 +1,5 @@
+// want +1 `^//go:build constraint "!testfix \|\| tag1" does not match the // .build constraint "tag2"$`
+//go:build tag1 || !testfix
+// +build tag2
+
+package filebuildtag_plusbuild
@@ -0,0 +1,3 @@
+// +build tag1
+
+package filebuildtag_plusbuild
@@ -0,0 +1,3 @@
+//go:build unit || !testfix
+
+package filebuildtag_qualifier_test
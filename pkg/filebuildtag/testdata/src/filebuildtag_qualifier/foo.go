@@ -0,0 +1 @@
+package filebuildtag_qualifier // want `missing expected build tag: "prod"`
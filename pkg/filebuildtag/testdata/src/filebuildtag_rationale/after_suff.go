@@ -0,0 +1,4 @@
+//go:build tag1 || !testfix
+// Requires docker.
+
+package filebuildtag_rationale
@@ -0,0 +1,6 @@
+// Copyright.
+// want +2 `^build constraint "tag1 \|\| !testfix" has no rationale comment adjoining it$`
+
+//go:build tag1 || !testfix
+
+package filebuildtag_rationale
@@ -0,0 +1,4 @@
+// Requires docker.
+//go:build tag1 || !testfix
+
+package filebuildtag_rationale
@@ -0,0 +1 @@
+package filebuildtag_rationale // want `^missing expected build tag: "tag1"`
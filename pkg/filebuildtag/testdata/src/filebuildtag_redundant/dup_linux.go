@@ -0,0 +1,4 @@
+// want +1 `^build constraint "linux" is redundant with the file name "dup_linux.go"$`
+//go:build linux
+
+package filebuildtag_redundant
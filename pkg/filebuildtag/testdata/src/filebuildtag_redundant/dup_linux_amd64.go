@@ -0,0 +1,5 @@
+// want +1 `^build constraint "amd64 && linux" is redundant with the file name "dup_linux_amd64.go"$`
+//go:build linux && amd64
+// +build linux,amd64
+
+package filebuildtag_redundant
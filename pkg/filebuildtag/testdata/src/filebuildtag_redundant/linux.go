@@ -0,0 +1,3 @@
+//go:build linux || !testfix
+
+package filebuildtag_redundant
@@ -0,0 +1,3 @@
+//go:build legacy || !testfix
+
+package filebuildtag_regex
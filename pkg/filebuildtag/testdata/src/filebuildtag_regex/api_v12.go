@@ -0,0 +1 @@
+package filebuildtag_regex // want `missing expected build tag: "legacy"`
@@ -0,0 +1,3 @@
+//go:build foobar || !testfix
+
+package filebuildtag_regex
@@ -0,0 +1 @@
+package filebuildtag_resolver // want `^missing expected build tag: "tag1" required by pattern "\*_suff.go" \(file has no build tags\)$`
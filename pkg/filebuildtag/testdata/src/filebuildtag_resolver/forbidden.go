@@ -0,0 +1,4 @@
+// want +1 `^forbidden build tag: "legacy"$`
+//go:build legacy || !testfix
+
+package filebuildtag_resolver
@@ -0,0 +1,3 @@
+//go:build manifest || !testfix
+
+package filebuildtag_resolver
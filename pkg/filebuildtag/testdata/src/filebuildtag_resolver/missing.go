@@ -0,0 +1 @@
+package filebuildtag_resolver // want `^missing expected build tag: "manifest" required by pattern "\(resolver\)" \(file has no build tags\)$`
@@ -0,0 +1,3 @@
+//go:build integration || !testfix
+
+package filebuildtag_reverse
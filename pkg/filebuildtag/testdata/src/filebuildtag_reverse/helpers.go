@@ -0,0 +1,4 @@
+// want +1 `unexpected build tag: "integration", only expected on files matching "\*_e2e_test.go", "\*_integration_test.go"`
+//go:build integration || !testfix
+
+package filebuildtag_reverse
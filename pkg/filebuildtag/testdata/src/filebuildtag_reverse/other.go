@@ -0,0 +1,3 @@
+//go:build other || !testfix
+
+package filebuildtag_reverse
@@ -0,0 +1,4 @@
+// want +1 `unexpected build tag: "unit", only expected on files matching "\*_unit_test.go"`
+//go:build unit || !testfix
+
+package filebuildtag_reverse
@@ -0,0 +1,4 @@
+// want +1 `^build constraint operands are not sorted: "\(linux && amd64\) \|\| !testfix" rather than "!testfix \|\| \(amd64 && linux\)"$`
+//go:build (linux && amd64) || !testfix
+
+package filebuildtag_sorted
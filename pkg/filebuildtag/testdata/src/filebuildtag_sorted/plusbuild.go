@@ -0,0 +1,5 @@
+// want +1 `^build constraint operands are not sorted: "linux \|\| !testfix" rather than "!testfix \|\| linux"$`
+//go:build linux || !testfix
+// +build linux !testfix
+
+package filebuildtag_sorted
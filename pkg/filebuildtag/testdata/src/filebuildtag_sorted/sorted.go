@@ -0,0 +1,3 @@
+//go:build !testfix || (amd64 && linux)
+
+package filebuildtag_sorted
@@ -0,0 +1,4 @@
+//go:build tag1 || !testfix
+// +build tag1 !testfix
+
+package filebuildtag_style_both
@@ -0,0 +1,4 @@
+// want +1 `missing // .build lines, required along with the //go:build line by the "both" constraint style`
+//go:build tag1 || !testfix
+
+package filebuildtag_style_both
@@ -0,0 +1,4 @@
+// want +1 `missing //go:build line, required along with the // .build lines by the "both" constraint style`
+// +build tag1 !testfix
+
+package filebuildtag_style_both
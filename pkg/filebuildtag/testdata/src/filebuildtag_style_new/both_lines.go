@@ -0,0 +1,5 @@
+// want +1 `.build lines are not allowed by the "new-only" constraint style, only the //go:build line is`
+//go:build tag1 || !testfix
+// +build tag1 !testfix
+
+package filebuildtag_style_new
@@ -0,0 +1,4 @@
+// want +1 `^missing any of the expected build tags of "anyof\(linux,darwin,windows\)" required by pattern "\*_platform\.go" \(file has: \[plan9\]\)$`
+//go:build plan9 || !testfix
+
+package filebuildtag_taggroup
@@ -0,0 +1,3 @@
+//go:build (foo && bar && baz) || !testfix
+
+package filebuildtag_taggroup
@@ -0,0 +1,3 @@
+//go:build darwin || !testfix
+
+package filebuildtag_taggroup
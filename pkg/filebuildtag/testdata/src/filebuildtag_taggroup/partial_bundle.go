@@ -0,0 +1,4 @@
+// want +1 `^missing expected build tags "bar", "baz" of "allof\(foo,bar,baz\)" required by pattern "\*_bundle\.go" \(file has: \[foo\]\)$`
+//go:build foo || !testfix
+
+package filebuildtag_taggroup
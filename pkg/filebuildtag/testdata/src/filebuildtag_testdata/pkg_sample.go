@@ -0,0 +1,3 @@
+//go:build sample || !testfix
+
+package filebuildtag_testdata
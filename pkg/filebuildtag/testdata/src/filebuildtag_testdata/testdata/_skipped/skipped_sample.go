@@ -0,0 +1 @@
+package _skipped
@@ -0,0 +1,3 @@
+//go:build sample
+
+package fixtures
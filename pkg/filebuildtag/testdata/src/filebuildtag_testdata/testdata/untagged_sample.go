@@ -0,0 +1,5 @@
+package fixtures
+
+import "fmt"
+
+func main() { fmt.Println( }
@@ -0,0 +1,4 @@
+// want +1 `^missing expected build tag: "integration" required by pattern "\*_integration_test\.go" \(file has: \[intgraton, integratio_\], did you mean "integratio_"\?\)$`
+//go:build intgraton || integratio_ || !testfix
+
+package filebuildtag_typo
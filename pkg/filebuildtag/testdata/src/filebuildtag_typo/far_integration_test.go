@@ -0,0 +1,4 @@
+// want +1 `^missing expected build tag: "integration" required by pattern "\*_integration_test\.go" \(file has: \[intgrtn\]\)$`
+//go:build intgrtn || !testfix
+
+package filebuildtag_typo
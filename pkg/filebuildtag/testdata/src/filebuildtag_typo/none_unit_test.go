@@ -0,0 +1 @@
+package filebuildtag_typo // want `^missing expected build tag: "unit" required by pattern "\*_unit_test\.go" \(file has no build tags\)$`
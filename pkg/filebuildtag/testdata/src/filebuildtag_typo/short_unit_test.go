@@ -0,0 +1,4 @@
+// want +1 `^missing expected build tag: "unit" required by pattern "\*_unit_test\.go" \(file has: \[unix\]\)$`
+//go:build unix || !testfix
+
+package filebuildtag_typo
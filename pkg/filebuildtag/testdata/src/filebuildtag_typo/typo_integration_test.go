@@ -0,0 +1,4 @@
+// want +1 `^missing expected build tag: "integration" required by pattern "\*_integration_test\.go" \(file has: \[slow, integraton\], did you mean "integraton"\?\)$`
+//go:build (slow && integraton) || !testfix
+
+package filebuildtag_typo
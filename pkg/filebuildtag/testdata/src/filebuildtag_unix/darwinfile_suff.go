@@ -0,0 +1,4 @@
+// want +1 `missing expected build tag: "linux" required by pattern "\*_suff.go"`
+//go:build darwin || !testfix
+
+package filebuildtag_unix
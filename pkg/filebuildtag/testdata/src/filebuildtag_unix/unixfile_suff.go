@@ -0,0 +1,3 @@
+//go:build unix || !testfix
+
+package filebuildtag_unix
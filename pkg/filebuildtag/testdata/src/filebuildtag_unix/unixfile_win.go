@@ -0,0 +1,4 @@
+// want +1 `missing expected build tag: "windows" required by pattern "\*_win.go"`
+//go:build unix || !testfix
+
+package filebuildtag_unix
@@ -0,0 +1 @@
+package filebuildtag_unused // want `pattern "\*_integraton_test.go" does not match any file of the package` `pattern "\*_skipped.go" does not match any file of the package` `pattern "re:foo\.\*" does not match any file of the package`
@@ -0,0 +1,72 @@
+package filebuildtag
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// timingOutput is where the timings of the debug-timing flag are printed, replaced by the tests.
+var timingOutput io.Writer = os.Stderr
+
+// phase is a phase of the check of a package whose time is recorded by the debug-timing flag.
+type phase int
+
+const (
+	// phasePreorder is the walk of the syntax trees by the inspector, which includes the other phases of the Go files.
+	phasePreorder phase = iota
+	// phaseParsing is the parsing of the build constraints, by internal.CheckGoFile and internal.CheckOtherFile.
+	phaseParsing
+	// phaseMatching is the matching of the files against the patterns of the rules and of the excludes.
+	phaseMatching
+	phaseCount
+)
+
+// timing records the time spent in each phase of the check of a package. A nil timing records nothing, so that the
+// checks cost no more than a nil check when the debug-timing flag is off.
+type timing struct {
+	started   time.Time
+	durations [phaseCount]time.Duration
+	files     int
+}
+
+// newTiming returns the timing of the check of a package starting now, or nil when the timing is disabled.
+func newTiming(enabled bool) *timing {
+	if !enabled {
+		return nil
+	}
+	return &timing{started: time.Now()}
+}
+
+// start returns the start time of a phase, the zero time when the timing is disabled.
+func (t *timing) start() time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+// stop records the time elapsed in the phase since it started.
+func (t *timing) stop(p phase, started time.Time) {
+	if t == nil {
+		return
+	}
+	t.durations[p] += time.Since(started)
+	if p == phaseParsing {
+		t.files++
+	}
+}
+
+// print prints the time spent checking the package, along with the time spent in each phase. The root package of the
+// scan command, having an empty path, is printed as ".".
+func (t *timing) print(w io.Writer, pkgPath string) {
+	if t == nil {
+		return
+	}
+	if pkgPath == "" {
+		pkgPath = "."
+	}
+	fmt.Fprintf(w, "%s: timing of %s: %d files in %s, preorder %s, parsing %s, matching %s\n", Name, pkgPath, t.files,
+		time.Since(t.started), t.durations[phasePreorder], t.durations[phaseParsing], t.durations[phaseMatching])
+}
@@ -0,0 +1,43 @@
+package filebuildtag
+
+// maxTypoDistance is the maximum edit distance between an expected tag and a present tag for the latter to be
+// considered a typo of the former.
+const maxTypoDistance = 2
+
+// closestTag returns the present tag which is most likely a typo of the expected tag, if any.
+//
+// To avoid noisy suggestions, the allowed edit distance depends on the length of the expected tag: one edit per
+// five characters, up to maxTypoDistance. Short tags such as "tag1" and "tag2" are therefore never confused.
+func closestTag(expected string, present []string) (string, bool) {
+	maxDistance := min(maxTypoDistance, len([]rune(expected))/5)
+	closest, closestDistance := "", maxDistance+1
+	for _, tag := range present {
+		d := editDistance(expected, tag)
+		if d > 0 && d < closestDistance {
+			closest, closestDistance = tag, d
+		}
+	}
+	return closest, closest != ""
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}